@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// recordAuditEvent writes a security event to the audit log. A failed write
+// is logged rather than returned so it never fails the request. Pass
+// uuid.Nil when the event isn't tied to a known user.
+func (cfg *apiConfig) recordAuditEvent(ctx context.Context, event string, userID uuid.UUID, ipAddress, details string) {
+	log.Printf("AUDIT %s user=%s ip=%s %s", event, userID, ipAddress, details)
+
+	err := cfg.db.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		Event:     event,
+		UserID:    uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil},
+		IpAddress: ipAddress,
+		Details:   details,
+	})
+	if err != nil {
+		log.Printf("Couldn't write audit event %s: %s", event, err)
+	}
+}
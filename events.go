@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/hashtag"
+	"github.com/MechamJonathan/chirpy/internal/realtime"
+	"github.com/MechamJonathan/chirpy/internal/stream"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// publishEvent queues eventType for the webhook endpoints userID has
+// subscribed to it. Failing to queue is logged rather than failing the
+// request that caused the event.
+func (cfg *apiConfig) publishEvent(ctx context.Context, userID uuid.UUID, eventType string, data interface{}) {
+	envelope, payload, err := webhook.NewEnvelope(eventType, data, time.Now())
+	if err != nil {
+		log.Printf("Couldn't encode %s event: %s", eventType, err)
+		return
+	}
+
+	_, err = cfg.db.EnqueueWebhookEvent(ctx, database.EnqueueWebhookEventParams{
+		EventID: envelope.ID,
+		Event:   eventType,
+		Payload: string(payload),
+		UserID:  userID,
+	})
+	if err != nil {
+		log.Printf("Couldn't queue %s event %s: %s", eventType, envelope.ID, err)
+	}
+}
+
+// publishChirp sends a chirp event to webhooks, SSE streams, WebSocket
+// subscribers and followers on other servers.
+func (cfg *apiConfig) publishChirp(ctx context.Context, eventType string, chirp Chirp) {
+	cfg.publishEvent(ctx, chirp.UserID, eventType, chirp)
+
+	data, err := json.Marshal(chirp)
+	if err != nil {
+		log.Printf("Couldn't encode %s event: %s", eventType, err)
+		return
+	}
+	cfg.chirpStream.Publish(stream.Event{
+		Type:     eventType,
+		Data:     data,
+		AuthorID: chirp.UserID,
+		Hashtags: hashtag.Extract(chirp.Body),
+	})
+
+	cfg.publishRealtime(ctx, realtime.AuthorTopic(chirp.UserID), eventType, chirp)
+	if chirp.ReplyToID != nil {
+		cfg.publishRealtime(ctx, realtime.RepliesTopic(*chirp.ReplyToID), eventType, chirp)
+	}
+
+	cfg.federateChirp(ctx, eventType, chirp)
+}
+
+// publishRealtime sends an event to WebSocket subscribers of topic.
+func (cfg *apiConfig) publishRealtime(ctx context.Context, topic, eventType string, data interface{}) {
+	err := realtime.PublishEvent(ctx, cfg.broker, topic, eventType, data)
+	if err != nil {
+		log.Printf("Couldn't publish %s to %s: %s", eventType, topic, err)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/activitypub"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/hashtag"
+	"github.com/MechamJonathan/chirpy/internal/notification"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	// remoteActorPassword isn't a valid hash, so no password matches it
+	// and nobody can log in as a remote actor.
+	remoteActorPassword = "!"
+	// maxRemoteNoteLength caps the runes kept from a remote note, since
+	// other servers allow longer posts than Chirpy does.
+	maxRemoteNoteLength = 1000
+)
+
+// federationBackend maps ActivityPub onto Chirpy's tables. Remote actors
+// get a local user, so a remote follow is a row in follows, a remote
+// like a row in chirp_likes and a remote reply a chirp.
+type federationBackend struct {
+	cfg *apiConfig
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return activitypub.ErrNotFound
+	}
+	return err
+}
+
+func (b federationBackend) LocalUser(ctx context.Context, username string) (activitypub.LocalUser, error) {
+	user, err := b.cfg.db.GetUserByUsername(ctx, sql.NullString{String: username, Valid: true})
+	if err != nil {
+		return activitypub.LocalUser{}, notFound(err)
+	}
+	return b.localUser(ctx, user)
+}
+
+func (b federationBackend) LocalUserByID(ctx context.Context, id uuid.UUID) (activitypub.LocalUser, error) {
+	user, err := b.cfg.db.GetUser(ctx, id)
+	if err != nil {
+		return activitypub.LocalUser{}, notFound(err)
+	}
+	return b.localUser(ctx, user)
+}
+
+// localUser returns a user with their signing key, making it the first
+// time. Only users with a username are federated.
+func (b federationBackend) localUser(ctx context.Context, user database.User) (activitypub.LocalUser, error) {
+	if !user.Username.Valid {
+		return activitypub.LocalUser{}, activitypub.ErrNotFound
+	}
+
+	key, err := b.cfg.db.GetUserKey(ctx, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		publicPEM, privatePEM, genErr := activitypub.GenerateKey()
+		if genErr != nil {
+			return activitypub.LocalUser{}, genErr
+		}
+		key, err = b.cfg.db.CreateUserKey(ctx, database.CreateUserKeyParams{
+			UserID:        user.ID,
+			PublicKeyPem:  publicPEM,
+			PrivateKeyPem: privatePEM,
+		})
+	}
+	if err != nil {
+		return activitypub.LocalUser{}, err
+	}
+	privateKey, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return activitypub.LocalUser{}, err
+	}
+
+	return activitypub.LocalUser{
+		ID:           user.ID,
+		Username:     user.Username.String,
+		PublicKeyPEM: key.PublicKeyPem,
+		PrivateKey:   privateKey,
+	}, nil
+}
+
+func (b federationBackend) Notes(ctx context.Context, userID uuid.UUID, limit int) ([]activitypub.LocalNote, error) {
+	chirps, err := b.cfg.db.ListRecentChirpsByAuthor(ctx, database.ListRecentChirpsByAuthorParams{
+		UserID: userID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	notes := []activitypub.LocalNote{}
+	for _, chirp := range chirps {
+		note, err := b.cfg.localNote(ctx, chirpFromDB(chirp))
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+// Note returns a chirp written here; chirps from other servers aren't
+// served as ours.
+func (b federationBackend) Note(ctx context.Context, id uuid.UUID) (activitypub.LocalNote, error) {
+	chirp, err := b.cfg.db.GetChirp(ctx, id)
+	if err != nil {
+		return activitypub.LocalNote{}, notFound(err)
+	}
+	_, err = b.cfg.db.GetRemoteNote(ctx, id)
+	if err == nil {
+		return activitypub.LocalNote{}, activitypub.ErrNotFound
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return activitypub.LocalNote{}, err
+	}
+	return b.cfg.localNote(ctx, chirpFromDB(chirp))
+}
+
+func (b federationBackend) RemoteActor(ctx context.Context, id string) (activitypub.RemoteActor, error) {
+	actor, err := b.cfg.db.GetRemoteActor(ctx, id)
+	if err != nil {
+		return activitypub.RemoteActor{}, notFound(err)
+	}
+	return remoteActorFromDB(actor), nil
+}
+
+func (b federationBackend) SaveRemoteActor(ctx context.Context, actor activitypub.RemoteActor) (activitypub.RemoteActor, error) {
+	existing, err := b.cfg.db.GetRemoteActor(ctx, actor.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return activitypub.RemoteActor{}, err
+	}
+	userID := existing.UserID
+
+	tx, err := b.cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return activitypub.RemoteActor{}, err
+	}
+	defer tx.Rollback()
+	qtx := b.cfg.db.WithTx(tx)
+
+	if userID == uuid.Nil {
+		user, err := qtx.CreateUser(ctx, database.CreateUserParams{
+			Email:          actor.ID,
+			HashedPassword: remoteActorPassword,
+		})
+		if err != nil {
+			return activitypub.RemoteActor{}, err
+		}
+		userID = user.ID
+	}
+	saved, err := qtx.UpsertRemoteActor(ctx, database.UpsertRemoteActorParams{
+		UserID:       userID,
+		ActorUri:     actor.ID,
+		Inbox:        actor.Inbox,
+		SharedInbox:  actor.SharedInbox,
+		PublicKeyID:  actor.PublicKeyID,
+		PublicKeyPem: actor.PublicKeyPEM,
+	})
+	if err != nil {
+		return activitypub.RemoteActor{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return activitypub.RemoteActor{}, err
+	}
+	return remoteActorFromDB(saved), nil
+}
+
+func (b federationBackend) AddFollower(ctx context.Context, userID uuid.UUID, follower activitypub.RemoteActor) error {
+	created, err := b.cfg.db.CreateFollow(ctx, database.CreateFollowParams{
+		FollowerID: follower.UserID,
+		FolloweeID: userID,
+	})
+	if err != nil || created == 0 {
+		return err
+	}
+	b.cfg.publishEvent(ctx, userID, webhook.EventUserFollowed, Follow{
+		FollowerID: follower.UserID,
+		FolloweeID: userID,
+		CreatedAt:  time.Now().UTC(),
+	})
+	b.cfg.notify(ctx, userID, follower.UserID, notification.TypeFollow, uuid.NullUUID{})
+	return nil
+}
+
+func (b federationBackend) RemoveFollower(ctx context.Context, userID uuid.UUID, follower activitypub.RemoteActor) error {
+	_, err := b.cfg.db.DeleteFollow(ctx, database.DeleteFollowParams{
+		FollowerID: follower.UserID,
+		FolloweeID: userID,
+	})
+	return err
+}
+
+func (b federationBackend) Followers(ctx context.Context, userID uuid.UUID) ([]activitypub.RemoteActor, error) {
+	actors, err := b.cfg.db.ListRemoteFollowers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	followers := []activitypub.RemoteActor{}
+	for _, actor := range actors {
+		followers = append(followers, remoteActorFromDB(actor))
+	}
+	return followers, nil
+}
+
+func (b federationBackend) AddPendingFollow(ctx context.Context, id string, userID uuid.UUID, followee activitypub.RemoteActor) error {
+	return b.cfg.db.CreatePendingFollow(ctx, database.CreatePendingFollowParams{
+		FollowUri:  id,
+		FollowerID: userID,
+		FolloweeID: followee.UserID,
+	})
+}
+
+func (b federationBackend) RemovePendingFollows(ctx context.Context, userID uuid.UUID, followee activitypub.RemoteActor) error {
+	return b.cfg.db.DeletePendingFollowsBetween(ctx, database.DeletePendingFollowsBetweenParams{
+		FollowerID: userID,
+		FolloweeID: followee.UserID,
+	})
+}
+
+// AddFollowing uses up the pending follow, so the same Accept can't be
+// replayed after an unfollow.
+func (b federationBackend) AddFollowing(ctx context.Context, followID string, followee activitypub.RemoteActor) error {
+	tx, err := b.cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := b.cfg.db.WithTx(tx)
+
+	userID, err := qtx.DeletePendingFollow(ctx, database.DeletePendingFollowParams{
+		FollowUri:  followID,
+		FolloweeID: followee.UserID,
+	})
+	if err != nil {
+		return notFound(err)
+	}
+	_, err = qtx.CreateFollow(ctx, database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: followee.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b federationBackend) IsFollowed(ctx context.Context, actor activitypub.RemoteActor) (bool, error) {
+	return b.cfg.db.IsRemoteActorFollowed(ctx, actor.UserID)
+}
+
+func (b federationBackend) AddLike(ctx context.Context, chirpID uuid.UUID, actor activitypub.RemoteActor) error {
+	chirp, err := b.cfg.db.GetChirp(ctx, chirpID)
+	if err != nil {
+		return notFound(err)
+	}
+	created, err := b.cfg.db.CreateChirpLike(ctx, database.CreateChirpLikeParams{
+		UserID:  actor.UserID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil || created == 0 {
+		return err
+	}
+	b.cfg.publishEvent(ctx, chirp.UserID, webhook.EventChirpLiked, ChirpLike{
+		UserID:    actor.UserID,
+		ChirpID:   chirp.ID,
+		CreatedAt: time.Now().UTC(),
+	})
+	b.cfg.notify(ctx, chirp.UserID, actor.UserID, notification.TypeLike, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	return nil
+}
+
+func (b federationBackend) RemoveLike(ctx context.Context, chirpID uuid.UUID, actor activitypub.RemoteActor) error {
+	_, err := b.cfg.db.DeleteChirpLike(ctx, database.DeleteChirpLikeParams{
+		UserID:  actor.UserID,
+		ChirpID: chirpID,
+	})
+	return err
+}
+
+// AddNote stores a remote note as a chirp by the actor's local user, and
+// notifies the same way a local chirp would. Replies to chirps that were
+// deleted, or whose author blocked the actor, are dropped.
+func (b federationBackend) AddNote(ctx context.Context, actor activitypub.RemoteActor, note activitypub.RemoteNote) error {
+	var parent database.Chirp
+	if note.InReplyTo.Valid {
+		var err error
+		parent, err = b.cfg.db.GetChirp(ctx, note.InReplyTo.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		blocked, err := b.cfg.db.HasBlockBetween(ctx, database.HasBlockBetweenParams{
+			UserID: actor.UserID,
+			Others: []uuid.UUID{parent.UserID},
+		})
+		if err != nil || blocked {
+			return err
+		}
+	}
+
+	body, err := validateChirp(truncateRunes(note.Content, maxRemoteNoteLength), 0)
+	if err != nil || body == "" {
+		return err
+	}
+
+	tx, err := b.cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := b.cfg.db.WithTx(tx)
+
+	chirp, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+		Body:      body,
+		UserID:    actor.UserID,
+		ReplyToID: note.InReplyTo,
+		Hashtags:  hashtag.Extract(body),
+	})
+	if err != nil {
+		return err
+	}
+	created, err := qtx.CreateRemoteNote(ctx, database.CreateRemoteNoteParams{
+		ChirpID:   chirp.ID,
+		ObjectUri: note.ID,
+	})
+	if err != nil || created == 0 {
+		// Already stored; the rollback drops the duplicate chirp.
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	b.cfg.publishChirp(ctx, webhook.EventChirpCreated, chirpFromDB(chirp))
+	b.cfg.notifyChirpCreated(ctx, chirp, parent)
+	return nil
+}
+
+// RemoveNote deletes the chirp made from one of the actor's notes and
+// tells subscribers, as a local delete would.
+func (b federationBackend) RemoveNote(ctx context.Context, actor activitypub.RemoteActor, id string) error {
+	chirp, err := b.cfg.db.DeleteRemoteChirp(ctx, database.DeleteRemoteChirpParams{
+		UserID:    actor.UserID,
+		ObjectUri: id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b.cfg.publishChirp(ctx, webhook.EventChirpDeleted, chirpFromDB(chirp))
+	return nil
+}
+
+func remoteActorFromDB(actor database.RemoteActor) activitypub.RemoteActor {
+	return activitypub.RemoteActor{
+		UserID:       actor.UserID,
+		ID:           actor.ActorUri,
+		Inbox:        actor.Inbox,
+		SharedInbox:  actor.SharedInbox,
+		PublicKeyID:  actor.PublicKeyID,
+		PublicKeyPEM: actor.PublicKeyPem,
+	}
+}
+
+func truncateRunes(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes])
+}
+
+// localNote is a chirp as federation sends it. A reply to a remote chirp
+// points at the original note and is addressed to its author.
+func (cfg *apiConfig) localNote(ctx context.Context, chirp Chirp) (activitypub.LocalNote, error) {
+	note := activitypub.LocalNote{
+		ID:        chirp.ID,
+		AuthorID:  chirp.UserID,
+		Body:      chirp.Body,
+		Published: chirp.CreatedAt,
+	}
+	if chirp.ReplyToID == nil {
+		return note, nil
+	}
+
+	remote, err := cfg.db.GetRemoteNote(ctx, *chirp.ReplyToID)
+	if errors.Is(err, sql.ErrNoRows) {
+		note.InReplyTo = cfg.federation.NoteID(*chirp.ReplyToID)
+		return note, nil
+	}
+	if err != nil {
+		return activitypub.LocalNote{}, err
+	}
+	note.InReplyTo = remote.ObjectUri
+
+	parent, err := cfg.db.GetChirp(ctx, *chirp.ReplyToID)
+	if err != nil {
+		return activitypub.LocalNote{}, err
+	}
+	author, err := cfg.db.GetRemoteActorByUserID(ctx, parent.UserID)
+	if err != nil {
+		return activitypub.LocalNote{}, err
+	}
+	replyTo := remoteActorFromDB(author)
+	note.ReplyTo = &replyTo
+	return note, nil
+}
+
+// federateChirp sends a new or deleted chirp to its author's followers on
+// other servers. Chirps from other servers aren't sent back out, since
+// their authors aren't federated users here.
+func (cfg *apiConfig) federateChirp(ctx context.Context, eventType string, chirp Chirp) {
+	var err error
+	switch eventType {
+	case webhook.EventChirpCreated:
+		var note activitypub.LocalNote
+		note, err = cfg.localNote(ctx, chirp)
+		if err == nil {
+			err = cfg.federation.PublishNote(ctx, note)
+		}
+	case webhook.EventChirpDeleted:
+		err = cfg.federation.DeleteNote(ctx, activitypub.LocalNote{ID: chirp.ID, AuthorID: chirp.UserID})
+	}
+	if err != nil {
+		log.Printf("Couldn't federate %s for chirp %s: %s", eventType, chirp.ID, err)
+	}
+}
+
+// federateLike tells the author of a chirp from another server that a
+// local user liked it.
+func (cfg *apiConfig) federateLike(ctx context.Context, userID uuid.UUID, chirp database.Chirp) {
+	remote, err := cfg.db.GetRemoteNote(ctx, chirp.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	var author database.RemoteActor
+	if err == nil {
+		author, err = cfg.db.GetRemoteActorByUserID(ctx, chirp.UserID)
+	}
+	if err == nil {
+		err = cfg.federation.Like(ctx, userID, remote.ObjectUri, remoteActorFromDB(author))
+	}
+	if err != nil {
+		log.Printf("Couldn't federate like of chirp %s: %s", chirp.ID, err)
+	}
+}
+
+// federateFollow sends a follow or unfollow of a remote actor's local
+// user to their server.
+func (cfg *apiConfig) federateFollow(ctx context.Context, followerID, followeeID uuid.UUID, follow bool) {
+	actor, err := cfg.db.GetRemoteActorByUserID(ctx, followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err == nil && follow {
+		err = cfg.federation.Follow(ctx, followerID, actor.ActorUri)
+	} else if err == nil {
+		err = cfg.federation.Unfollow(ctx, followerID, actor.ActorUri)
+	}
+	if err != nil {
+		log.Printf("Couldn't federate follow of %s: %s", followeeID, err)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/activitypub"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// startFederatedTestInstance serves cfg's federation routes from httptest
+// with the real federationBackend. Instances in a test share the test
+// database, so each needs its own users; deliveries are queued in memory
+// so that each instance only sends its own.
+func startFederatedTestInstance(t *testing.T, cfg *apiConfig) {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	federation, err := activitypub.NewServer(server.URL, federationBackend{cfg: cfg}, activitypub.NewMemoryStore(),
+		webhook.NewClient(true), webhook.DefaultRetryPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	federation.Register(mux)
+	cfg.publicURL = server.URL
+	cfg.federation = federation
+}
+
+// flushFederation sends everything the instances have queued, including
+// what they queue in reply, until there is nothing left.
+func flushFederation(t *testing.T, cfgs ...*apiConfig) {
+	t.Helper()
+	for {
+		sent := 0
+		for _, cfg := range cfgs {
+			n, err := cfg.federation.RunOnce(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent += n
+		}
+		if sent == 0 {
+			return
+		}
+	}
+}
+
+// countTestRows runs a COUNT(*) query.
+func countTestRows(t *testing.T, cfg *apiConfig, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	err := cfg.dbConn.QueryRow(query, args...).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestFederationBackend(t *testing.T) {
+	ctx := context.Background()
+	a := newTestConfig(t)
+	b := newTestConfig(t)
+	startFederatedTestInstance(t, a)
+	startFederatedTestInstance(t, b)
+	alice := createTestUser(t, a, "alice")
+	bob := createTestUser(t, b, "bob")
+	aliceID, bobID := a.federation.ActorID("alice"), b.federation.ActorID("bob")
+
+	// Fetching alice from bob's server gives her a local user there.
+	remoteAlice, err := b.federation.FetchActor(ctx, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceUser, err := b.db.GetUser(ctx, remoteAlice.UserID)
+	if err != nil {
+		t.Fatalf("remote alice has no user: %v", err)
+	}
+	if aliceUser.Email != aliceID || aliceUser.Username.Valid {
+		t.Errorf("remote alice's user = %+v, want the actor ID as email and no username", aliceUser)
+	}
+
+	// Bob follows alice. Her server records the follower and accepts, and
+	// only then does his server record the follow.
+	b.federateFollow(ctx, bob.ID, remoteAlice.UserID, true)
+	flushFederation(t, a, b)
+	remoteBob, err := a.db.GetRemoteActor(ctx, bobID)
+	if err != nil {
+		t.Fatalf("bob wasn't saved on alice's server: %v", err)
+	}
+	if n := countTestRows(t, a, "SELECT COUNT(*) FROM follows WHERE follower_id = $1 AND followee_id = $2", remoteBob.UserID, alice.ID); n != 1 {
+		t.Errorf("%d follows of alice by bob on her server, want 1", n)
+	}
+	following, err := b.db.ListFollowing(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(following, []uuid.UUID{remoteAlice.UserID}) {
+		t.Errorf("bob follows %v, want remote alice %s", following, remoteAlice.UserID)
+	}
+	if n := countTestRows(t, b, "SELECT COUNT(*) FROM activitypub_pending_follows"); n != 0 {
+		t.Errorf("%d pending follows after the accept, want 0", n)
+	}
+
+	// Alice's chirp reaches bob's server as a chirp by remote alice.
+	chirp := createTestChirp(t, a, alice, "hello #fediverse")
+	a.federateChirp(ctx, webhook.EventChirpCreated, chirpFromDB(chirp))
+	flushFederation(t, a, b)
+	copies, err := b.db.GetChirpsByAuthors(ctx, []uuid.UUID{remoteAlice.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 1 || copies[0].Body != chirp.Body || !slices.Equal(copies[0].Hashtags, []string{"fediverse"}) {
+		t.Fatalf("remote alice's chirps = %+v, want her chirp", copies)
+	}
+	remoteChirp := copies[0]
+
+	// Bob likes it and replies.
+	b.federateLike(ctx, bob.ID, remoteChirp)
+	reply, err := b.db.CreateChirp(ctx, database.CreateChirpParams{
+		Body:      "hi alice",
+		UserID:    bob.ID,
+		ReplyToID: uuid.NullUUID{UUID: remoteChirp.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	note, err := b.localNote(ctx, chirpFromDB(reply))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.InReplyTo != a.federation.NoteID(chirp.ID) || note.ReplyTo == nil || note.ReplyTo.ID != aliceID {
+		t.Errorf("reply note = %+v, want it to reply to alice's note and reach her", note)
+	}
+	b.federateChirp(ctx, webhook.EventChirpCreated, chirpFromDB(reply))
+	flushFederation(t, a, b)
+	if n := countTestRows(t, a, "SELECT COUNT(*) FROM chirp_likes WHERE user_id = $1 AND chirp_id = $2", remoteBob.UserID, chirp.ID); n != 1 {
+		t.Errorf("%d likes of alice's chirp by bob, want 1", n)
+	}
+	replies, err := a.db.GetChirpsByAuthors(ctx, []uuid.UUID{remoteBob.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1 || replies[0].Body != "hi alice" || replies[0].ReplyToID.UUID != chirp.ID {
+		t.Errorf("remote bob's chirps = %+v, want his reply to alice", replies)
+	}
+
+	// Alice deletes her chirp, and bob's server deletes its copy.
+	a.federateChirp(ctx, webhook.EventChirpDeleted, chirpFromDB(chirp))
+	flushFederation(t, a, b)
+	_, err = b.db.GetChirp(ctx, remoteChirp.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("getting the deleted copy: error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	// Bob unfollows.
+	b.federateFollow(ctx, bob.ID, remoteAlice.UserID, false)
+	flushFederation(t, a, b)
+	if n := countTestRows(t, a, "SELECT COUNT(*) FROM follows WHERE follower_id = $1", remoteBob.UserID); n != 0 {
+		t.Errorf("%d follows by bob on alice's server after unfollowing, want 0", n)
+	}
+}
@@ -3,12 +3,12 @@ module github.com/MechamJonathan/chirpy
 go 1.23.4
 
 require (
+	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.32.0
 )
 
-require (
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-	golang.org/x/crypto v0.32.0 // indirect
-)
+require golang.org/x/sys v0.29.0 // indirect
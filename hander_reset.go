@@ -4,8 +4,7 @@ import "net/http"
 
 func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("Reset is only allowed in dev environment."))
+		respondWithError(w, http.StatusForbidden, "Reset is only allowed in dev environment", nil)
 		return
 	}
 
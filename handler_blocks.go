@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Block struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// handlerBlocksCreate blocks the user in the path and removes the follows
+// between them. Neither user can then follow, message, like, reply to or
+// quote the other, or notify them.
+func (cfg *apiConfig) handlerBlocksCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid user ID", err)
+		return
+	}
+	if blockedID == accessToken.UserID {
+		respondWithError(w, http.StatusBadRequest, "You can't block yourself", nil)
+		return
+	}
+
+	_, err = cfg.db.GetUser(r.Context(), blockedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	created, err := cfg.createBlock(r.Context(), accessToken.UserID, blockedID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+	if created == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Block{
+		BlockerID: accessToken.UserID,
+		BlockedID: blockedID,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+func (cfg *apiConfig) createBlock(ctx context.Context, blockerID, blockedID uuid.UUID) (int64, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	created, err := qtx.CreateBlock(ctx, database.CreateBlockParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = qtx.DeleteFollowsBetween(ctx, database.DeleteFollowsBetweenParams{
+		UserID:  blockerID,
+		OtherID: blockedID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created, tx.Commit()
+}
+
+func (cfg *apiConfig) handlerBlocksDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid user ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteBlock(r.Context(), database.DeleteBlockParams{
+		BlockerID: accessToken.UserID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unblock user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You haven't blocked this user", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkNotBlocked responds with a 403 and reports false if userID has
+// blocked, or been blocked by, any of others.
+func (cfg *apiConfig) checkNotBlocked(w http.ResponseWriter, r *http.Request, userID uuid.UUID, others []uuid.UUID, msg string) bool {
+	blocked, err := cfg.db.HasBlockBetween(r.Context(), database.HasBlockBetweenParams{
+		UserID: userID,
+		Others: others,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check blocks", err)
+		return false
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, msg, nil)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+)
+
+func TestHandlerBlocksCreate(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	aliceToken := loginTestUser(t, cfg, alice)
+	block := func() *httptest.ResponseRecorder {
+		return serveAuthed(cfg.handlerBlocksCreate, aliceToken, http.MethodPost, "/api/users/"+bob.ID.String()+"/block", "", "userID", bob.ID.String())
+	}
+
+	_, err := cfg.db.CreateFollow(context.Background(), database.CreateFollowParams{FollowerID: alice.ID, FolloweeID: bob.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.db.CreateFollow(context.Background(), database.CreateFollowParams{FollowerID: bob.ID, FolloweeID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, block(), http.StatusCreated)
+	expectStatus(t, block(), http.StatusNoContent)
+	for _, user := range []database.User{alice, bob} {
+		following, err := cfg.db.ListFollowing(context.Background(), user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(following) != 0 {
+			t.Errorf("%s still follows %v after the block", user.Username.String, following)
+		}
+	}
+
+	w := serveAuthed(cfg.handlerBlocksCreate, aliceToken, http.MethodPost, "/api/users/"+alice.ID.String()+"/block", "", "userID", alice.ID.String())
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestBlocksGateInteractions(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	aliceToken, bobToken := loginTestUser(t, cfg, alice), loginTestUser(t, cfg, bob)
+	conversation := startTestConversation(t, cfg, aliceToken, bob.ID)
+	chirp := createTestChirp(t, cfg, alice, "hello")
+	blockTestUser(t, cfg, alice, bob)
+
+	chirpID, aliceID := chirp.ID.String(), alice.ID.String()
+	tests := []struct {
+		name string
+		h    authedHandler
+		body string
+		path []string
+	}{
+		{"start a conversation", cfg.handlerConversationsCreate, `{"participant_ids": ["` + aliceID + `"]}`, nil},
+		{"send a message", cfg.handlerMessagesCreate, `{"body": "hi"}`, []string{"conversationID", conversation.ID.String()}},
+		{"follow", cfg.handlerFollowsCreate, "", []string{"userID", aliceID}},
+		{"like", cfg.handlerChirpLikesCreate, "", []string{"chirpID", chirpID}},
+		{"reply", cfg.handler_chirps_create, `{"body": "hi", "reply_to_id": "` + chirpID + `"}`, nil},
+		{"quote", cfg.handler_chirps_create, `{"body": "hi", "quote_of": "` + chirpID + `"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAuthed(tt.h, bobToken, http.MethodPost, "/", tt.body, tt.path...)
+			expectStatus(t, w, http.StatusForbidden)
+		})
+	}
+
+	// Blocks work both ways, and end with an unblock.
+	w := serveAuthed(cfg.handlerFollowsCreate, aliceToken, http.MethodPost, "/", "", "userID", bob.ID.String())
+	expectStatus(t, w, http.StatusForbidden)
+	w = serveAuthed(cfg.handlerBlocksDelete, aliceToken, http.MethodDelete, "/", "", "userID", bob.ID.String())
+	expectStatus(t, w, http.StatusNoContent)
+	w = serveAuthed(cfg.handlerFollowsCreate, bobToken, http.MethodPost, "/", "", "userID", aliceID)
+	expectStatus(t, w, http.StatusCreated)
+	var follow Follow
+	err := json.NewDecoder(w.Body).Decode(&follow)
+	if err != nil || follow.FolloweeID != alice.ID {
+		t.Errorf("follow = %+v, error = %v; want bob following alice", follow, err)
+	}
+}
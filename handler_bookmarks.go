@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerBookmarksCreate bookmarks a chirp. Unlike likes, bookmarks are
+// private: the author isn't told.
+func (cfg *apiConfig) handlerBookmarksCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid chirp ID", err)
+		return
+	}
+	_, err = cfg.db.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp from database", err)
+		return
+	}
+
+	created, err := cfg.db.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:  accessToken.UserID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't bookmark chirp", err)
+		return
+	}
+	if created == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (cfg *apiConfig) handlerBookmarksDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid chirp ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  accessToken.UserID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove bookmark", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You haven't bookmarked this chirp", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerBookmarksList returns the user's bookmarked chirps, most
+// recently bookmarked first.
+func (cfg *apiConfig) handlerBookmarksList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	dbChirps, err := cfg.db.ListBookmarkedChirps(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get bookmarks", err)
+		return
+	}
+
+	chirps := []Chirp{}
+	for _, dbChirp := range dbChirps {
+		chirps = append(chirps, chirpFromDB(dbChirp))
+	}
+	err = cfg.addQuotes(r.Context(), chirps, accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get quoted chirps", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirps)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/notification"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+type ChirpLike struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// handlerChirpLikesCreate likes a chirp and tells its author.
+func (cfg *apiConfig) handlerChirpLikesCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid chirp ID", err)
+		return
+	}
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp from database", err)
+		return
+	}
+	if !cfg.checkNotBlocked(w, r, accessToken.UserID, []uuid.UUID{chirp.UserID}, "You can't like this chirp") {
+		return
+	}
+
+	created, err := cfg.db.CreateChirpLike(r.Context(), database.CreateChirpLikeParams{
+		UserID:  accessToken.UserID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+	if created == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	like := ChirpLike{
+		UserID:    accessToken.UserID,
+		ChirpID:   chirp.ID,
+		CreatedAt: time.Now().UTC(),
+	}
+	cfg.publishEvent(r.Context(), chirp.UserID, webhook.EventChirpLiked, like)
+	cfg.notify(r.Context(), chirp.UserID, accessToken.UserID, notification.TypeLike, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	cfg.federateLike(r.Context(), accessToken.UserID, chirp)
+
+	respondWithJSON(w, http.StatusCreated, like)
+}
+
+func (cfg *apiConfig) handlerChirpLikesDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid chirp ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteChirpLike(r.Context(), database.DeleteChirpLikeParams{
+		UserID:  accessToken.UserID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You haven't liked this chirp", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
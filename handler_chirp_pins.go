@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// handlerChirpPinsCreate pins one of the user's own chirps to their
+// profile, up to their tier's pinned_chirps limit.
+func (cfg *apiConfig) handlerChirpPinsCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid chirp ID", err)
+		return
+	}
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp from database", err)
+		return
+	}
+	if chirp.UserID != accessToken.UserID {
+		respondWithError(w, http.StatusForbidden, "You can only pin your own chirps", nil)
+		return
+	}
+
+	grant, err := cfg.entitlement(r.Context(), accessToken.UserID, entitlements.PinnedChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get entitlements", err)
+		return
+	}
+	if !grant.Allowed {
+		respondWithError(w, http.StatusForbidden, "You can't pin chirps", nil)
+		return
+	}
+
+	pinned, err := cfg.pinChirp(r.Context(), accessToken.UserID, chirp.ID, grant.Limit)
+	if errors.Is(err, errPinLimitReached) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can pin at most %d chirps", grant.Limit), nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+	if !pinned {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+var errPinLimitReached = errors.New("pinned chirp limit reached")
+
+// pinChirp pins the chirp unless the user already has limit pins (0 means
+// no limit). It reports false if the chirp was already pinned, which
+// doesn't count against the limit. Concurrent pins by the same user wait
+// for each other, so they can't go over it together.
+func (cfg *apiConfig) pinChirp(ctx context.Context, userID, chirpID uuid.UUID, limit int) (bool, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.LockUserPins(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	alreadyPinned, err := qtx.IsChirpPinned(ctx, database.IsChirpPinnedParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil || alreadyPinned {
+		return false, err
+	}
+	if limit > 0 {
+		count, err := qtx.CountPinnedChirps(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		if count >= int64(limit) {
+			return false, errPinLimitReached
+		}
+	}
+
+	pinned, err := qtx.PinChirp(ctx, database.PinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return pinned > 0, tx.Commit()
+}
+
+func (cfg *apiConfig) handlerChirpPinsDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid chirp ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeletePinnedChirp(r.Context(), database.DeletePinnedChirpParams{
+		UserID:  accessToken.UserID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unpin chirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp isn't pinned", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pinnedChirpIDs returns the user's pins, most recent first. After a
+// downgrade only as many as their tier now allows are shown; the rest
+// stay pinned in case they upgrade again.
+func (cfg *apiConfig) pinnedChirpIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	grant, err := cfg.entitlement(ctx, userID, entitlements.PinnedChirps)
+	if err != nil {
+		return nil, err
+	}
+	if !grant.Allowed {
+		return nil, nil
+	}
+	pinned, err := cfg.db.ListPinnedChirpIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if grant.Limit > 0 && len(pinned) > grant.Limit {
+		pinned = pinned[:grant.Limit]
+	}
+	return pinned, nil
+}
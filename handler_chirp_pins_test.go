@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestHandlerChirpPinsCreate(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	aliceToken := loginTestUser(t, cfg, alice)
+	first, second := createTestChirp(t, cfg, alice, "first"), createTestChirp(t, cfg, alice, "second")
+	bobs := createTestChirp(t, cfg, bob, "bob's")
+	pin := func(chirpID string) int {
+		return serveAuthed(cfg.handlerChirpPinsCreate, aliceToken, http.MethodPost, "/", "", "chirpID", chirpID).Code
+	}
+
+	// Free accounts can pin one chirp.
+	if status := pin(first.ID.String()); status != http.StatusCreated {
+		t.Fatalf("pinning: status = %d, want %d", status, http.StatusCreated)
+	}
+	if status := pin(first.ID.String()); status != http.StatusNoContent {
+		t.Errorf("pinning it again at the limit: status = %d, want %d", status, http.StatusNoContent)
+	}
+	if status := pin(second.ID.String()); status != http.StatusForbidden {
+		t.Errorf("pinning over the limit: status = %d, want %d", status, http.StatusForbidden)
+	}
+	if status := pin(bobs.ID.String()); status != http.StatusForbidden {
+		t.Errorf("pinning someone else's chirp: status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	w := serveAuthed(cfg.handlerChirpPinsDelete, aliceToken, http.MethodDelete, "/", "", "chirpID", first.ID.String())
+	expectStatus(t, w, http.StatusNoContent)
+	if status := pin(second.ID.String()); status != http.StatusCreated {
+		t.Errorf("pinning after unpinning: status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+func TestPinChirpConcurrently(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	alice := createTestUser(t, cfg, "alice")
+	const limit = 3
+
+	var wg sync.WaitGroup
+	for range 10 {
+		chirp := createTestChirp(t, cfg, alice, "pin me")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cfg.pinChirp(ctx, alice.ID, chirp.ID, limit)
+			if err != nil && !errors.Is(err, errPinLimitReached) {
+				t.Errorf("pinChirp() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count, err := cfg.db.CountPinnedChirps(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != limit {
+		t.Errorf("%d chirps pinned concurrently, want the limit of %d", count, limit)
+	}
+}
@@ -1,95 +1,184 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/MechamJonathan/chirpy/internal/auth"
 	"github.com/MechamJonathan/chirpy/internal/database"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/MechamJonathan/chirpy/internal/entitlements"
+	"github.com/MechamJonathan/chirpy/internal/hashtag"
+	"github.com/MechamJonathan/chirpy/internal/notification"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uuid.UUID `json:"user_id"`
-	Body      string    `json:"body"`
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Body      string       `json:"body"`
+	ReplyToID *uuid.UUID   `json:"reply_to_id,omitempty"`
+	QuoteOf   *uuid.UUID   `json:"quote_of,omitempty"`
+	Quote     *QuotedChirp `json:"quote,omitempty"`
+	Pinned    bool         `json:"pinned,omitempty"`
 }
 
-func (cfg *apiConfig) handler_chirps_create(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handler_chirps_create(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		ReplyToID *uuid.UUID `json:"reply_to_id"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 
-	// Get and validate JWT token
-	token, err := auth.GetBearerToken(r.Header)
+	userID := accessToken.UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT token", err)
+		respondWithDecodeError(w, err)
 		return
 	}
 
-	// Parse and validate the token
-	claims := jwt.RegisteredClaims{}
-	parsedToken, err := jwt.ParseWithClaims(
-		token,
-		&claims,
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.jwtSecret), nil
-		},
-	)
-	if err != nil || !parsedToken.Valid {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+	chirpLength, err := cfg.entitlement(r.Context(), userID, entitlements.ChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get entitlements", err)
 		return
 	}
-
-	// Parse the user ID from the token's subject claim
-	userID, err := uuid.Parse(claims.Subject)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid user ID in token", err)
+	if !chirpLength.Allowed {
+		respondWithError(w, http.StatusForbidden, "You can't post chirps", nil)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	cleanedBody, err := validateChirp(params.Body, chirpLength.Limit)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithInvalidField(w, "body", err)
 		return
 	}
 
-	cleanedBody, err := validateChirp(params.Body)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error(), err)
-		return
+	var parent database.Chirp
+	replyToID := uuid.NullUUID{}
+	if params.ReplyToID != nil {
+		parent, err = cfg.db.GetChirp(r.Context(), *params.ReplyToID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusBadRequest, "The chirp you're replying to doesn't exist", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp from database", err)
+			return
+		}
+		if !cfg.checkNotBlocked(w, r, userID, []uuid.UUID{parent.UserID}, "You can't reply to this chirp") {
+			return
+		}
+		replyToID = uuid.NullUUID{UUID: *params.ReplyToID, Valid: true}
+	}
+
+	var quoted database.Chirp
+	quoteOfID := uuid.NullUUID{}
+	if params.QuoteOf != nil {
+		quoted, err = cfg.db.GetChirp(r.Context(), *params.QuoteOf)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusBadRequest, "The chirp you're quoting doesn't exist", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp from database", err)
+			return
+		}
+		if !cfg.checkNotBlocked(w, r, userID, []uuid.UUID{quoted.UserID}, "You can't quote this chirp") {
+			return
+		}
+		quoteOfID = uuid.NullUUID{UUID: quoted.ID, Valid: true}
 	}
 
 	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanedBody,
-		UserID: userID,
+		Body:      cleanedBody,
+		UserID:    userID,
+		ReplyToID: replyToID,
+		QuoteOfID: quoteOfID,
+		Hashtags:  hashtag.Extract(cleanedBody),
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error(), err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	created := chirpFromDB(chirp)
+	if quoteOfID.Valid {
+		created.Quote = quotedChirpFrom(chirpFromDB(quoted))
+	}
+	cfg.publishChirp(r.Context(), webhook.EventChirpCreated, created)
+	cfg.notifyChirpCreated(r.Context(), chirp, parent)
+
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+// maxMentionNotifications caps how many users one chirp can notify by
+// mentioning them.
+const maxMentionNotifications = 10
+
+// notifyChirpCreated tells the author of parent (if the chirp is a reply)
+// and the users the chirp mentions.
+func (cfg *apiConfig) notifyChirpCreated(ctx context.Context, chirp, parent database.Chirp) {
+	if chirp.ReplyToID.Valid {
+		cfg.notify(ctx, parent.UserID, chirp.UserID, notification.TypeReply, chirp.ReplyToID)
+	}
+
+	usernames := notification.Mentions(chirp.Body)
+	if len(usernames) == 0 {
+		return
+	}
+	if len(usernames) > maxMentionNotifications {
+		usernames = usernames[:maxMentionNotifications]
+	}
+	mentioned, err := cfg.db.GetUsersByUsernames(ctx, usernames)
+	if err != nil {
+		log.Printf("Couldn't look up mentioned users: %s", err)
 		return
 	}
+	for _, user := range mentioned {
+		// The reply notification already covers the parent's author.
+		if chirp.ReplyToID.Valid && user.ID == parent.UserID {
+			continue
+		}
+		cfg.notify(ctx, user.ID, chirp.UserID, notification.TypeMention, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	}
+}
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
+func chirpFromDB(chirp database.Chirp) Chirp {
+	result := Chirp{
 		ID:        chirp.ID,
 		CreatedAt: chirp.CreatedAt,
 		UpdatedAt: chirp.UpdatedAt,
 		Body:      chirp.Body,
 		UserID:    chirp.UserID,
-	})
-
+	}
+	if chirp.ReplyToID.Valid {
+		result.ReplyToID = &chirp.ReplyToID.UUID
+	}
+	if chirp.QuoteOfID.Valid {
+		result.QuoteOf = &chirp.QuoteOfID.UUID
+	} else if chirp.IsQuote {
+		// The quoted chirp was deleted.
+		result.Quote = unavailableQuote(nil)
+	}
+	return result
 }
 
-func validateChirp(body string) (string, error) {
-	const maxChirpLength = 140
-	if len(body) > maxChirpLength {
+// validateChirp censors the body. maxChirpLength counts characters, not
+// bytes; 0 means no limit.
+func validateChirp(body string, maxChirpLength int) (string, error) {
+	if maxChirpLength > 0 && utf8.RuneCountInString(body) > maxChirpLength {
 		return "", errors.New("Chirp is too long")
 	}
 
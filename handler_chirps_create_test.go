@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestValidateChirp(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		limit   int
+		want    string
+		wantErr bool
+	}{
+		{"censors bad words", "What a kerfuffle that Sharbert made", 140, "What a **** that **** made", false},
+		{"at the limit", "hello", 5, "hello", false},
+		{"over the limit", "hello!", 5, "", true},
+		// Five characters, but fifteen bytes.
+		{"counts characters, not bytes", "こんにちは", 5, "こんにちは", false},
+		{"no limit", "hello!", 0, "hello!", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateChirp(tt.body, tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateChirp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validateChirp() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
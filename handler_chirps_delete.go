@@ -7,21 +7,12 @@ import (
 
 	"github.com/MechamJonathan/chirpy/internal/auth"
 	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
 	"github.com/google/uuid"
 )
 
-func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request) {
-
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
-		return
-	}
+func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	userID := accessToken.UserID
 
 	chirpIdString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIdString)
@@ -45,6 +36,7 @@ func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Likes, bookmarks and pins of the chirp go with it (ON DELETE CASCADE).
 	_, err = cfg.db.DeleteChirpByID(r.Context(), database.DeleteChirpByIDParams{
 		ID:     chirpID,
 		UserID: userID,
@@ -53,6 +45,7 @@ func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request
 		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
+	cfg.publishChirp(r.Context(), webhook.EventChirpDeleted, chirpFromDB(dbChirp))
 
 	w.WriteHeader(http.StatusNoContent)
 }
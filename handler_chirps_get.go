@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"sort"
 
+	"github.com/MechamJonathan/chirpy/internal/database"
 	"github.com/google/uuid"
 )
 
@@ -11,32 +15,35 @@ func (cfg *apiConfig) handlerChirpsGet(w http.ResponseWriter, r *http.Request) {
 	chirpIdString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIdString)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Invalid chirp ID", err)
+		respondWithError(w, http.StatusNotFound, "Invalid chirp ID", err)
 		return
 	}
 
 	dbChirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't get chirp from database", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp from database", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		UserID:    dbChirp.UserID,
-		Body:      dbChirp.Body,
-	})
-}
-
-func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Request) {
-	dbChirps, err := cfg.db.GetAllChirps(r.Context())
+	viewerID, ok := cfg.chirpViewer(w, r)
+	if !ok {
+		return
+	}
+	chirps := []Chirp{chirpFromDB(dbChirp)}
+	err = cfg.addQuotes(r.Context(), chirps, viewerID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from database", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get quoted chirp", err)
 		return
 	}
 
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
+
+func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Request) {
 	authorID := r.URL.Query().Get("author_id")
 	var authorUUID uuid.UUID
 	if authorID != "" {
@@ -48,27 +55,57 @@ func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Reque
 		authorUUID = parsed
 	}
 
-	chirps := []Chirp{}
-	for _, dbChirp := range dbChirps {
+	dbChirps, err := cfg.publicChirps(r.Context(), uuid.NullUUID{UUID: authorUUID, Valid: authorID != ""})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from database", err)
+		return
+	}
+
+	var pinned []uuid.UUID
+	if r.URL.Query().Get("pinned_first") == "true" {
 		if authorID == "" {
-			chirps = append(chirps, Chirp{
-				ID:        dbChirp.ID,
-				CreatedAt: dbChirp.CreatedAt,
-				UpdatedAt: dbChirp.UpdatedAt,
-				Body:      dbChirp.Body,
-				UserID:    dbChirp.UserID,
-			})
-		} else if dbChirp.UserID == authorUUID {
-			chirps = append(chirps, Chirp{
-				ID:        dbChirp.ID,
-				CreatedAt: dbChirp.CreatedAt,
-				UpdatedAt: dbChirp.UpdatedAt,
-				Body:      dbChirp.Body,
-				UserID:    dbChirp.UserID,
-			})
+			respondWithError(w, http.StatusBadRequest, "pinned_first needs an author_id", nil)
+			return
+		}
+		pinned, err = cfg.pinnedChirpIDs(r.Context(), authorUUID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get pinned chirps", err)
+			return
 		}
 	}
 
+	cfg.respondWithChirps(w, r, dbChirps, pinned)
+}
+
+// publicChirps returns the chirps anyone may see, oldest first, by
+// authorID if it's valid. Every chirp is public today; anything that must
+// stay private, such as direct messages, lives in its own table.
+// Feeds query their chirps directly and follow the same rule.
+func (cfg *apiConfig) publicChirps(ctx context.Context, authorID uuid.NullUUID) ([]database.Chirp, error) {
+	if authorID.Valid {
+		return cfg.db.GetChirpsByAuthors(ctx, []uuid.UUID{authorID.UUID})
+	}
+	return cfg.db.GetAllChirps(ctx)
+}
+
+// respondWithChirps orders chirps by ?sort, oldest first unless it's
+// "desc". Every endpoint that returns a timeline uses it. Chirps in
+// pinned come before the rest, in the order given.
+func (cfg *apiConfig) respondWithChirps(w http.ResponseWriter, r *http.Request, dbChirps []database.Chirp, pinned []uuid.UUID) {
+	viewerID, ok := cfg.chirpViewer(w, r)
+	if !ok {
+		return
+	}
+	chirps := []Chirp{}
+	for _, dbChirp := range dbChirps {
+		chirps = append(chirps, chirpFromDB(dbChirp))
+	}
+	err := cfg.addQuotes(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get quoted chirps", err)
+		return
+	}
+
 	sortType := r.URL.Query().Get("sort")
 	if sortType == "desc" {
 		sort.Slice(chirps, func(i, j int) bool {
@@ -80,5 +117,24 @@ func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Reque
 		})
 	}
 
+	if len(pinned) > 0 {
+		rank := map[uuid.UUID]int{}
+		for i, id := range pinned {
+			rank[id] = i
+		}
+		position := func(chirp Chirp) int {
+			if i, ok := rank[chirp.ID]; ok {
+				return i
+			}
+			return len(pinned)
+		}
+		sort.SliceStable(chirps, func(i, j int) bool {
+			return position(chirps[i]) < position(chirps[j])
+		})
+		for i := range chirps {
+			_, chirps[i].Pinned = rank[chirps[i].ID]
+		}
+	}
+
 	respondWithJSON(w, http.StatusOK, chirps)
 }
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/realtime"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxConversationParticipants counts the creator too.
+	maxConversationParticipants = 10
+	// maxMessageLength counts characters, like the chirp length limit.
+	maxMessageLength = 1000
+)
+
+type Conversation struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	CreatedBy    uuid.UUID     `json:"created_by"`
+	Participants []Participant `json:"participants"`
+	UnreadCount  int64         `json:"unread_count"`
+}
+
+// Participant.LastReadAt is the read receipt: everyone's messages up to
+// then have been read.
+type Participant struct {
+	UserID     uuid.UUID `json:"user_id"`
+	JoinedAt   time.Time `json:"joined_at"`
+	LastReadAt time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+// handlerConversationsCreate starts a conversation between the user and
+// participant_ids. With a single other participant, the existing
+// one-to-one conversation is returned if there is one.
+func (cfg *apiConfig) handlerConversationsCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	others := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{accessToken.UserID: true}
+	for _, id := range params.ParticipantIDs {
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		respondWithError(w, http.StatusBadRequest, "A conversation needs someone else in it", nil)
+		return
+	}
+	if len(others)+1 > maxConversationParticipants {
+		respondWithError(w, http.StatusBadRequest, "Conversations can have at most "+strconv.Itoa(maxConversationParticipants)+" participants", nil)
+		return
+	}
+
+	for _, id := range others {
+		_, err := cfg.db.GetUser(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusBadRequest, "User "+id.String()+" doesn't exist", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+			return
+		}
+	}
+
+	if !cfg.checkNotBlocked(w, r, accessToken.UserID, others, "You can't message these users") {
+		return
+	}
+
+	if len(others) == 1 {
+		existing, err := cfg.db.FindDirectConversation(r.Context(), database.FindDirectConversationParams{
+			UserID:  accessToken.UserID,
+			OtherID: others[0],
+		})
+		if err == nil {
+			cfg.respondWithConversation(w, r, http.StatusOK, existing.ID, accessToken.UserID)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't find conversation", err)
+			return
+		}
+	}
+
+	conversation, err := cfg.createConversation(r.Context(), accessToken.UserID, others)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+		return
+	}
+	cfg.respondWithConversation(w, r, http.StatusCreated, conversation.ID, accessToken.UserID)
+}
+
+func (cfg *apiConfig) createConversation(ctx context.Context, creatorID uuid.UUID, others []uuid.UUID) (database.Conversation, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Conversation{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	conversation, err := qtx.CreateConversation(ctx, creatorID)
+	if err != nil {
+		return database.Conversation{}, err
+	}
+	for _, userID := range append([]uuid.UUID{creatorID}, others...) {
+		err = qtx.AddConversationParticipant(ctx, database.AddConversationParticipantParams{
+			ConversationID: conversation.ID,
+			UserID:         userID,
+		})
+		if err != nil {
+			return database.Conversation{}, err
+		}
+	}
+	return conversation, tx.Commit()
+}
+
+// handlerConversationsList returns the user's conversations, most
+// recently active first.
+func (cfg *apiConfig) handlerConversationsList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	rows, err := cfg.db.ListConversations(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversations", err)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	participants, err := cfg.conversationParticipants(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get participants", err)
+		return
+	}
+
+	conversations := []Conversation{}
+	for _, row := range rows {
+		conversations = append(conversations, Conversation{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			CreatedBy:    row.CreatedBy,
+			Participants: participants[row.ID],
+			UnreadCount:  row.UnreadCount,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+func (cfg *apiConfig) handlerConversationsGet(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid conversation ID", err)
+		return
+	}
+	cfg.respondWithConversation(w, r, http.StatusOK, conversationID, accessToken.UserID)
+}
+
+// respondWithConversation responds with a 404 if userID isn't in the
+// conversation, so its existence isn't given away.
+func (cfg *apiConfig) respondWithConversation(w http.ResponseWriter, r *http.Request, code int, conversationID, userID uuid.UUID) {
+	row, err := cfg.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		ID:     conversationID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Conversation not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversation", err)
+		return
+	}
+	participants, err := cfg.conversationParticipants(r.Context(), []uuid.UUID{row.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get participants", err)
+		return
+	}
+
+	respondWithJSON(w, code, Conversation{
+		ID:           row.ID,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+		CreatedBy:    row.CreatedBy,
+		Participants: participants[row.ID],
+		UnreadCount:  row.UnreadCount,
+	})
+}
+
+func (cfg *apiConfig) conversationParticipants(ctx context.Context, conversationIDs []uuid.UUID) (map[uuid.UUID][]Participant, error) {
+	rows, err := cfg.db.ListConversationParticipants(ctx, conversationIDs)
+	if err != nil {
+		return nil, err
+	}
+	participants := map[uuid.UUID][]Participant{}
+	for _, row := range rows {
+		participants[row.ConversationID] = append(participants[row.ConversationID], Participant{
+			UserID:     row.UserID,
+			JoinedAt:   row.JoinedAt,
+			LastReadAt: row.LastReadAt,
+		})
+	}
+	return participants, nil
+}
+
+// participantConversation loads the conversation in the path along with
+// its participants, responding with a 404 if the user isn't one of them.
+func (cfg *apiConfig) participantConversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, []Participant, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid conversation ID", err)
+		return uuid.Nil, nil, false
+	}
+	participants, err := cfg.conversationParticipants(r.Context(), []uuid.UUID{conversationID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get participants", err)
+		return uuid.Nil, nil, false
+	}
+	for _, participant := range participants[conversationID] {
+		if participant.UserID == userID {
+			return conversationID, participants[conversationID], true
+		}
+	}
+	respondWithError(w, http.StatusNotFound, "Conversation not found", nil)
+	return uuid.Nil, nil, false
+}
+
+// handlerMessagesList returns a page of messages, newest first. Passing
+// the ID of the last message as ?before= gets the next page.
+func (cfg *apiConfig) handlerMessagesList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	const defaultLimit, maxLimit = 50, 200
+
+	conversationID, _, ok := cfg.participantConversation(w, r, accessToken.UserID)
+	if !ok {
+		return
+	}
+
+	limit := defaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(parsed, maxLimit)
+	}
+	before := uuid.NullUUID{}
+	if value := r.URL.Query().Get("before"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid before", err)
+			return
+		}
+		before = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	dbMessages, err := cfg.db.ListMessages(r.Context(), database.ListMessagesParams{
+		ConversationID: conversationID,
+		Before:         before,
+		MaxResults:     int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get messages", err)
+		return
+	}
+
+	messages := []Message{}
+	for _, dbMessage := range dbMessages {
+		messages = append(messages, messageFromDB(dbMessage))
+	}
+	respondWithJSON(w, http.StatusOK, messages)
+}
+
+// handlerMessagesCreate sends a message. Blocks are checked again here,
+// since one may have been added after the conversation started.
+func (cfg *apiConfig) handlerMessagesCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	conversationID, participants, ok := cfg.participantConversation(w, r, accessToken.UserID)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		respondWithError(w, http.StatusBadRequest, "Message is empty", nil)
+		return
+	}
+	if utf8.RuneCountInString(params.Body) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "Message is too long", nil)
+		return
+	}
+	cleanedBody, err := validateChirp(params.Body, 0)
+	if err != nil {
+		respondWithInvalidField(w, "body", err)
+		return
+	}
+
+	others := []uuid.UUID{}
+	for _, participant := range participants {
+		if participant.UserID != accessToken.UserID {
+			others = append(others, participant.UserID)
+		}
+	}
+	if !cfg.checkNotBlocked(w, r, accessToken.UserID, others, "You can't message these users") {
+		return
+	}
+
+	dbMessage, err := cfg.createMessage(r.Context(), conversationID, accessToken.UserID, cleanedBody)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	message := messageFromDB(dbMessage)
+	for _, userID := range others {
+		cfg.publishRealtime(r.Context(), realtime.NotificationsTopic(userID), "message.created", message)
+	}
+	respondWithJSON(w, http.StatusCreated, message)
+}
+
+// createMessage also moves the conversation to the top of everyone's list
+// and marks it read up to the new message for the sender.
+func (cfg *apiConfig) createMessage(ctx context.Context, conversationID, senderID uuid.UUID, body string) (database.Message, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Message{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	message, err := qtx.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	err = qtx.TouchConversation(ctx, conversationID)
+	if err != nil {
+		return database.Message{}, err
+	}
+	_, err = qtx.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:         message.CreatedAt,
+		ConversationID: conversationID,
+		UserID:         senderID,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	return message, tx.Commit()
+}
+
+// handlerConversationsRead marks the conversation read up to message_id,
+// or up to the newest message if none is given. The other participants
+// see the receipt as a message.read event.
+func (cfg *apiConfig) handlerConversationsRead(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		MessageID *uuid.UUID `json:"message_id"`
+	}
+
+	conversationID, participants, ok := cfg.participantConversation(w, r, accessToken.UserID)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	var readUpTo database.Message
+	if params.MessageID != nil {
+		readUpTo, err = cfg.db.GetMessage(r.Context(), database.GetMessageParams{
+			ID:             *params.MessageID,
+			ConversationID: conversationID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusBadRequest, "Message not found in this conversation", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get message", err)
+			return
+		}
+	} else {
+		newest, err := cfg.db.ListMessages(r.Context(), database.ListMessagesParams{
+			ConversationID: conversationID,
+			MaxResults:     1,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get messages", err)
+			return
+		}
+		if len(newest) == 0 {
+			cfg.respondWithConversation(w, r, http.StatusOK, conversationID, accessToken.UserID)
+			return
+		}
+		readUpTo = newest[0]
+	}
+
+	participant, err := cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ReadAt:         readUpTo.CreatedAt,
+		ConversationID: conversationID,
+		UserID:         accessToken.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation read", err)
+		return
+	}
+
+	type receipt struct {
+		ConversationID uuid.UUID `json:"conversation_id"`
+		UserID         uuid.UUID `json:"user_id"`
+		LastReadAt     time.Time `json:"last_read_at"`
+	}
+	for _, other := range participants {
+		if other.UserID == accessToken.UserID {
+			continue
+		}
+		cfg.publishRealtime(r.Context(), realtime.NotificationsTopic(other.UserID), "message.read", receipt{
+			ConversationID: conversationID,
+			UserID:         accessToken.UserID,
+			LastReadAt:     participant.LastReadAt,
+		})
+	}
+
+	cfg.respondWithConversation(w, r, http.StatusOK, conversationID, accessToken.UserID)
+}
+
+func messageFromDB(message database.Message) Message {
+	return Message{
+		ID:             message.ID,
+		CreatedAt:      message.CreatedAt,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// startTestConversation has accessToken's user start a conversation with
+// others.
+func startTestConversation(t *testing.T, cfg *apiConfig, accessToken auth.AccessToken, others ...uuid.UUID) Conversation {
+	t.Helper()
+	body, err := json.Marshal(map[string][]uuid.UUID{"participant_ids": others})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serveAuthed(cfg.handlerConversationsCreate, accessToken, http.MethodPost, "/api/conversations", string(body))
+	if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+		t.Fatalf("Couldn't start conversation: status = %d; body: %s", w.Code, w.Body)
+	}
+	var conversation Conversation
+	err = json.NewDecoder(w.Body).Decode(&conversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conversation
+}
+
+// sendTestMessage sends body to the conversation and returns the response.
+func sendTestMessage(cfg *apiConfig, accessToken auth.AccessToken, conversationID uuid.UUID, body string) (Message, int) {
+	params, _ := json.Marshal(map[string]string{"body": body})
+	w := serveAuthed(cfg.handlerMessagesCreate, accessToken, http.MethodPost, "/api/conversations/"+conversationID.String()+"/messages",
+		string(params), "conversationID", conversationID.String())
+	var message Message
+	json.NewDecoder(w.Body).Decode(&message)
+	return message, w.Code
+}
+
+// getTestConversation returns the conversation as accessToken's user sees it.
+func getTestConversation(t *testing.T, cfg *apiConfig, accessToken auth.AccessToken, conversationID uuid.UUID) Conversation {
+	t.Helper()
+	w := serveAuthed(cfg.handlerConversationsGet, accessToken, http.MethodGet, "/api/conversations/"+conversationID.String(), "",
+		"conversationID", conversationID.String())
+	expectStatus(t, w, http.StatusOK)
+	var conversation Conversation
+	err := json.NewDecoder(w.Body).Decode(&conversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conversation
+}
+
+func TestHandlerConversationsCreateReusesDirectConversation(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	aliceToken, bobToken := loginTestUser(t, cfg, alice), loginTestUser(t, cfg, bob)
+
+	first := startTestConversation(t, cfg, aliceToken, bob.ID)
+	if len(first.Participants) != 2 {
+		t.Fatalf("participants = %+v, want alice and bob", first.Participants)
+	}
+	again := startTestConversation(t, cfg, bobToken, alice.ID, alice.ID)
+	if again.ID != first.ID {
+		t.Errorf("bob got conversation %s, want the existing %s", again.ID, first.ID)
+	}
+
+	w := serveAuthed(cfg.handlerConversationsCreate, aliceToken, http.MethodPost, "/api/conversations", `{"participant_ids": ["`+alice.ID.String()+`"]}`)
+	expectStatus(t, w, http.StatusBadRequest)
+	w = serveAuthed(cfg.handlerConversationsCreate, aliceToken, http.MethodPost, "/api/conversations", `{"participant_ids": ["`+uuid.NewString()+`"]}`)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestHandlerMessagesCreate(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob, carol := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob"), createTestUser(t, cfg, "carol")
+	aliceToken := loginTestUser(t, cfg, alice)
+	conversation := startTestConversation(t, cfg, aliceToken, bob.ID)
+
+	message, status := sendTestMessage(cfg, aliceToken, conversation.ID, "what a kerfuffle")
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", status, http.StatusCreated)
+	}
+	if message.Body != "what a ****" || message.SenderID != alice.ID {
+		t.Errorf("message = %+v, want alice's censored message", message)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"empty", "  ", http.StatusBadRequest},
+		// Three bytes a character, so only the character count fits.
+		{"at the limit in characters", strings.Repeat("語", maxMessageLength), http.StatusCreated},
+		{"over the limit", strings.Repeat("語", maxMessageLength+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, status := sendTestMessage(cfg, aliceToken, conversation.ID, tt.body)
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+
+	// Outsiders can't tell the conversation exists.
+	_, status = sendTestMessage(cfg, loginTestUser(t, cfg, carol), conversation.ID, "hi")
+	if status != http.StatusNotFound {
+		t.Errorf("outsider: status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestHandlerMessagesListBefore(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob, carol := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob"), createTestUser(t, cfg, "carol")
+	aliceToken := loginTestUser(t, cfg, alice)
+	conversation := startTestConversation(t, cfg, aliceToken, bob.ID)
+	other := startTestConversation(t, cfg, aliceToken, carol.ID)
+
+	var sent []Message
+	for _, body := range []string{"one", "two", "three"} {
+		message, _ := sendTestMessage(cfg, aliceToken, conversation.ID, body)
+		sent = append(sent, message)
+	}
+	elsewhere, _ := sendTestMessage(cfg, aliceToken, other.ID, "elsewhere")
+
+	list := func(query string) []Message {
+		t.Helper()
+		w := serveAuthed(cfg.handlerMessagesList, aliceToken, http.MethodGet, "/api/conversations/"+conversation.ID.String()+"/messages"+query, "",
+			"conversationID", conversation.ID.String())
+		expectStatus(t, w, http.StatusOK)
+		var messages []Message
+		err := json.NewDecoder(w.Body).Decode(&messages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return messages
+	}
+
+	page := list("?limit=2")
+	if len(page) != 2 || page[0].ID != sent[2].ID || page[1].ID != sent[1].ID {
+		t.Fatalf("first page = %+v, want three and two", page)
+	}
+	page = list("?limit=2&before=" + page[1].ID.String())
+	if len(page) != 1 || page[0].ID != sent[0].ID {
+		t.Errorf("second page = %+v, want one", page)
+	}
+	if page := list("?before=" + elsewhere.ID.String()); len(page) != 0 {
+		t.Errorf("before from another conversation returned %+v, want nothing", page)
+	}
+}
+
+func TestHandlerConversationsReadReceipts(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob, carol := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob"), createTestUser(t, cfg, "carol")
+	aliceToken, bobToken := loginTestUser(t, cfg, alice), loginTestUser(t, cfg, bob)
+	conversation := startTestConversation(t, cfg, aliceToken, bob.ID)
+	other := startTestConversation(t, cfg, aliceToken, carol.ID)
+
+	first, _ := sendTestMessage(cfg, aliceToken, conversation.ID, "one")
+	second, _ := sendTestMessage(cfg, aliceToken, conversation.ID, "two")
+	elsewhere, _ := sendTestMessage(cfg, aliceToken, other.ID, "elsewhere")
+	if got := getTestConversation(t, cfg, bobToken, conversation.ID).UnreadCount; got != 2 {
+		t.Fatalf("bob's unread count = %d, want 2", got)
+	}
+	// Sending marks the conversation read for the sender.
+	if got := getTestConversation(t, cfg, aliceToken, conversation.ID).UnreadCount; got != 0 {
+		t.Errorf("alice's unread count = %d, want 0", got)
+	}
+
+	read := func(body string) *Conversation {
+		t.Helper()
+		w := serveAuthed(cfg.handlerConversationsRead, bobToken, http.MethodPost, "/api/conversations/"+conversation.ID.String()+"/read", body,
+			"conversationID", conversation.ID.String())
+		if w.Code != http.StatusOK {
+			return nil
+		}
+		var conversation Conversation
+		json.NewDecoder(w.Body).Decode(&conversation)
+		return &conversation
+	}
+
+	if got := read(`{"message_id": "` + elsewhere.ID.String() + `"}`); got != nil {
+		t.Errorf("reading up to another conversation's message succeeded: %+v", got)
+	}
+	got := read(`{"message_id": "` + first.ID.String() + `"}`)
+	if got == nil || got.UnreadCount != 1 {
+		t.Fatalf("after reading the first message: %+v, want 1 unread", got)
+	}
+	for _, participant := range got.Participants {
+		if participant.UserID == bob.ID && !participant.LastReadAt.Equal(first.CreatedAt) {
+			t.Errorf("bob's last_read_at = %s, want %s", participant.LastReadAt, first.CreatedAt)
+		}
+	}
+
+	if got := read(""); got == nil || got.UnreadCount != 0 {
+		t.Fatalf("after reading everything: %+v, want 0 unread", got)
+	}
+	// Receipts only move forward.
+	got = read(`{"message_id": "` + first.ID.String() + `"}`)
+	for _, participant := range got.Participants {
+		if participant.UserID == bob.ID && !participant.LastReadAt.Equal(second.CreatedAt) {
+			t.Errorf("bob's last_read_at moved back to %s, want %s", participant.LastReadAt, second.CreatedAt)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerEntitlementsGet(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type response struct {
+		Tier         entitlements.Tier                              `json:"tier"`
+		Entitlements map[entitlements.Capability]entitlements.Grant `json:"entitlements"`
+	}
+
+	tier, err := cfg.userTier(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get subscription", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Tier:         tier,
+		Entitlements: cfg.entitlements.For(tier),
+	})
+}
+
+// userTier returns the membership tier that decides the user's
+// entitlements.
+func (cfg *apiConfig) userTier(ctx context.Context, userID uuid.UUID) (entitlements.Tier, error) {
+	isChirpyRed, err := cfg.isChirpyRed(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if isChirpyRed {
+		return entitlements.TierRed, nil
+	}
+	return entitlements.TierFree, nil
+}
+
+// entitlement returns what the user gets for capability.
+func (cfg *apiConfig) entitlement(ctx context.Context, userID uuid.UUID, capability entitlements.Capability) (entitlements.Grant, error) {
+	tier, err := cfg.userTier(ctx, userID)
+	if err != nil {
+		return entitlements.Grant{}, err
+	}
+	return cfg.entitlements.Check(tier, capability), nil
+}
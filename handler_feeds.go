@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/feed"
+	"github.com/MechamJonathan/chirpy/internal/hashtag"
+	"github.com/google/uuid"
+)
+
+const (
+	feedEntries    = 50
+	feedTitleRunes = 60
+)
+
+// handlerFeedUser serves a user's chirps as feed.atom or feed.rss.
+func (cfg *apiConfig) handlerFeedUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid user ID", err)
+		return
+	}
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	state, err := cfg.db.GetChirpsState(r.Context(), database.GetChirpsStateParams{
+		AuthorID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from database", err)
+		return
+	}
+	// The feed shows the user's name, so changing it changes the feed.
+	updated := user.UpdatedAt
+	if state.LastChanged.After(updated) {
+		updated = state.LastChanged
+	}
+	if cfg.feedNotModified(w, r, updated, state.ChirpCount, userID.String()) {
+		return
+	}
+
+	dbChirps, err := cfg.db.ListRecentChirpsByAuthor(r.Context(), database.ListRecentChirpsByAuthorParams{
+		UserID: userID,
+		Limit:  feedEntries,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from database", err)
+		return
+	}
+
+	name := authorName(user)
+	cfg.respondWithFeed(w, r, feed.Feed{
+		Title:       "Chirps by " + name,
+		Description: "The latest chirps by " + name + " on Chirpy",
+		ID:          cfg.publicURL + "/users/" + userID.String(),
+		Link:        cfg.publicURL + "/api/chirps?author_id=" + userID.String() + "&sort=desc",
+		Updated:     updated,
+	}, dbChirps, map[uuid.UUID]database.User{userID: user})
+}
+
+// handlerFeedHashtag serves the chirps with a hashtag as feed.atom or
+// feed.rss.
+func (cfg *apiConfig) handlerFeedHashtag(w http.ResponseWriter, r *http.Request) {
+	tag := hashtag.Normalize(r.PathValue("tag"))
+	if !slices.Equal(hashtag.Extract("#"+tag), []string{tag}) {
+		respondWithError(w, http.StatusNotFound, "Invalid hashtag", nil)
+		return
+	}
+
+	state, err := cfg.db.GetChirpsState(r.Context(), database.GetChirpsStateParams{
+		Tag: sql.NullString{String: tag, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from database", err)
+		return
+	}
+	updated := state.LastChanged
+	if cfg.feedNotModified(w, r, updated, state.ChirpCount, tag) {
+		return
+	}
+
+	dbChirps, err := cfg.db.ListRecentChirpsByHashtag(r.Context(), database.ListRecentChirpsByHashtagParams{
+		Tag:        tag,
+		MaxResults: feedEntries,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from database", err)
+		return
+	}
+
+	authorIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		authorIDs = append(authorIDs, dbChirp.UserID)
+	}
+	dbUsers, err := cfg.db.GetUsersByIDs(r.Context(), authorIDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get authors", err)
+		return
+	}
+	authors := map[uuid.UUID]database.User{}
+	for _, user := range dbUsers {
+		authors[user.ID] = user
+	}
+
+	cfg.respondWithFeed(w, r, feed.Feed{
+		Title:       "#" + tag + " on Chirpy",
+		Description: "The latest chirps tagged #" + tag + " on Chirpy",
+		ID:          cfg.publicURL + "/hashtags/" + tag,
+		Link:        cfg.publicURL + "/api/stream/chirps?hashtag=" + tag,
+		Updated:     updated,
+	}, dbChirps, authors)
+}
+
+// feedNotModified sets the feed's validators and answers a conditional
+// GET with 304 if it can, before any chirps are loaded. The validators
+// come from how many chirps the feed is drawn from and when they last
+// changed, which only moves forward: creating, editing and deleting a
+// chirp all move it. key tells feeds apart.
+func (cfg *apiConfig) feedNotModified(w http.ResponseWriter, r *http.Request, updated time.Time, chirpCount int64, key string) bool {
+	etag := feed.ETag(r.URL.Path, key, strconv.FormatInt(chirpCount, 10), updated.UTC().Format(time.RFC3339Nano))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if feed.NotModified(r.Header, etag, updated) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// respondWithFeed adds dbChirps, which are newest first, to f and writes
+// it in the format the path asks for. feedNotModified has already set the
+// validators.
+func (cfg *apiConfig) respondWithFeed(w http.ResponseWriter, r *http.Request, f feed.Feed, dbChirps []database.Chirp, authors map[uuid.UUID]database.User) {
+	rss := strings.HasSuffix(r.URL.Path, ".rss")
+	f.SelfLink = cfg.publicURL + r.URL.Path
+
+	for _, dbChirp := range dbChirps {
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:        "urn:uuid:" + dbChirp.ID.String(),
+			Title:     feed.Title(dbChirp.Body, feedTitleRunes),
+			Link:      cfg.publicURL + "/api/chirps/" + dbChirp.ID.String(),
+			Content:   dbChirp.Body,
+			Author:    authorName(authors[dbChirp.UserID]),
+			Published: dbChirp.CreatedAt,
+			Updated:   dbChirp.UpdatedAt,
+		})
+	}
+
+	render, contentType := f.Atom, "application/atom+xml; charset=utf-8"
+	if rss {
+		render, contentType = f.RSS, "application/rss+xml; charset=utf-8"
+	}
+	body, err := render()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't render feed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// authorName is how a user is shown in a feed. Their email is private, so
+// users without a username are shown by ID.
+func authorName(user database.User) string {
+	if user.Username.Valid {
+		return "@" + user.Username.String
+	}
+	return "user " + user.ID.String()
+}
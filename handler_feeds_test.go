@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+)
+
+// getTestFeed fetches an Atom feed, conditionally if etag isn't empty.
+func getTestFeed(h http.HandlerFunc, path, name, value, etag string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.SetPathValue(name, value)
+	if etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+// getTestFeedSince fetches an Atom feed with only If-Modified-Since, as
+// feed readers that don't keep ETags do.
+func getTestFeedSince(h http.HandlerFunc, path, name, value, since string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.SetPathValue(name, value)
+	r.Header.Set("If-Modified-Since", since)
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+// feedEntryIDs returns the IDs of the entries in an Atom feed.
+func feedEntryIDs(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var atom struct {
+		Entries []struct {
+			ID string `xml:"id"`
+		} `xml:"entry"`
+	}
+	err := xml.Unmarshal(w.Body.Bytes(), &atom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, entry := range atom.Entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestHandlerFeedUserValidators(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	first := createTestChirp(t, cfg, alice, "first")
+	createTestChirp(t, cfg, bob, "not alice's")
+	path := "/users/" + alice.ID.String() + "/feed.atom"
+	get := func(etag string) *httptest.ResponseRecorder {
+		return getTestFeed(cfg.handlerFeedUser, path, "userID", alice.ID.String(), etag)
+	}
+
+	w := get("")
+	expectStatus(t, w, http.StatusOK)
+	if ids := feedEntryIDs(t, w); len(ids) != 1 || ids[0] != "urn:uuid:"+first.ID.String() {
+		t.Fatalf("entries = %v, want alice's chirp", ids)
+	}
+	etag := w.Header().Get("ETag")
+	expectStatus(t, get(etag), http.StatusNotModified)
+
+	second := createTestChirp(t, cfg, alice, "second")
+	w = get(etag)
+	expectStatus(t, w, http.StatusOK)
+	if ids := feedEntryIDs(t, w); len(ids) != 2 || ids[0] != "urn:uuid:"+second.ID.String() {
+		t.Errorf("entries = %v, want the new chirp first", ids)
+	}
+	etag = w.Header().Get("ETag")
+
+	// Deleting a chirp changes the feed even though nothing was updated.
+	_, err := cfg.db.DeleteChirpByID(context.Background(), database.DeleteChirpByIDParams{ID: second.ID, UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, get(etag), http.StatusOK)
+}
+
+func TestHandlerFeedHashtag(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	tagged := createTestChirp(t, cfg, alice, "learning #Go today")
+	createTestChirp(t, cfg, bob, "#golang isn't #go")
+	createTestChirp(t, cfg, bob, "no tags, just go")
+	get := func(tag, etag string) *httptest.ResponseRecorder {
+		return getTestFeed(cfg.handlerFeedHashtag, "/hashtags/"+tag+"/feed.atom", "tag", tag, etag)
+	}
+
+	w := get("go", "")
+	expectStatus(t, w, http.StatusOK)
+	ids := feedEntryIDs(t, w)
+	if len(ids) != 2 || ids[1] != "urn:uuid:"+tagged.ID.String() {
+		t.Errorf("entries = %v, want the two chirps tagged #go, newest first", ids)
+	}
+	expectStatus(t, get("go", w.Header().Get("ETag")), http.StatusNotModified)
+
+	w = get("rust", "")
+	expectStatus(t, w, http.StatusOK)
+	if ids := feedEntryIDs(t, w); len(ids) != 0 {
+		t.Errorf("entries = %v, want none", ids)
+	}
+	expectStatus(t, get("not-a-tag", ""), http.StatusNotFound)
+}
+
+func TestHandlerFeedLastModifiedAfterDelete(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	alice := createTestUser(t, cfg, "alice")
+	older := createTestChirp(t, cfg, alice, "older #go")
+	newer := createTestChirp(t, cfg, alice, "newer #go")
+	// Move everything an hour back, so deletes happen in a later second
+	// than the Last-Modified they're compared with.
+	for _, query := range []string{
+		"UPDATE chirps SET created_at = created_at - INTERVAL '1 hour', updated_at = updated_at - INTERVAL '1 hour'",
+		"UPDATE users SET updated_at = updated_at - INTERVAL '1 hour'",
+	} {
+		_, err := cfg.dbConn.Exec(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	feeds := []struct {
+		name  string
+		h     http.HandlerFunc
+		path  string
+		param string
+		value string
+	}{
+		{name: "user", h: cfg.handlerFeedUser, path: "/users/" + alice.ID.String() + "/feed.atom", param: "userID", value: alice.ID.String()},
+		{name: "hashtag", h: cfg.handlerFeedHashtag, path: "/hashtags/go/feed.atom", param: "tag", value: "go"},
+	}
+	lastModified := map[string]time.Time{}
+	for _, f := range feeds {
+		w := getTestFeed(f.h, f.path, f.param, f.value, "")
+		expectStatus(t, w, http.StatusOK)
+		lastModified[f.name], _ = http.ParseTime(w.Header().Get("Last-Modified"))
+	}
+
+	// Deleting an older chirp leaves the newest updated_at where it was.
+	_, err := cfg.db.DeleteChirpByID(ctx, database.DeleteChirpByIDParams{ID: older.ID, UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range feeds {
+		w := getTestFeedSince(f.h, f.path, f.param, f.value, lastModified[f.name].Format(http.TimeFormat))
+		expectStatus(t, w, http.StatusOK)
+		updated, _ := http.ParseTime(w.Header().Get("Last-Modified"))
+		if !updated.After(lastModified[f.name]) {
+			t.Errorf("%s feed: Last-Modified = %v after a delete, want later than %v", f.name, updated, lastModified[f.name])
+		}
+		lastModified[f.name] = updated
+		expectStatus(t, getTestFeedSince(f.h, f.path, f.param, f.value, updated.Format(http.TimeFormat)), http.StatusNotModified)
+	}
+
+	// Deleting the newest chirp doesn't move it backwards.
+	_, err = cfg.db.DeleteChirpByID(ctx, database.DeleteChirpByIDParams{ID: newer.ID, UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range feeds {
+		w := getTestFeed(f.h, f.path, f.param, f.value, "")
+		expectStatus(t, w, http.StatusOK)
+		updated, _ := http.ParseTime(w.Header().Get("Last-Modified"))
+		if updated.Before(lastModified[f.name]) {
+			t.Errorf("%s feed: Last-Modified moved back from %v to %v", f.name, lastModified[f.name], updated)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"database/sql"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/notification"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// handlerFollowsCreate follows the user in the path and tells them with a
+// user.followed webhook event and notification.
+func (cfg *apiConfig) handlerFollowsCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid user ID", err)
+		return
+	}
+	if followeeID == accessToken.UserID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	_, err = cfg.db.GetUser(r.Context(), followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	if !cfg.checkNotBlocked(w, r, accessToken.UserID, []uuid.UUID{followeeID}, "You can't follow this user") {
+		return
+	}
+
+	created, err := cfg.db.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: accessToken.UserID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+		return
+	}
+
+	// Following someone twice is a no-op, and doesn't notify them again.
+	if created == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	follow := Follow{
+		FollowerID: accessToken.UserID,
+		FolloweeID: followeeID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	cfg.publishEvent(r.Context(), followeeID, webhook.EventUserFollowed, follow)
+	cfg.notify(r.Context(), followeeID, accessToken.UserID, notification.TypeFollow, uuid.NullUUID{})
+	cfg.federateFollow(r.Context(), accessToken.UserID, followeeID, true)
+
+	respondWithJSON(w, http.StatusCreated, follow)
+}
+
+func (cfg *apiConfig) handlerFollowsDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid user ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: accessToken.UserID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "You don't follow this user", nil)
+		return
+	}
+	cfg.federateFollow(r.Context(), accessToken.UserID, followeeID, false)
+
+	w.WriteHeader(http.StatusNoContent)
+}
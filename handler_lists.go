@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/google/uuid"
+)
+
+const (
+	maxListNameLength = 100
+	maxListMembers    = 500
+)
+
+type List struct {
+	ID        uuid.UUID   `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	UserID    uuid.UUID   `json:"user_id"`
+	Name      string      `json:"name"`
+	Private   bool        `json:"private"`
+	MemberIDs []uuid.UUID `json:"member_ids"`
+}
+
+func (cfg *apiConfig) handlerListsCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		Name    string `json:"name"`
+		Private bool   `json:"private"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	name, err := parseListName(params.Name)
+	if err != nil {
+		respondWithInvalidField(w, "name", err)
+		return
+	}
+
+	list, err := cfg.db.CreateList(r.Context(), database.CreateListParams{
+		UserID:  accessToken.UserID,
+		Name:    name,
+		Private: params.Private,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create list", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, listFromDB(list, nil))
+}
+
+// handlerListsForUser returns a user's lists. Private ones are only
+// included for the user themselves.
+func (cfg *apiConfig) handlerListsForUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid user ID", err)
+		return
+	}
+	accessToken, ok, err := cfg.optionalAccessToken(r, oauth.ScopeChirpsRead)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	dbLists, err := cfg.db.ListLists(r.Context(), database.ListListsParams{
+		UserID:         userID,
+		IncludePrivate: ok && accessToken.UserID == userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get lists", err)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(dbLists))
+	for _, dbList := range dbLists {
+		ids = append(ids, dbList.ID)
+	}
+	members, err := cfg.listMembers(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list members", err)
+		return
+	}
+
+	lists := []List{}
+	for _, dbList := range dbLists {
+		lists = append(lists, listFromDB(dbList, members[dbList.ID]))
+	}
+	respondWithJSON(w, http.StatusOK, lists)
+}
+
+func (cfg *apiConfig) handlerListsGet(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+	members, err := cfg.listMembers(r.Context(), []uuid.UUID{list.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list members", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, listFromDB(list, members[list.ID]))
+}
+
+// handlerListsChirps is the list's timeline: chirps by its members.
+func (cfg *apiConfig) handlerListsChirps(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+	members, err := cfg.listMembers(r.Context(), []uuid.UUID{list.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list members", err)
+		return
+	}
+	dbChirps, err := cfg.db.GetChirpsByAuthors(r.Context(), members[list.ID])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from database", err)
+		return
+	}
+
+	cfg.respondWithChirps(w, r, dbChirps, nil)
+}
+
+// visibleList loads the list in the path. A private list is a 404 to
+// everyone but its owner.
+func (cfg *apiConfig) visibleList(w http.ResponseWriter, r *http.Request) (database.List, bool) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid list ID", err)
+		return database.List{}, false
+	}
+	accessToken, ok, err := cfg.optionalAccessToken(r, oauth.ScopeChirpsRead)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return database.List{}, false
+	}
+
+	list, err := cfg.db.GetList(r.Context(), listID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && list.Private && (!ok || accessToken.UserID != list.UserID)) {
+		respondWithError(w, http.StatusNotFound, "List not found", err)
+		return database.List{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list", err)
+		return database.List{}, false
+	}
+	return list, true
+}
+
+// ownedList loads the list in the path, responding with a 404 if it isn't
+// the user's.
+func (cfg *apiConfig) ownedList(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.List, bool) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid list ID", err)
+		return database.List{}, false
+	}
+	list, err := cfg.db.GetList(r.Context(), listID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && list.UserID != userID) {
+		respondWithError(w, http.StatusNotFound, "List not found", err)
+		return database.List{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list", err)
+		return database.List{}, false
+	}
+	return list, true
+}
+
+// handlerListsUpdate renames the list or changes whether it's private.
+// Fields left out keep their value.
+func (cfg *apiConfig) handlerListsUpdate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		Name    *string `json:"name"`
+		Private *bool   `json:"private"`
+	}
+
+	list, ok := cfg.ownedList(w, r, accessToken.UserID)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	name := list.Name
+	if params.Name != nil {
+		name, err = parseListName(*params.Name)
+		if err != nil {
+			respondWithInvalidField(w, "name", err)
+			return
+		}
+	}
+	private := list.Private
+	if params.Private != nil {
+		private = *params.Private
+	}
+
+	updated, err := cfg.db.UpdateList(r.Context(), database.UpdateListParams{
+		ID:      list.ID,
+		UserID:  accessToken.UserID,
+		Name:    name,
+		Private: private,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update list", err)
+		return
+	}
+	members, err := cfg.listMembers(r.Context(), []uuid.UUID{list.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list members", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, listFromDB(updated, members[list.ID]))
+}
+
+func (cfg *apiConfig) handlerListsDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid list ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteList(r.Context(), database.DeleteListParams{
+		ID:     listID,
+		UserID: accessToken.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete list", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "List not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerListMembersCreate adds {"user_id": ...} to the list. Being added
+// to a list doesn't notify anyone.
+func (cfg *apiConfig) handlerListMembersCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	list, ok := cfg.ownedList(w, r, accessToken.UserID)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	_, err = cfg.db.GetUser(r.Context(), params.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "User doesn't exist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	count, err := cfg.db.CountListMembers(r.Context(), list.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count list members", err)
+		return
+	}
+	if count >= maxListMembers {
+		respondWithError(w, http.StatusBadRequest, "List is full", nil)
+		return
+	}
+
+	added, err := cfg.db.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: params.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add list member", err)
+		return
+	}
+	if added == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (cfg *apiConfig) handlerListMembersDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	list, ok := cfg.ownedList(w, r, accessToken.UserID)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid user ID", err)
+		return
+	}
+
+	removed, err := cfg.db.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove list member", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "User isn't on this list", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listMembers returns the member IDs of each list, in the order they were
+// added.
+func (cfg *apiConfig) listMembers(ctx context.Context, listIDs []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	rows, err := cfg.db.ListListMembers(ctx, listIDs)
+	if err != nil {
+		return nil, err
+	}
+	members := map[uuid.UUID][]uuid.UUID{}
+	for _, row := range rows {
+		members[row.ListID] = append(members[row.ListID], row.UserID)
+	}
+	return members, nil
+}
+
+func parseListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("List name is required")
+	}
+	if len(name) > maxListNameLength {
+		return "", errors.New("List name is too long")
+	}
+	return name, nil
+}
+
+func listFromDB(list database.List, members []uuid.UUID) List {
+	if members == nil {
+		members = []uuid.UUID{}
+	}
+	return List{
+		ID:        list.ID,
+		CreatedAt: list.CreatedAt,
+		UpdatedAt: list.UpdatedAt,
+		UserID:    list.UserID,
+		Name:      list.Name,
+		Private:   list.Private,
+		MemberIDs: members,
+	}
+}
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/MechamJonathan/chirpy/internal/auth"
 	"github.com/MechamJonathan/chirpy/internal/database"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password         string `json:"password"`
+		Email            string `json:"email"`
+		ExpiresInSeconds int    `json:"expires_in_seconds"`
 	}
 	type response struct {
 		User
@@ -25,66 +32,157 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithDecodeError(w, err)
 		return
 	}
 
-	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+	user, err := cfg.checkCredentials(r.Context(), clientIP(r), params.Email, params.Password)
+	var throttled errLoginThrottled
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later", nil)
 		return
 	}
-
-	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
-	if err != nil {
+	if errors.Is(err, errIncorrectCredentials) {
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
-
-	// Default to 1 hour
-	expiresIn := 60 * 60 // 1 hour in seconds
-
-	// Create the JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Subject:   user.ID.String(),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiresIn) * time.Second)),
-	})
-
-	// Sign the token
-	tokenString, err := token.SignedString([]byte(cfg.jwtSecret))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check credentials", err)
 		return
 	}
 
 	// create refresh token
-	refreshToken, err := auth.MakeRefreshToken()
+	sess := newSession(r)
+	sess.UserID = user.ID
+	refreshToken, err := cfg.issueRefreshToken(r.Context(), cfg.db, &sess)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't generate refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
 		return
 	}
 
-	expiresAt := time.Now().Add(60 * 24 * time.Hour) // 60 days
+	// Create the JWT token, tied to the new session. The token service
+	// clamps the requested lifetime to the server maximum.
+	expiresIn := time.Duration(params.ExpiresInSeconds) * time.Second
+	tokenString, err := cfg.makeAccessToken(sess, expiresIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create token", err)
+		return
+	}
 
-	err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		UserID:    user.ID,
-		ExpiresAt: expiresAt,
-	})
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't insert refresh token into database", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get subscription", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		User:         userFromDB(user, isChirpyRed),
 		Token:        tokenString,
 		RefreshToken: refreshToken,
 	})
 }
+
+var errIncorrectCredentials = errors.New("incorrect email or password")
+
+// errLoginThrottled means the account or client IP has failed too often
+// and must wait before trying again.
+type errLoginThrottled struct {
+	wait time.Duration
+}
+
+func (e errLoginThrottled) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.wait)
+}
+
+// checkCredentials verifies an email and password with brute-force
+// protection, upgrading the stored hash when it is outdated. Every place
+// that accepts a password should go through it.
+func (cfg *apiConfig) checkCredentials(ctx context.Context, ip, email, password string) (database.User, error) {
+	emailKey := "email:" + strings.ToLower(strings.TrimSpace(email))
+	ipKey := "ip:" + ip
+
+	wait, err := cfg.loginWait(ctx, emailKey, ipKey)
+	if err != nil {
+		return database.User{}, err
+	}
+	if wait > 0 {
+		return database.User{}, errLoginThrottled{wait: wait}
+	}
+
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		// Take as long as checking a real password would.
+		cfg.passwords.VerifyDummy(password)
+		cfg.recordLoginFailure(ctx, emailKey, ipKey, uuid.Nil, ip)
+		return database.User{}, fmt.Errorf("%w: %w", errIncorrectCredentials, err)
+	}
+
+	needsRehash, err := cfg.passwords.Verify(password, user.HashedPassword)
+	if err != nil {
+		cfg.recordLoginFailure(ctx, emailKey, ipKey, user.ID, ip)
+		return database.User{}, fmt.Errorf("%w: %w", errIncorrectCredentials, err)
+	}
+
+	// Only the account's counter is reset. Resetting the IP counter would
+	// let an attacker with one valid account keep guessing others.
+	err = cfg.loginEmailLimiter.Reset(ctx, emailKey)
+	if err != nil {
+		log.Printf("Couldn't reset login attempts for %s: %s", emailKey, err)
+	}
+
+	if needsRehash {
+		cfg.rehashPassword(ctx, user.ID, password)
+	}
+	return user, nil
+}
+
+// loginWait returns how long the client must wait before its next login
+// attempt, given the failures recorded against the account and the IP.
+func (cfg *apiConfig) loginWait(ctx context.Context, emailKey, ipKey string) (time.Duration, error) {
+	emailWait, err := cfg.loginEmailLimiter.Check(ctx, emailKey)
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := cfg.loginIPLimiter.Check(ctx, ipKey)
+	if err != nil {
+		return 0, err
+	}
+	return max(emailWait, ipWait), nil
+}
+
+// recordLoginFailure counts a failed login against the account and the IP
+// and audits any lock it causes. userID is uuid.Nil for unknown emails.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, emailKey, ipKey string, userID uuid.UUID, ip string) {
+	locked, err := cfg.loginEmailLimiter.Fail(ctx, emailKey)
+	if err != nil {
+		log.Printf("Couldn't record login failure for %s: %s", emailKey, err)
+	} else if locked {
+		cfg.recordAuditEvent(ctx, "login.locked", userID, ip, emailKey)
+	}
+
+	locked, err = cfg.loginIPLimiter.Fail(ctx, ipKey)
+	if err != nil {
+		log.Printf("Couldn't record login failure for %s: %s", ipKey, err)
+	} else if locked {
+		cfg.recordAuditEvent(ctx, "login.locked", uuid.Nil, ip, ipKey)
+	}
+}
+
+// rehashPassword upgrades a stored hash to the current scheme after the
+// user proved they know the password. Failing to upgrade doesn't fail the
+// login; it is retried on the next one.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashedPassword, err := cfg.passwords.Hash(password)
+	if err != nil {
+		log.Printf("Couldn't rehash password for user %s: %s", userID, err)
+		return
+	}
+	err = cfg.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		log.Printf("Couldn't store rehashed password for user %s: %s", userID, err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerLoginUnknownEmailLooksLikeWrongPassword(t *testing.T) {
+	cfg := newTestConfig(t)
+	createTestUser(t, cfg, "alice")
+
+	login := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		cfg.handlerLogin(w, r)
+		return w
+	}
+
+	wrongPassword := login(`{"email": "alice@example.com", "password": "wrong"}`)
+	expectStatus(t, wrongPassword, http.StatusUnauthorized)
+	unknownEmail := login(`{"email": "nobody@example.com", "password": "wrong"}`)
+	expectStatus(t, unknownEmail, http.StatusUnauthorized)
+	if wrongPassword.Body.String() != unknownEmail.Body.String() {
+		t.Errorf("bodies differ:\n%s\n%s", wrongPassword.Body, unknownEmail.Body)
+	}
+
+	ok := login(`{"email": "alice@example.com", "password": "correct horse battery staple"}`)
+	expectStatus(t, ok, http.StatusOK)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/notification"
+	"github.com/MechamJonathan/chirpy/internal/realtime"
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	ID         uuid.UUID   `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Type       string      `json:"type"`
+	ChirpID    *uuid.UUID  `json:"chirp_id,omitempty"`
+	ActorIDs   []uuid.UUID `json:"actor_ids"`
+	ActorCount int         `json:"actor_count"`
+	Summary    string      `json:"summary"`
+	Read       bool        `json:"read"`
+}
+
+// notify tells userID that actorID did something, unless either has
+// blocked the other. It's added to an unread notification of the same
+// group if there is one. Like
+// publishEvent, failures are logged rather than failing the request.
+func (cfg *apiConfig) notify(ctx context.Context, userID, actorID uuid.UUID, notificationType string, chirpID uuid.NullUUID) {
+	if userID == actorID {
+		return
+	}
+	blocked, err := cfg.db.HasBlockBetween(ctx, database.HasBlockBetweenParams{
+		UserID: userID,
+		Others: []uuid.UUID{actorID},
+	})
+	if err != nil {
+		log.Printf("Couldn't check blocks for %s notification for %s: %s", notificationType, userID, err)
+		return
+	}
+	if blocked {
+		return
+	}
+
+	n, added, err := cfg.addNotification(ctx, userID, actorID, notificationType, chirpID)
+	if err != nil {
+		log.Printf("Couldn't create %s notification for %s: %s", notificationType, userID, err)
+		return
+	}
+	if !added {
+		return
+	}
+
+	type event struct {
+		NotificationID uuid.UUID  `json:"notification_id"`
+		Type           string     `json:"type"`
+		ChirpID        *uuid.UUID `json:"chirp_id,omitempty"`
+		ActorID        uuid.UUID  `json:"actor_id"`
+	}
+	data := event{NotificationID: n.ID, Type: n.Type, ActorID: actorID}
+	if n.ChirpID.Valid {
+		data.ChirpID = &n.ChirpID.UUID
+	}
+	cfg.publishRealtime(ctx, realtime.NotificationsTopic(userID), "notification.created", data)
+}
+
+// addNotification reports false if the user turned the type off or the
+// actor is already part of the notification.
+func (cfg *apiConfig) addNotification(ctx context.Context, userID, actorID uuid.UUID, notificationType string, chirpID uuid.NullUUID) (database.Notification, bool, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Notification{}, false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	n, err := qtx.UpsertNotification(ctx, database.UpsertNotificationParams{
+		UserID:   userID,
+		Type:     notificationType,
+		GroupKey: notification.GroupKey(notificationType, chirpID.UUID),
+		ChirpID:  chirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Notification{}, false, nil
+	}
+	if err != nil {
+		return database.Notification{}, false, err
+	}
+
+	added, err := qtx.AddNotificationActor(ctx, database.AddNotificationActorParams{
+		NotificationID: n.ID,
+		ActorID:        actorID,
+	})
+	if err != nil {
+		return database.Notification{}, false, err
+	}
+	// Rolling back keeps a repeat, such as liking a chirp again, from
+	// moving the notification back to the top.
+	if added == 0 {
+		return database.Notification{}, false, nil
+	}
+	return n, true, tx.Commit()
+}
+
+// handlerNotificationsList returns the user's notifications, most
+// recently active first, and how many are unread. ?unread=true leaves out
+// read ones.
+func (cfg *apiConfig) handlerNotificationsList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	const defaultLimit, maxLimit = 50, 200
+	type response struct {
+		UnreadCount   int64          `json:"unread_count"`
+		Notifications []Notification `json:"notifications"`
+	}
+
+	limit := defaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(parsed, maxLimit)
+	}
+
+	dbNotifications, err := cfg.db.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:     accessToken.UserID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notifications", err)
+		return
+	}
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count notifications", err)
+		return
+	}
+
+	notifications := []Notification{}
+	for _, dbNotification := range dbNotifications {
+		notifications = append(notifications, notificationFromDB(dbNotification))
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		UnreadCount:   unread,
+		Notifications: notifications,
+	})
+}
+
+// handlerNotificationsRead marks the notifications in "ids" read, or all
+// of them with "all": true. Marking everything read has to be asked for,
+// so a client that loses its ids doesn't do it by accident.
+func (cfg *apiConfig) handlerNotificationsRead(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		IDs []uuid.UUID `json:"ids"`
+		All bool        `json:"all"`
+	}
+	type response struct {
+		UnreadCount int64 `json:"unread_count"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	if params.All == (len(params.IDs) > 0) {
+		respondWithError(w, http.StatusBadRequest, `Give either "ids" or "all": true`, nil)
+		return
+	}
+
+	_, err = cfg.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID:           accessToken.UserID,
+		AllNotifications: params.All,
+		Ids:              params.IDs,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notifications read", err)
+		return
+	}
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count notifications", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{UnreadCount: unread})
+}
+
+func (cfg *apiConfig) handlerNotificationPreferencesGet(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	preferences, err := cfg.notificationPreferences(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, preferences)
+}
+
+// handlerNotificationPreferencesUpdate turns notification types on or
+// off. Types left out of the body keep their setting.
+func (cfg *apiConfig) handlerNotificationPreferencesUpdate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	decoder := json.NewDecoder(r.Body)
+	params := map[string]bool{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	for notificationType := range params {
+		if !notification.ValidType(notificationType) {
+			respondWithError(w, http.StatusBadRequest, "Unknown notification type "+strconv.Quote(notificationType), nil)
+			return
+		}
+	}
+
+	for notificationType, enabled := range params {
+		err = cfg.db.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  accessToken.UserID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update preferences", err)
+			return
+		}
+	}
+
+	preferences, err := cfg.notificationPreferences(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, preferences)
+}
+
+// notificationPreferences returns whether each type is on.
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	dbPreferences, err := cfg.db.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences := map[string]bool{}
+	for _, notificationType := range notification.Types {
+		preferences[notificationType] = true
+	}
+	for _, preference := range dbPreferences {
+		preferences[preference.Type] = preference.Enabled
+	}
+	return preferences, nil
+}
+
+func notificationFromDB(n database.ListNotificationsRow) Notification {
+	result := Notification{
+		ID:         n.ID,
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+		Type:       n.Type,
+		ActorIDs:   n.RecentActorIds,
+		ActorCount: int(n.ActorCount),
+		Summary:    notification.Summary(n.Type, int(n.ActorCount)),
+		Read:       n.ReadAt.Valid,
+	}
+	if result.ActorIDs == nil {
+		result.ActorIDs = []uuid.UUID{}
+	}
+	if n.ChirpID.Valid {
+		result.ChirpID = &n.ChirpID.UUID
+	}
+	return result
+}
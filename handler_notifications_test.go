@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/hashtag"
+	"github.com/MechamJonathan/chirpy/internal/notification"
+	"github.com/google/uuid"
+)
+
+// listTestNotifications returns the user's unread count and notifications.
+func listTestNotifications(t *testing.T, cfg *apiConfig, accessToken auth.AccessToken) (int64, []Notification) {
+	t.Helper()
+	w := serveAuthed(cfg.handlerNotificationsList, accessToken, http.MethodGet, "/api/notifications", "")
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		UnreadCount   int64          `json:"unread_count"`
+		Notifications []Notification `json:"notifications"`
+	}
+	err := json.NewDecoder(w.Body).Decode(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.UnreadCount, resp.Notifications
+}
+
+// createTestChirp stores a chirp without going through the handler.
+func createTestChirp(t *testing.T, cfg *apiConfig, user database.User, body string) database.Chirp {
+	t.Helper()
+	chirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
+		Body:     body,
+		UserID:   user.ID,
+		Hashtags: hashtag.Extract(body),
+	})
+	if err != nil {
+		t.Fatalf("Couldn't create chirp: %v", err)
+	}
+	return chirp
+}
+
+// blockTestUser makes blocker block blocked.
+func blockTestUser(t *testing.T, cfg *apiConfig, blocker, blocked database.User) {
+	t.Helper()
+	_, err := cfg.db.CreateBlock(context.Background(), database.CreateBlockParams{BlockerID: blocker.ID, BlockedID: blocked.ID})
+	if err != nil {
+		t.Fatalf("Couldn't block user: %v", err)
+	}
+}
+
+func TestNotifyGroupsRepeats(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	alice, bob, carol := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob"), createTestUser(t, cfg, "carol")
+	chirp := createTestChirp(t, cfg, alice, "hello")
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+
+	cfg.notify(ctx, alice.ID, bob.ID, notification.TypeLike, chirpID)
+	cfg.notify(ctx, alice.ID, carol.ID, notification.TypeLike, chirpID)
+	cfg.notify(ctx, alice.ID, bob.ID, notification.TypeLike, chirpID)
+	cfg.notify(ctx, alice.ID, alice.ID, notification.TypeLike, chirpID)
+
+	unread, notifications := listTestNotifications(t, cfg, loginTestUser(t, cfg, alice))
+	if unread != 1 || len(notifications) != 1 {
+		t.Fatalf("unread = %d, notifications = %+v; want one grouped notification", unread, notifications)
+	}
+	if n := notifications[0]; n.ActorCount != 2 || n.Summary != "2 people liked your chirp" {
+		t.Errorf("notification = %+v, want bob and carol's likes", n)
+	}
+}
+
+func TestNotifySkipsBlocks(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	alice, bob, carol := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob"), createTestUser(t, cfg, "carol")
+	blockTestUser(t, cfg, alice, bob)
+
+	cfg.notify(ctx, alice.ID, bob.ID, notification.TypeFollow, uuid.NullUUID{})
+	cfg.notifyChirpCreated(ctx, createTestChirp(t, cfg, bob, "hi @alice"), database.Chirp{})
+	// Blocks work both ways.
+	cfg.notify(ctx, bob.ID, alice.ID, notification.TypeFollow, uuid.NullUUID{})
+	_, notifications := listTestNotifications(t, cfg, loginTestUser(t, cfg, alice))
+	if len(notifications) != 0 {
+		t.Errorf("alice got %+v from bob after blocking bob", notifications)
+	}
+	_, notifications = listTestNotifications(t, cfg, loginTestUser(t, cfg, bob))
+	if len(notifications) != 0 {
+		t.Errorf("bob got %+v from alice after being blocked", notifications)
+	}
+
+	cfg.notifyChirpCreated(ctx, createTestChirp(t, cfg, carol, "hi @alice"), database.Chirp{})
+	_, notifications = listTestNotifications(t, cfg, loginTestUser(t, cfg, alice))
+	if len(notifications) != 1 || notifications[0].Type != notification.TypeMention {
+		t.Errorf("alice got %+v, want carol's mention", notifications)
+	}
+}
+
+func TestNotifyRespectsPreferences(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	accessToken := loginTestUser(t, cfg, alice)
+
+	w := serveAuthed(cfg.handlerNotificationPreferencesUpdate, accessToken, http.MethodPut, "/api/notifications/preferences", `{"follow": false}`)
+	expectStatus(t, w, http.StatusOK)
+	w = serveAuthed(cfg.handlerNotificationPreferencesUpdate, accessToken, http.MethodPut, "/api/notifications/preferences", `{"shove": false}`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	cfg.notify(context.Background(), alice.ID, bob.ID, notification.TypeFollow, uuid.NullUUID{})
+	_, notifications := listTestNotifications(t, cfg, accessToken)
+	if len(notifications) != 0 {
+		t.Errorf("alice got %+v with follow notifications off", notifications)
+	}
+}
+
+func TestHandlerNotificationsRead(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	accessToken := loginTestUser(t, cfg, alice)
+	cfg.notify(ctx, alice.ID, bob.ID, notification.TypeFollow, uuid.NullUUID{})
+	chirp := createTestChirp(t, cfg, alice, "hello")
+	cfg.notify(ctx, alice.ID, bob.ID, notification.TypeLike, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	_, notifications := listTestNotifications(t, cfg, accessToken)
+	if len(notifications) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(notifications))
+	}
+
+	// Nothing here asks for everything to be marked read.
+	for _, body := range []string{"", `{}`, `{"ids": []}`, `{"all": false}`, `{"all": true, "ids": ["` + notifications[0].ID.String() + `"]}`} {
+		w := serveAuthed(cfg.handlerNotificationsRead, accessToken, http.MethodPost, "/api/notifications/read", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %q: status = %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+	unread, _ := listTestNotifications(t, cfg, accessToken)
+	if unread != 2 {
+		t.Fatalf("unread = %d after rejected requests, want 2", unread)
+	}
+
+	w := serveAuthed(cfg.handlerNotificationsRead, accessToken, http.MethodPost, "/api/notifications/read", `{"ids": ["`+notifications[0].ID.String()+`"]}`)
+	expectStatus(t, w, http.StatusOK)
+	unread, _ = listTestNotifications(t, cfg, accessToken)
+	if unread != 1 {
+		t.Errorf("unread = %d after marking one read, want 1", unread)
+	}
+
+	w = serveAuthed(cfg.handlerNotificationsRead, accessToken, http.MethodPost, "/api/notifications/read", `{"all": true}`)
+	expectStatus(t, w, http.StatusOK)
+	unread, _ = listTestNotifications(t, cfg, accessToken)
+	if unread != 0 {
+		t.Errorf("unread = %d after marking all read, want 0", unread)
+	}
+}
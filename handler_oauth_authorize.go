@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/google/uuid"
+)
+
+const authorizationCodeLifetime = 10 * time.Minute
+
+var consentTemplate = template.Must(template.New("consent").Parse(`
+<html>
+    <body>
+        <h1>Authorize {{.ClientName}}</h1>
+        <p>{{.ClientName}} would like to:</p>
+        <ul>
+            {{range .Scopes}}<li>{{.}}</li>{{end}}
+        </ul>
+        {{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+        <form method="post" action="/oauth/authorize">
+            <input type="hidden" name="response_type" value="code">
+            <input type="hidden" name="client_id" value="{{.ClientID}}">
+            <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+            <input type="hidden" name="scope" value="{{.Scope}}">
+            <input type="hidden" name="state" value="{{.State}}">
+            <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+            <input type="hidden" name="code_challenge_method" value="S256">
+            <p><label>Email <input type="email" name="email" autocomplete="username"></label></p>
+            <p><label>Password <input type="password" name="password" autocomplete="current-password"></label></p>
+            <button type="submit" name="decision" value="approve">Allow</button>
+            <button type="submit" name="decision" value="deny">Deny</button>
+        </form>
+    </body>
+</html>`))
+
+// authorizeRequest is a validated authorization request.
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	CodeChallenge string
+}
+
+// errAuthorizeRequest is an authorization request error. Errors found
+// before the client and redirect URI are trusted must not redirect,
+// otherwise Chirpy becomes an open redirector.
+type errAuthorizeRequest struct {
+	code        string
+	description string
+	redirect    bool
+}
+
+func (e errAuthorizeRequest) Error() string {
+	return e.code + ": " + e.description
+}
+
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.URL.Query())
+	if err != nil {
+		respondWithAuthorizeError(w, r, req, err)
+		return
+	}
+	renderConsent(w, http.StatusOK, req, "")
+}
+
+// handlerOAuthAuthorizeConsent handles the consent form. The user signs in
+// on the form itself, with the same brute-force protection as the login
+// endpoint.
+func (cfg *apiConfig) handlerOAuthAuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse form", err)
+		return
+	}
+
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.PostForm)
+	if err != nil {
+		respondWithAuthorizeError(w, r, req, err)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "approve" {
+		respondWithAuthorizeError(w, r, req, errAuthorizeRequest{"access_denied", "the user denied the request", true})
+		return
+	}
+
+	user, err := cfg.checkCredentials(r.Context(), clientIP(r), r.PostForm.Get("email"), r.PostForm.Get("password"))
+	var throttled errLoginThrottled
+	if errors.As(err, &throttled) {
+		renderConsent(w, http.StatusTooManyRequests, req, "Too many failed login attempts, try again later")
+		return
+	}
+	if errors.Is(err, errIncorrectCredentials) {
+		renderConsent(w, http.StatusUnauthorized, req, "Incorrect email or password")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't check credentials: %s", err)
+		renderConsent(w, http.StatusInternalServerError, req, "Something went wrong, please try again")
+		return
+	}
+
+	code, err := oauth.NewSecret(32)
+	if err != nil {
+		respondWithAuthorizeError(w, r, req, errAuthorizeRequest{"server_error", "couldn't create code", true})
+		return
+	}
+	err = cfg.db.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scope:         oauth.FormatScope(req.Scopes),
+		CodeChallenge: req.CodeChallenge,
+		SessionID:     uuid.New(),
+		ExpiresAt:     time.Now().UTC().Add(authorizationCodeLifetime),
+	})
+	if err != nil {
+		log.Printf("Couldn't store authorization code: %s", err)
+		respondWithAuthorizeError(w, r, req, errAuthorizeRequest{"server_error", "couldn't create code", true})
+		return
+	}
+
+	redirectWithParams(w, r, req.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	})
+}
+
+func (cfg *apiConfig) parseAuthorizeRequest(ctx context.Context, values url.Values) (authorizeRequest, error) {
+	req := authorizeRequest{
+		RedirectURI: values.Get("redirect_uri"),
+		State:       values.Get("state"),
+	}
+
+	client, err := cfg.db.GetOAuthClient(ctx, values.Get("client_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return req, errAuthorizeRequest{"invalid_request", "unknown client_id", false}
+	}
+	if err != nil {
+		log.Printf("Couldn't get OAuth client: %s", err)
+		return req, errAuthorizeRequest{"server_error", "couldn't look up client", false}
+	}
+	if !oauth.MatchRedirectURI(req.RedirectURI, strings.Fields(client.RedirectUris)) {
+		return req, errAuthorizeRequest{"invalid_request", "redirect_uri isn't registered for this client", false}
+	}
+	req.Client = client
+
+	// From here on the redirect URI is trusted, so errors go back to the
+	// client.
+	if values.Get("response_type") != "code" {
+		return req, errAuthorizeRequest{"unsupported_response_type", "only the code response type is supported", true}
+	}
+	if values.Get("code_challenge_method") != "S256" || !oauth.ValidChallenge(values.Get("code_challenge")) {
+		return req, errAuthorizeRequest{"invalid_request", "PKCE with code_challenge_method S256 is required", true}
+	}
+	req.CodeChallenge = values.Get("code_challenge")
+
+	scopes, err := oauth.ParseScope(values.Get("scope"))
+	if err != nil {
+		return req, errAuthorizeRequest{"invalid_scope", err.Error(), true}
+	}
+	if len(scopes) == 0 || !oauth.Subset(scopes, strings.Fields(client.Scopes)) {
+		return req, errAuthorizeRequest{"invalid_scope", "the client may not request these scopes", true}
+	}
+	req.Scopes = scopes
+
+	return req, nil
+}
+
+func renderConsent(w http.ResponseWriter, code int, req authorizeRequest, errMsg string) {
+	descriptions := []string{}
+	for _, scope := range req.Scopes {
+		descriptions = append(descriptions, oauth.DescribeScope(scope))
+	}
+
+	// The consent page must never be framed, or another site could trick
+	// the user into clicking Allow.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	err := consentTemplate.Execute(w, map[string]interface{}{
+		"ClientName":    req.Client.Name,
+		"ClientID":      req.Client.ID,
+		"RedirectURI":   req.RedirectURI,
+		"Scope":         oauth.FormatScope(req.Scopes),
+		"Scopes":        descriptions,
+		"State":         req.State,
+		"CodeChallenge": req.CodeChallenge,
+		"Error":         errMsg,
+	})
+	if err != nil {
+		log.Printf("Error rendering consent page: %s", err)
+	}
+}
+
+func respondWithAuthorizeError(w http.ResponseWriter, r *http.Request, req authorizeRequest, err error) {
+	authErr := errAuthorizeRequest{}
+	if !errors.As(err, &authErr) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check authorization request", err)
+		return
+	}
+	if !authErr.redirect {
+		respondWithError(w, http.StatusBadRequest, authErr.description, err)
+		return
+	}
+
+	redirectWithParams(w, r, req.RedirectURI, url.Values{
+		"error":             {authErr.code},
+		"error_description": {authErr.description},
+		"state":             {req.State},
+	})
+}
+
+// redirectWithParams redirects to a registered redirect URI, keeping any
+// query it already has.
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid redirect URI", err)
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
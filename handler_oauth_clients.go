@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+)
+
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+}
+
+// handlerOAuthClientsCreate registers a third-party app. Confidential
+// clients get a secret, shown only in this response. Public clients, such
+// as mobile and single-page apps, rely on PKCE alone.
+func (cfg *apiConfig) handlerOAuthClientsCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	type response struct {
+		OAuthClient
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+
+	if !accessToken.FirstParty() {
+		respondWithError(w, http.StatusForbidden, "Clients must be registered from a login session", nil)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "Client name is required", nil)
+		return
+	}
+	if len(params.RedirectURIs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one redirect URI is required", nil)
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		if !oauth.ValidRedirectURI(uri) {
+			respondWithError(w, http.StatusBadRequest, "Invalid redirect URI: "+uri, nil)
+			return
+		}
+	}
+	scopes, err := oauth.ParseScope(strings.Join(params.Scopes, " "))
+	if err != nil {
+		respondWithInvalidField(w, "scopes", err)
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+
+	clientID, err := oauth.NewSecret(16)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create client ID", err)
+		return
+	}
+	clientSecret := ""
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		clientSecret, err = oauth.NewSecret(32)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create client secret", err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(clientSecret), Valid: true}
+	}
+
+	client, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           clientID,
+		UserID:       accessToken.UserID,
+		Name:         name,
+		SecretHash:   secretHash,
+		RedirectUris: strings.Join(params.RedirectURIs, " "),
+		Scopes:       oauth.FormatScope(scopes),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create client", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		OAuthClient:  oauthClientFromDB(client),
+		ClientSecret: clientSecret,
+	})
+}
+
+func oauthClientFromDB(client database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: strings.Fields(client.RedirectUris),
+		Scopes:       strings.Fields(client.Scopes),
+		Confidential: client.SecretHash.Valid,
+	}
+}
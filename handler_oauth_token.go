@@ -0,0 +1,273 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+)
+
+// respondWithOAuthError writes an error in the RFC 6749 format, which
+// OAuth client libraries expect instead of our usual {"error": ...}.
+func respondWithOAuthError(w http.ResponseWriter, code int, errCode, description string, err error) {
+	type oauthError struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, oauthError{
+		Error:            errCode,
+		ErrorDescription: description,
+	})
+}
+
+// authenticateClient identifies the OAuth client making a back-channel
+// request. Confidential clients send their secret with HTTP Basic or in
+// the form; public clients only send client_id.
+func (cfg *apiConfig) authenticateClient(r *http.Request) (database.OauthClient, error) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if clientID == "" {
+		return database.OauthClient{}, errors.New("no client_id")
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.SecretHash.Valid {
+		got := auth.HashToken(clientSecret)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errors.New("incorrect client secret")
+		}
+	} else if clientSecret != "" {
+		return database.OauthClient{}, errors.New("public client sent a secret")
+	}
+	return client, nil
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form", err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed", err)
+		return
+	}
+
+	var sess tokenSession
+	var refreshToken string
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		sess, refreshToken, err = cfg.exchangeAuthorizationCode(r, client)
+	case "refresh_token":
+		current := newSession(r)
+		current.ClientID = client.ID
+		refreshToken, sess, err = cfg.rotateRefreshToken(r.Context(), r.PostForm.Get("refresh_token"), current)
+		if err != nil {
+			err = errInvalidGrant{err}
+		}
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "", nil)
+		return
+	}
+	var invalidGrant errInvalidGrant
+	if errors.As(err, &invalidGrant) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "the grant is invalid, expired or revoked", err)
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	cfg.respondWithTokens(w, sess, refreshToken)
+}
+
+type errInvalidGrant struct {
+	err error
+}
+
+func (e errInvalidGrant) Error() string {
+	return "invalid grant: " + e.err.Error()
+}
+
+// exchangeAuthorizationCode redeems a code for the session it was issued
+// for. Codes are single use; a code presented twice has leaked, so the
+// tokens already issued for it are revoked.
+func (cfg *apiConfig) exchangeAuthorizationCode(r *http.Request, client database.OauthClient) (tokenSession, string, error) {
+	codeHash := auth.HashToken(r.PostForm.Get("code"))
+	code, err := cfg.db.ConsumeAuthorizationCode(r.Context(), codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		used, getErr := cfg.db.GetAuthorizationCode(r.Context(), codeHash)
+		if getErr == nil && used.ClientID == client.ID {
+			revoked, revokeErr := cfg.db.RevokeRefreshTokenFamily(r.Context(), used.SessionID)
+			if revokeErr != nil {
+				log.Printf("Couldn't revoke tokens for reused code: %s", revokeErr)
+			} else {
+				cfg.recordAuditEvent(r.Context(), "oauth.code_reused", used.UserID, clientIP(r),
+					fmt.Sprintf("revoked %d tokens in family %s", revoked, used.SessionID))
+			}
+		}
+		return tokenSession{}, "", errInvalidGrant{errors.New("code doesn't exist or was already used")}
+	}
+	if err != nil {
+		return tokenSession{}, "", err
+	}
+
+	if code.ClientID != client.ID {
+		return tokenSession{}, "", errInvalidGrant{errors.New("code was issued to a different client")}
+	}
+	if code.RedirectUri != r.PostForm.Get("redirect_uri") {
+		return tokenSession{}, "", errInvalidGrant{errors.New("redirect_uri doesn't match")}
+	}
+	if time.Now().UTC().After(code.ExpiresAt) {
+		return tokenSession{}, "", errInvalidGrant{errors.New("code expired")}
+	}
+	if !oauth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		return tokenSession{}, "", errInvalidGrant{errors.New("code_verifier doesn't match")}
+	}
+
+	sess := newSession(r)
+	sess.ID = code.SessionID
+	sess.CreatedAt = time.Now().UTC()
+	sess.UserID = code.UserID
+	sess.ClientID = client.ID
+	sess.Scopes = strings.Fields(code.Scope)
+	refreshToken, err := cfg.issueRefreshToken(r.Context(), cfg.db, &sess)
+	if err != nil {
+		return tokenSession{}, "", err
+	}
+	return sess, refreshToken, nil
+}
+
+func (cfg *apiConfig) respondWithTokens(w http.ResponseWriter, sess tokenSession, refreshToken string) {
+	type response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	accessToken, err := cfg.makeAccessToken(sess, 0)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, response{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(cfg.tokens.AccessTokenTTL().Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        oauth.FormatScope(sess.Scopes),
+	})
+}
+
+// handlerOAuthRevoke implements RFC 7009. Revoking a refresh token ends
+// its whole session. Unknown tokens and tokens of other clients are
+// ignored, and the response is the same either way.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form", err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed", err)
+		return
+	}
+
+	token, err := cfg.db.GetRefreshToken(r.Context(), auth.HashRefreshToken(r.PostForm.Get("token")))
+	if err == nil && token.ClientID.String == client.ID {
+		_, err = cfg.db.RevokeRefreshTokenFamily(r.Context(), token.FamilyID)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlerOAuthIntrospect implements RFC 7662 for access and refresh
+// tokens. A client may only introspect its own tokens.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form", err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	token := r.PostForm.Get("token")
+
+	accessToken, err := cfg.tokens.ValidateAccessToken(token)
+	if err == nil && accessToken.ClientID == client.ID {
+		respondWithJSON(w, http.StatusOK, response{
+			Active:    true,
+			Scope:     oauth.FormatScope(accessToken.Scopes),
+			ClientID:  accessToken.ClientID,
+			Subject:   accessToken.UserID.String(),
+			ExpiresAt: accessToken.ExpiresAt.Unix(),
+			TokenType: "Bearer",
+		})
+		return
+	}
+
+	refreshToken, err := cfg.db.GetRefreshToken(r.Context(), auth.HashRefreshToken(token))
+	if err == nil && refreshToken.ClientID.String == client.ID &&
+		!refreshToken.RevokedAt.Valid && time.Now().UTC().Before(refreshToken.ExpiresAt) {
+		respondWithJSON(w, http.StatusOK, response{
+			Active:    true,
+			Scope:     refreshToken.Scope,
+			ClientID:  refreshToken.ClientID.String,
+			Subject:   refreshToken.UserID.String(),
+			ExpiresAt: refreshToken.ExpiresAt.Unix(),
+			TokenType: "refresh_token",
+		})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Active: false})
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/MechamJonathan/chirpy/internal/openapi"
+)
+
+// handlerOpenAPI serves the OpenAPI document describing every route.
+func handlerOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.JSON())
+}
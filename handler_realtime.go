@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/MechamJonathan/chirpy/internal/realtime"
+	"github.com/google/uuid"
+)
+
+// websocketTicketTTL is how long a client has to open the WebSocket a
+// ticket was issued for.
+const websocketTicketTTL = 30 * time.Second
+
+// handlerRealtimeTicketsCreate issues a single-use ticket for opening the
+// WebSocket. Browsers can't set headers on the handshake, so the ticket
+// goes in the URL in place of the access token; by the time it shows up in
+// a proxy or access log it has been spent.
+func (cfg *apiConfig) handlerRealtimeTicketsCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type response struct {
+		Ticket    string    `json:"ticket"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	ticket, err := oauth.NewSecret(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create ticket", err)
+		return
+	}
+
+	_, err = cfg.db.DeleteExpiredWebsocketTickets(r.Context(), accessToken.UserID)
+	if err != nil {
+		log.Printf("Couldn't delete expired WebSocket tickets for user %s: %s", accessToken.UserID, err)
+	}
+
+	// A ticket never outlives the token it was issued for.
+	expiresAt := time.Now().UTC().Add(websocketTicketTTL)
+	if !accessToken.ExpiresAt.IsZero() && accessToken.ExpiresAt.Before(expiresAt) {
+		expiresAt = accessToken.ExpiresAt.UTC()
+	}
+	err = cfg.db.CreateWebsocketTicket(r.Context(), database.CreateWebsocketTicketParams{
+		TicketHash:      auth.HashToken(ticket),
+		UserID:          accessToken.UserID,
+		SessionID:       uuid.NullUUID{UUID: accessToken.SessionID, Valid: accessToken.SessionID != uuid.Nil},
+		ClientID:        sql.NullString{String: accessToken.ClientID, Valid: accessToken.ClientID != ""},
+		PersonalTokenID: uuid.NullUUID{UUID: accessToken.PersonalTokenID, Valid: accessToken.PersonalTokenID != uuid.Nil},
+		Scope:           strings.Join(accessToken.Scopes, " "),
+		TokenExpiresAt:  sql.NullTime{Time: accessToken.ExpiresAt, Valid: !accessToken.ExpiresAt.IsZero()},
+		ExpiresAt:       expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create ticket", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		Ticket:    ticket,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// handlerRealtime upgrades to a WebSocket on which the client can
+// subscribe to "timeline", "notifications" and "replies:<chirpID>". The
+// client authenticates with an access token in the Authorization header
+// or, from a browser, a ticket from POST /api/ws/tickets in the ticket
+// parameter.
+func (cfg *apiConfig) handlerRealtime(w http.ResponseWriter, r *http.Request) {
+	ticket := r.URL.Query().Get("ticket")
+	token, err := auth.GetBearerToken(r.Header)
+	if ticket == "" && err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy"`)
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT or ticket", err)
+		return
+	}
+
+	var accessToken auth.AccessToken
+	if ticket != "" {
+		accessToken, err = cfg.redeemWebsocketTicket(r.Context(), ticket)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "Ticket is invalid, expired or already used", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check ticket", err)
+			return
+		}
+	} else {
+		accessToken, err = cfg.validateBearerToken(r.Context(), token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy", error="invalid_token"`)
+			respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+			return
+		}
+	}
+	if !accessToken.HasScope(oauth.ScopeChirpsRead) {
+		respondWithInsufficientScope(w, oauth.ScopeChirpsRead)
+		return
+	}
+
+	conn, err := realtime.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	cfg.realtime.Serve(r.Context(), conn, cfg.realtimeResolver(accessToken), accessToken.ExpiresAt)
+}
+
+// redeemWebsocketTicket spends a ticket and returns the access token it
+// was issued for.
+func (cfg *apiConfig) redeemWebsocketTicket(ctx context.Context, ticket string) (auth.AccessToken, error) {
+	dbTicket, err := cfg.db.ConsumeWebsocketTicket(ctx, auth.HashToken(ticket))
+	if err != nil {
+		return auth.AccessToken{}, err
+	}
+	return auth.AccessToken{
+		UserID:          dbTicket.UserID,
+		SessionID:       dbTicket.SessionID.UUID,
+		ExpiresAt:       dbTicket.TokenExpiresAt.Time,
+		ClientID:        dbTicket.ClientID.String,
+		Scopes:          strings.Fields(dbTicket.Scope),
+		PersonalTokenID: dbTicket.PersonalTokenID.UUID,
+	}, nil
+}
+
+// realtimeResolver maps the topics a client asks for to broker topics.
+// The timeline is resolved once, so following someone takes effect the
+// next time the client subscribes to it.
+func (cfg *apiConfig) realtimeResolver(accessToken auth.AccessToken) realtime.Resolver {
+	return func(ctx context.Context, topic string) ([]string, error) {
+		switch topic {
+		case "timeline":
+			following, err := cfg.db.ListFollowing(ctx, accessToken.UserID)
+			if err != nil {
+				return nil, err
+			}
+			topics := []string{realtime.AuthorTopic(accessToken.UserID)}
+			for _, followeeID := range following {
+				topics = append(topics, realtime.AuthorTopic(followeeID))
+			}
+			return topics, nil
+		case "notifications":
+			return []string{realtime.NotificationsTopic(accessToken.UserID)}, nil
+		}
+
+		value, ok := strings.CutPrefix(topic, "replies:")
+		if !ok {
+			return nil, realtime.ErrUnknownTopic
+		}
+		chirpID, err := uuid.Parse(value)
+		if err != nil {
+			return nil, realtime.ErrUnknownTopic
+		}
+		_, err = cfg.db.GetChirp(ctx, chirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, realtime.ErrUnknownTopic
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []string{realtime.RepliesTopic(chirpID)}, nil
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func TestHandlerRealtimeNeedsCredentials(t *testing.T) {
+	keys := auth.NewKeyring()
+	keys.Add(auth.NewHMACKey("test", "test-secret"))
+	cfg := &apiConfig{keys: keys, tokens: auth.NewTokenService(keys, auth.DefaultTokenConfig())}
+	token, err := cfg.tokens.MakeAccessToken(uuid.New(), uuid.New(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Access tokens in the URL end up in logs, so they aren't accepted.
+	r := httptest.NewRequest(http.MethodGet, "/api/ws?access_token="+token, nil)
+	w := httptest.NewRecorder()
+	cfg.handlerRealtime(w, r)
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestHandlerRealtimeTicket(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice")
+	accessToken := loginTestUser(t, cfg, user)
+
+	w := serveAuthed(cfg.handlerRealtimeTicketsCreate, accessToken, http.MethodPost, "/api/ws/tickets", "")
+	expectStatus(t, w, http.StatusCreated)
+	var ticket struct {
+		Ticket    string    `json:"ticket"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err := json.NewDecoder(w.Body).Decode(&ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Ticket == "" || time.Until(ticket.ExpiresAt) > websocketTicketTTL {
+		t.Fatalf("ticket = %+v, want a ticket expiring within %s", ticket, websocketTicketTTL)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(cfg.handlerRealtime))
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws?ticket=" + ticket.Ticket
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() with a fresh ticket: error = %v", err)
+	}
+	err = conn.WriteJSON(map[string]string{"type": "subscribe", "topic": "notifications"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply struct {
+		Type string `json:"type"`
+	}
+	err = conn.ReadJSON(&reply)
+	if err != nil || reply.Type != "subscribed" {
+		t.Errorf("subscribing: reply = %+v, error = %v; want subscribed", reply, err)
+	}
+	conn.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("a ticket opened a second connection")
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("reusing a ticket: status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
@@ -1,15 +1,30 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	errRefreshTokenReused  = errors.New("refresh token reused")
+	errRefreshTokenRevoked = errors.New("refresh token revoked")
+	errRefreshTokenExpired = errors.New("refresh token expired")
 )
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
@@ -18,24 +33,21 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshToken)
+	newRefreshToken, sess, err := cfg.rotateRefreshToken(r.Context(), refreshToken, newSession(r))
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.jwtSecret,
-		time.Hour,
-	)
+	accessToken, err := cfg.makeAccessToken(sess, 0)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
 	})
 }
 
@@ -46,7 +58,20 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.db.RevokeRefreshToken(r.Context(), refreshToken)
+	// Tokens issued to OAuth clients are revoked through /oauth/revoke,
+	// which authenticates the client that owns them.
+	tokenHash := auth.HashRefreshToken(refreshToken)
+	current, err := cfg.db.GetRefreshToken(r.Context(), tokenHash)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && current.ClientID.Valid) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get refresh token", err)
+		return
+	}
+
+	_, err = cfg.db.RevokeRefreshToken(r.Context(), tokenHash)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
 		return
@@ -54,3 +79,159 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// tokenSession describes the device a refresh token family was issued to. The
+// family ID doubles as the session ID. ClientID and Scopes are set when
+// the session belongs to a third-party OAuth client.
+type tokenSession struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	UserAgent string
+	IPAddress string
+	ClientID  string
+	Scopes    []string
+}
+
+// newSession starts a first-party session for the client making the
+// request. ID, UserID and CreatedAt are filled in when the first token is
+// issued.
+func newSession(r *http.Request) tokenSession {
+	return tokenSession{
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+	}
+}
+
+// issueRefreshToken creates a new refresh token for the session, starting
+// the session if it doesn't have an ID yet.
+func (cfg *apiConfig) issueRefreshToken(ctx context.Context, db *database.Queries, sess *tokenSession) (string, error) {
+	refreshToken, err := cfg.tokens.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	if sess.ID == uuid.Nil {
+		sess.ID = uuid.New()
+		sess.CreatedAt = now
+	}
+
+	err = db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash:        refreshToken.Hash,
+		CreatedAt:        now,
+		UserID:           sess.UserID,
+		ExpiresAt:        refreshToken.ExpiresAt,
+		FamilyID:         sess.ID,
+		UserAgent:        sess.UserAgent,
+		IpAddress:        sess.IPAddress,
+		SessionCreatedAt: sess.CreatedAt,
+		ClientID:         sql.NullString{String: sess.ClientID, Valid: sess.ClientID != ""},
+		Scope:            strings.Join(sess.Scopes, " "),
+	})
+	if err != nil {
+		return "", err
+	}
+	return refreshToken.Token, nil
+}
+
+// rotateRefreshToken revokes the presented refresh token and issues its
+// replacement in the same family. Presenting a token that was already
+// rotated means it was copied, so the whole family is revoked. A token
+// revoked by logging out or ending its session, or one that merely
+// expired, is refused without touching the family. The token
+// must belong to the same OAuth client as client, or to no client for
+// first-party sessions.
+func (cfg *apiConfig) rotateRefreshToken(ctx context.Context, refreshToken string, client tokenSession) (string, tokenSession, error) {
+	tokenHash := auth.HashRefreshToken(refreshToken)
+	current, err := cfg.db.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return "", tokenSession{}, err
+	}
+	if current.ReplacedBy.Valid {
+		cfg.revokeTokenFamily(ctx, current, client.IPAddress)
+		return "", tokenSession{}, errRefreshTokenReused
+	}
+	if current.RevokedAt.Valid {
+		return "", tokenSession{}, errRefreshTokenRevoked
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return "", tokenSession{}, errRefreshTokenExpired
+	}
+	if current.ClientID.String != client.ClientID {
+		return "", tokenSession{}, errors.New("refresh token belongs to a different client")
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", tokenSession{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	sess := tokenSession{
+		ID:        current.FamilyID,
+		UserID:    current.UserID,
+		CreatedAt: current.SessionCreatedAt,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		ClientID:  current.ClientID.String,
+		Scopes:    strings.Fields(current.Scope),
+	}
+	newRefreshToken, err := cfg.issueRefreshToken(ctx, qtx, &sess)
+	if err != nil {
+		return "", tokenSession{}, err
+	}
+
+	_, err = qtx.RotateRefreshToken(ctx, database.RotateRefreshTokenParams{
+		TokenHash:  tokenHash,
+		ReplacedBy: sql.NullString{String: auth.HashRefreshToken(newRefreshToken), Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			return "", tokenSession{}, cfg.refreshTokenRaceError(ctx, current, client.IPAddress)
+		}
+		return "", tokenSession{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", tokenSession{}, err
+	}
+	return newRefreshToken, sess, nil
+}
+
+// refreshTokenRaceError explains why a token that looked valid when read
+// couldn't be rotated. Either another request rotated it in between, which
+// is the same as presenting it twice, or it was revoked or expired in the
+// meantime.
+func (cfg *apiConfig) refreshTokenRaceError(ctx context.Context, token database.RefreshToken, ipAddress string) error {
+	latest, err := cfg.db.GetRefreshToken(ctx, token.TokenHash)
+	if err != nil {
+		return err
+	}
+	if latest.ReplacedBy.Valid {
+		cfg.revokeTokenFamily(ctx, latest, ipAddress)
+		return errRefreshTokenReused
+	}
+	if latest.RevokedAt.Valid {
+		return errRefreshTokenRevoked
+	}
+	return errRefreshTokenExpired
+}
+
+// makeAccessToken mints an access token for the session, scoped to what
+// the session's OAuth client was granted.
+func (cfg *apiConfig) makeAccessToken(sess tokenSession, expiresIn time.Duration) (string, error) {
+	return cfg.tokens.MakeClientAccessToken(sess.UserID, sess.ID, sess.ClientID, sess.Scopes, expiresIn)
+}
+
+func (cfg *apiConfig) revokeTokenFamily(ctx context.Context, token database.RefreshToken, ipAddress string) {
+	revoked, err := cfg.db.RevokeRefreshTokenFamily(ctx, token.FamilyID)
+	if err != nil {
+		log.Printf("Couldn't revoke refresh token family %s: %s", token.FamilyID, err)
+		return
+	}
+	cfg.recordAuditEvent(ctx, "refresh_token.reused", token.UserID, ipAddress,
+		fmt.Sprintf("revoked %d tokens in family %s", revoked, token.FamilyID))
+}
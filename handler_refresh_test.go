@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+)
+
+// startTestSession logs user in and returns the session's refresh token.
+func startTestSession(t *testing.T, cfg *apiConfig, user database.User) (string, tokenSession) {
+	t.Helper()
+	sess := tokenSession{UserID: user.ID, UserAgent: "test", IPAddress: "192.0.2.1"}
+	refreshToken, err := cfg.issueRefreshToken(context.Background(), cfg.db, &sess)
+	if err != nil {
+		t.Fatalf("Couldn't start session: %v", err)
+	}
+	return refreshToken, sess
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	user := createTestUser(t, cfg, "alice")
+	first, sess := startTestSession(t, cfg, user)
+
+	second, rotated, err := cfg.rotateRefreshToken(ctx, first, tokenSession{IPAddress: "192.0.2.2"})
+	if err != nil {
+		t.Fatalf("rotateRefreshToken() error = %v", err)
+	}
+	if second == first {
+		t.Fatal("rotateRefreshToken() returned the presented token")
+	}
+	if rotated.ID != sess.ID || rotated.UserID != user.ID {
+		t.Errorf("rotated session = %+v, want family %s of user %s", rotated, sess.ID, user.ID)
+	}
+
+	old, err := cfg.db.GetRefreshToken(ctx, auth.HashRefreshToken(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !old.RevokedAt.Valid || old.ReplacedBy.String != auth.HashRefreshToken(second) {
+		t.Errorf("old token revoked = %v, replaced by %q; want revoked and replaced by the new token", old.RevokedAt.Valid, old.ReplacedBy.String)
+	}
+
+	_, _, err = cfg.rotateRefreshToken(ctx, second, tokenSession{})
+	if err != nil {
+		t.Errorf("rotating the replacement: error = %v", err)
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	user := createTestUser(t, cfg, "alice")
+	first, _ := startTestSession(t, cfg, user)
+
+	second, _, err := cfg.rotateRefreshToken(ctx, first, tokenSession{})
+	if err != nil {
+		t.Fatalf("rotateRefreshToken() error = %v", err)
+	}
+
+	_, _, err = cfg.rotateRefreshToken(ctx, first, tokenSession{})
+	if !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("presenting a rotated token: error = %v, want %v", err, errRefreshTokenReused)
+	}
+	_, _, err = cfg.rotateRefreshToken(ctx, second, tokenSession{})
+	if err == nil {
+		t.Fatal("the replacement still rotates after its family was revoked")
+	}
+
+	sessions, err := cfg.db.ListUserSessions(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("%d sessions still active after reuse, want 0", len(sessions))
+	}
+}
+
+func TestRotateRefreshTokenExpiredKeepsFamily(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	user := createTestUser(t, cfg, "alice")
+	current, sess := startTestSession(t, cfg, user)
+
+	// An expired token from the same family, as a client that slept
+	// through its token's lifetime would present.
+	expired := "expired-refresh-token"
+	err := cfg.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash:        auth.HashRefreshToken(expired),
+		CreatedAt:        time.Now().Add(-2 * time.Hour),
+		UserID:           user.ID,
+		ExpiresAt:        time.Now().Add(-time.Hour),
+		FamilyID:         sess.ID,
+		UserAgent:        sess.UserAgent,
+		IpAddress:        sess.IPAddress,
+		SessionCreatedAt: sess.CreatedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = cfg.rotateRefreshToken(ctx, expired, tokenSession{})
+	if !errors.Is(err, errRefreshTokenExpired) {
+		t.Fatalf("presenting an expired token: error = %v, want %v", err, errRefreshTokenExpired)
+	}
+	_, _, err = cfg.rotateRefreshToken(ctx, current, tokenSession{})
+	if err != nil {
+		t.Errorf("the family was revoked for an expired token: error = %v", err)
+	}
+}
+
+func TestRefreshTokenRaceError(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	user := createTestUser(t, cfg, "alice")
+	first, _ := startTestSession(t, cfg, user)
+	token, err := cfg.db.GetRefreshToken(ctx, auth.HashRefreshToken(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unrevoked means the token expired between the read and the write.
+	err = cfg.refreshTokenRaceError(ctx, token, "192.0.2.1")
+	if !errors.Is(err, errRefreshTokenExpired) {
+		t.Errorf("unrevoked token: error = %v, want %v", err, errRefreshTokenExpired)
+	}
+
+	// Revoked without a replacement means it was logged out.
+	_, err = cfg.db.RevokeRefreshToken(ctx, token.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.refreshTokenRaceError(ctx, token, "192.0.2.1")
+	if !errors.Is(err, errRefreshTokenRevoked) {
+		t.Errorf("revoked token: error = %v, want %v", err, errRefreshTokenRevoked)
+	}
+
+	// Replaced means another request rotated it.
+	second, _ := startTestSession(t, cfg, user)
+	token, err = cfg.db.GetRefreshToken(ctx, auth.HashRefreshToken(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cfg.rotateRefreshToken(ctx, second, tokenSession{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.refreshTokenRaceError(ctx, token, "192.0.2.1")
+	if !errors.Is(err, errRefreshTokenReused) {
+		t.Errorf("rotated token: error = %v, want %v", err, errRefreshTokenReused)
+	}
+}
+
+func TestRefreshAfterLogoutIsntReuse(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	user := createTestUser(t, cfg, "alice")
+	loggedOut, _ := startTestSession(t, cfg, user)
+	other, _ := startTestSession(t, cfg, user)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/revoke", nil)
+	r.Header.Set("Authorization", "Bearer "+loggedOut)
+	w := httptest.NewRecorder()
+	cfg.handlerRevoke(w, r)
+	expectStatus(t, w, http.StatusNoContent)
+
+	r = httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+	r.Header.Set("Authorization", "Bearer "+loggedOut)
+	w = httptest.NewRecorder()
+	cfg.handlerRefresh(w, r)
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	_, _, err := cfg.rotateRefreshToken(ctx, loggedOut, tokenSession{})
+	if !errors.Is(err, errRefreshTokenRevoked) {
+		t.Errorf("refreshing after logging out: error = %v, want %v", err, errRefreshTokenRevoked)
+	}
+	if n := countTestRows(t, cfg, "SELECT COUNT(*) FROM audit_log WHERE event = 'refresh_token.reused'"); n != 0 {
+		t.Errorf("%d refresh_token.reused events after logging out, want 0", n)
+	}
+	_, _, err = cfg.rotateRefreshToken(ctx, other, tokenSession{})
+	if err != nil {
+		t.Errorf("another session stopped working: error = %v", err)
+	}
+}
+
+func TestHandlerRevokeOnlyFirstPartyTokens(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice")
+	firstParty, _ := startTestSession(t, cfg, user)
+	clientSession := tokenSession{UserID: user.ID, ClientID: "other-client", Scopes: []string{"chirps:read"}}
+	clientToken, err := cfg.issueRefreshToken(context.Background(), cfg.db, &clientSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoke := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/revoke", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		cfg.handlerRevoke(w, r)
+		return w
+	}
+
+	expectStatus(t, revoke(clientToken), http.StatusUnauthorized)
+	token, err := cfg.db.GetRefreshToken(context.Background(), auth.HashRefreshToken(clientToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.RevokedAt.Valid {
+		t.Error("an OAuth client's token was revoked through /api/revoke")
+	}
+
+	expectStatus(t, revoke("unknown-token"), http.StatusUnauthorized)
+	expectStatus(t, revoke(firstParty), http.StatusNoContent)
+}
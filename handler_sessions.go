@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+	ClientID   string    `json:"client_id,omitempty"`
+}
+
+func (cfg *apiConfig) handlerSessionsList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	userID, sessionID := accessToken.UserID, accessToken.SessionID
+
+	dbSessions, err := cfg.db.ListUserSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get sessions", err)
+		return
+	}
+
+	sessions := []Session{}
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, Session{
+			ID:         dbSession.FamilyID,
+			UserAgent:  dbSession.UserAgent,
+			IPAddress:  dbSession.IpAddress,
+			CreatedAt:  dbSession.SessionCreatedAt,
+			LastUsedAt: dbSession.LastUsedAt,
+			ExpiresAt:  dbSession.ExpiresAt,
+			Current:    dbSession.FamilyID == sessionID,
+			ClientID:   dbSession.ClientID.String,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) handlerSessionsRevoke(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	userID := accessToken.UserID
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid session ID", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+		UserID:   userID,
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Session not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerSessionsRevokeOthers logs the user out everywhere except the
+// session the access token was issued from. Personal access tokens
+// aren't issued from a session, so there is nothing to keep.
+func (cfg *apiConfig) handlerSessionsRevokeOthers(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	userID, sessionID := accessToken.UserID, accessToken.SessionID
+	if sessionID == uuid.Nil {
+		respondWithError(w, http.StatusForbidden, "Other sessions can only be revoked from a session", nil)
+		return
+	}
+
+	_, err := cfg.db.RevokeOtherUserSessions(r.Context(), database.RevokeOtherUserSessionsParams{
+		UserID:   userID,
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientIP returns the address of the client that made the request,
+// without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+func TestHandlerSessionsRevokeOthers(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice")
+	current := loginTestUser(t, cfg, user)
+	loginTestUser(t, cfg, user)
+
+	w := serveAuthed(cfg.handlerSessionsRevokeOthers, current, http.MethodDelete, "/api/sessions", "")
+	expectStatus(t, w, http.StatusNoContent)
+
+	sessions, err := cfg.db.ListUserSessions(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].FamilyID != current.SessionID {
+		t.Errorf("sessions left = %d, want only the caller's", len(sessions))
+	}
+}
+
+func TestHandlerSessionsRevokeOthersNeedsSession(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice")
+	loginTestUser(t, cfg, user)
+
+	// A personal access token isn't tied to a session.
+	personal := auth.AccessToken{UserID: user.ID, PersonalTokenID: uuid.New(), Scopes: []string{"profile"}}
+	w := serveAuthed(cfg.handlerSessionsRevokeOthers, personal, http.MethodDelete, "/api/sessions", "")
+	expectStatus(t, w, http.StatusForbidden)
+
+	sessions, err := cfg.db.ListUserSessions(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("sessions left = %d, want 1", len(sessions))
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/hashtag"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/MechamJonathan/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	streamHeartbeat    = 25 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+// handlerStreamChirps streams chirp.created and chirp.deleted events as
+// Server-Sent Events. Streams can be narrowed with repeated author_id and
+// hashtag parameters, and followed=true (which needs a bearer token)
+// adds the authors the user follows. A client that reconnects with
+// Last-Event-ID gets what it missed from the replay buffer; if that's
+// more than the buffer holds it gets a resync event and should refetch
+// GET /api/chirps.
+func (cfg *apiConfig) handlerStreamChirps(w http.ResponseWriter, r *http.Request) {
+	filter, err := cfg.streamFilter(r)
+	var badFilter errStreamFilter
+	if errors.As(err, &badFilter) {
+		switch badFilter.code {
+		case http.StatusUnauthorized:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy"`)
+		case http.StatusForbidden:
+			respondWithInsufficientScope(w, oauth.ScopeChirpsRead)
+			return
+		}
+		respondWithError(w, badFilter.code, badFilter.message, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get followed users", err)
+		return
+	}
+
+	// Browsers send Last-Event-ID when EventSource reconnects; the query
+	// parameter lets a client resume on its first connection too.
+	lastEventID := uint64(0)
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value != "" {
+		lastEventID, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	sub, replay, complete, err := cfg.chirpStream.Subscribe(lastEventID, filter)
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Server is shutting down", err)
+		return
+	}
+	defer cfg.chirpStream.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	write := func(format string, args ...interface{}) error {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		_, err := fmt.Fprintf(w, format, args...)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	err = write("retry: 3000\n\n")
+	if err == nil && !complete {
+		err = write("event: resync\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		if err != nil {
+			break
+		}
+		err = writeStreamEvent(write, event)
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			err = write(": ping\n\n")
+		case event, ok := <-sub.C:
+			// The hub dropped us for falling behind, or is shutting down.
+			// Either way the client reconnects and resumes.
+			if !ok {
+				return
+			}
+			err = writeStreamEvent(write, event)
+		}
+	}
+	log.Printf("Ending chirp stream: %s", err)
+}
+
+func writeStreamEvent(write func(string, ...interface{}) error, event stream.Event) error {
+	return write("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
+
+// errStreamFilter is a stream request we can't serve, as opposed to a
+// failure looking up who the user follows.
+type errStreamFilter struct {
+	code    int
+	message string
+	err     error
+}
+
+func (e errStreamFilter) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+	return e.message + ": " + e.err.Error()
+}
+
+// streamFilter builds the subscription filter from the query string.
+// author_id and followed=true add to the same set of authors.
+func (cfg *apiConfig) streamFilter(r *http.Request) (stream.Filter, error) {
+	query := r.URL.Query()
+	filter := stream.Filter{}
+
+	if query.Has("author_id") {
+		filter.Authors = map[uuid.UUID]bool{}
+		for _, value := range query["author_id"] {
+			authorID, err := uuid.Parse(value)
+			if err != nil {
+				return stream.Filter{}, errStreamFilter{http.StatusBadRequest, "Invalid author ID", err}
+			}
+			filter.Authors[authorID] = true
+		}
+	}
+
+	if query.Get("followed") == "true" {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			return stream.Filter{}, errStreamFilter{http.StatusUnauthorized, "followed=true needs a bearer token", err}
+		}
+		accessToken, err := cfg.validateBearerToken(r.Context(), token)
+		if err != nil {
+			return stream.Filter{}, errStreamFilter{http.StatusUnauthorized, "Couldn't validate JWT", err}
+		}
+		if !accessToken.HasScope(oauth.ScopeChirpsRead) {
+			return stream.Filter{}, errStreamFilter{http.StatusForbidden, "Token doesn't grant the " + oauth.ScopeChirpsRead + " scope", nil}
+		}
+		following, err := cfg.db.ListFollowing(r.Context(), accessToken.UserID)
+		if err != nil {
+			return stream.Filter{}, err
+		}
+		if filter.Authors == nil {
+			filter.Authors = map[uuid.UUID]bool{}
+		}
+		for _, followeeID := range following {
+			filter.Authors[followeeID] = true
+		}
+	}
+
+	if query.Has("hashtag") {
+		filter.Hashtags = map[string]bool{}
+		for _, value := range query["hashtag"] {
+			tag := hashtag.Normalize(value)
+			if tag == "" {
+				return stream.Filter{}, errStreamFilter{http.StatusBadRequest, "Hashtag can't be empty", nil}
+			}
+			filter.Hashtags[tag] = true
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/google/uuid"
+)
+
+type PersonalToken struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// handlerTokensCreate creates a personal access token for scripts and
+// bots. The token itself is only ever in this response. Tokens can only be
+// created from a login session, so a leaked token can't mint more.
+func (cfg *apiConfig) handlerTokensCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+	type response struct {
+		PersonalToken
+		Token string `json:"token"`
+	}
+
+	if !accessToken.FirstParty() {
+		respondWithError(w, http.StatusForbidden, "Tokens must be created from a login session", nil)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "Token name is required", nil)
+		return
+	}
+	scopes, err := oauth.ParseScope(strings.Join(params.Scopes, " "))
+	if err != nil {
+		respondWithInvalidField(w, "scopes", err)
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds can't be negative", nil)
+		return
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(params.ExpiresInSeconds) * time.Second),
+			Valid: true,
+		}
+	}
+
+	token, err := auth.MakePersonalToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create token", err)
+		return
+	}
+
+	personalToken, err := cfg.db.CreatePersonalToken(r.Context(), database.CreatePersonalTokenParams{
+		UserID:    accessToken.UserID,
+		Name:      name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    oauth.FormatScope(scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		PersonalToken: personalTokenFromDB(personalToken),
+		Token:         token,
+	})
+}
+
+func (cfg *apiConfig) handlerTokensList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	dbTokens, err := cfg.db.ListPersonalTokens(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get tokens", err)
+		return
+	}
+
+	tokens := []PersonalToken{}
+	for _, dbToken := range dbTokens {
+		tokens = append(tokens, personalTokenFromDB(dbToken))
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) handlerTokensRevoke(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid token ID", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokePersonalToken(r.Context(), database.RevokePersonalTokenParams{
+		ID:     tokenID,
+		UserID: accessToken.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke token", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Token not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func personalTokenFromDB(token database.PersonalToken) PersonalToken {
+	personalToken := PersonalToken{
+		ID:        token.ID,
+		CreatedAt: token.CreatedAt,
+		Name:      token.Name,
+		Scopes:    strings.Fields(token.Scopes),
+	}
+	if token.ExpiresAt.Valid {
+		personalToken.ExpiresAt = &token.ExpiresAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		personalToken.LastUsedAt = &token.LastUsedAt.Time
+	}
+	return personalToken
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/MechamJonathan/chirpy/internal/auth"
 	"github.com/MechamJonathan/chirpy/internal/database"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type User struct {
@@ -16,6 +20,7 @@ type User struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
+	Username    string    `json:"username,omitempty"`
 	Password    string    `json:"-"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
 }
@@ -24,6 +29,7 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
+		Username string `json:"username"`
 	}
 	type response struct {
 		User
@@ -33,11 +39,23 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithDecodeError(w, err)
 		return
 	}
 
-	HashedPassword, err := auth.HashPassword(params.Password)
+	err = cfg.passwordPolicy.Check(params.Password)
+	if err != nil {
+		respondWithInvalidField(w, "password", err)
+		return
+	}
+
+	username, err := parseUsername(params.Username)
+	if err != nil {
+		respondWithInvalidField(w, "username", err)
+		return
+	}
+
+	HashedPassword, err := cfg.passwords.Hash(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
@@ -46,19 +64,55 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: HashedPassword,
+		Username:       username,
 	})
+	if isUniqueViolation(err, "users_username_key") {
+		respondWithError(w, http.StatusConflict, "Username is taken", err)
+		return
+	}
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, http.StatusConflict, "Email is taken", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error(), err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusCreated, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		User: userFromDB(user, false),
 	})
 }
+
+func userFromDB(user database.User, isChirpyRed bool) User {
+	return User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Username:    user.Username.String,
+		IsChirpyRed: isChirpyRed,
+	}
+}
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// parseUsername lowercases and checks an optional username. Usernames are
+// what @mentions refer to.
+func parseUsername(username string) (sql.NullString, error) {
+	if username == "" {
+		return sql.NullString{}, nil
+	}
+	username = strings.ToLower(username)
+	if !usernamePattern.MatchString(username) {
+		return sql.NullString{}, errors.New("Username must be 3 to 30 letters, digits or underscores")
+	}
+	return sql.NullString{String: username, Valid: true}, nil
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// for the named unique constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
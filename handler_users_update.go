@@ -8,35 +8,39 @@ import (
 	"github.com/MechamJonathan/chirpy/internal/database"
 )
 
-func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request) {
+func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
+		Username string `json:"username"`
 	}
 	type response struct {
 		User
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	userID := accessToken.UserID
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		respondWithDecodeError(w, err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+
+	err = cfg.passwordPolicy.Check(params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithInvalidField(w, "password", err)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	username, err := parseUsername(params.Username)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithInvalidField(w, "username", err)
 		return
 	}
 
-	HashedPassword, err := auth.HashPassword(params.Password)
+	HashedPassword, err := cfg.passwords.Hash(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
@@ -46,18 +50,28 @@ func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request)
 		ID:             userID,
 		Email:          params.Email,
 		HashedPassword: HashedPassword,
+		Username:       username,
 	})
+	if isUniqueViolation(err, "users_username_key") {
+		respondWithError(w, http.StatusConflict, "Username is taken", err)
+		return
+	}
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, http.StatusConflict, "Email is taken", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
 		return
 	}
 
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get subscription", err)
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-		},
+		User: userFromDB(user, isChirpyRed),
 	})
 }
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EventID        uuid.UUID       `json:"event_id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// handlerWebhookEndpointsCreate registers an endpoint for the user's
+// events. The signing secret is only shown in this response.
+func (cfg *apiConfig) handlerWebhookEndpointsCreate(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	type response struct {
+		WebhookEndpoint
+		Secret string `json:"secret"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	endpointURL, err := url.Parse(params.URL)
+	if err != nil || (endpointURL.Scheme != "https" && endpointURL.Scheme != "http") || endpointURL.Host == "" {
+		respondWithError(w, http.StatusBadRequest, "URL must be an absolute http or https URL", err)
+		return
+	}
+	if len(params.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one event is required", nil)
+		return
+	}
+	events := []string{}
+	for _, event := range webhook.Events {
+		if slices.Contains(params.Events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) != len(params.Events) {
+		respondWithError(w, http.StatusBadRequest, "Unknown event, expected one of: "+strings.Join(webhook.Events, ", "), nil)
+		return
+	}
+
+	secret, err := oauth.NewSecret(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create secret", err)
+		return
+	}
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: accessToken.UserID,
+		Url:    endpointURL.String(),
+		Secret: secret,
+		Events: strings.Join(events, " "),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create endpoint", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		WebhookEndpoint: webhookEndpointFromDB(endpoint),
+		Secret:          secret,
+	})
+}
+
+func (cfg *apiConfig) handlerWebhookEndpointsList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	dbEndpoints, err := cfg.db.ListWebhookEndpoints(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get endpoints", err)
+		return
+	}
+
+	endpoints := []WebhookEndpoint{}
+	for _, dbEndpoint := range dbEndpoints {
+		endpoints = append(endpoints, webhookEndpointFromDB(dbEndpoint))
+	}
+
+	respondWithJSON(w, http.StatusOK, endpoints)
+}
+
+func (cfg *apiConfig) handlerWebhookEndpointsDelete(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid endpoint ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: accessToken.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete endpoint", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Endpoint not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerWebhookDeliveriesList is the delivery log for one endpoint,
+// newest first.
+func (cfg *apiConfig) handlerWebhookDeliveriesList(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	const defaultLimit, maxLimit = 50, 200
+
+	endpoint, ok := cfg.getWebhookEndpoint(w, r, accessToken)
+	if !ok {
+		return
+	}
+
+	limit := defaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(parsed, maxLimit)
+	}
+
+	dbDeliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get deliveries", err)
+		return
+	}
+
+	deliveries := []WebhookDelivery{}
+	for _, dbDelivery := range dbDeliveries {
+		deliveries = append(deliveries, webhookDeliveryFromDB(dbDelivery))
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// handlerWebhookDeliveriesReplay queues a past delivery again. The replay
+// is a new delivery with the same event ID, so the log keeps both.
+func (cfg *apiConfig) handlerWebhookDeliveriesReplay(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+	endpoint, ok := cfg.getWebhookEndpoint(w, r, accessToken)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid delivery ID", err)
+		return
+	}
+	delivery, err := cfg.db.GetWebhookDelivery(r.Context(), database.GetWebhookDeliveryParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Delivery not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get delivery", err)
+		return
+	}
+	if delivery.Status == string(webhook.StatusPending) {
+		respondWithError(w, http.StatusConflict, "Delivery is still being retried", nil)
+		return
+	}
+
+	replay, err := cfg.db.ReplayWebhookDelivery(r.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't replay delivery", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, webhookDeliveryFromDB(replay))
+}
+
+// getWebhookEndpoint loads the endpoint named in the path, responding
+// with 404 if the user doesn't own it.
+func (cfg *apiConfig) getWebhookEndpoint(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid endpoint ID", err)
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: accessToken.UserID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Endpoint not found", err)
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get endpoint", err)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func webhookEndpointFromDB(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:        endpoint.ID,
+		CreatedAt: endpoint.CreatedAt,
+		URL:       endpoint.Url,
+		Events:    strings.Fields(endpoint.Events),
+	}
+}
+
+func webhookDeliveryFromDB(delivery database.WebhookDelivery) WebhookDelivery {
+	result := WebhookDelivery{
+		ID:             delivery.ID,
+		CreatedAt:      delivery.CreatedAt,
+		EventID:        delivery.EventID,
+		Event:          delivery.Event,
+		Status:         delivery.Status,
+		Attempts:       int(delivery.Attempts),
+		ResponseStatus: int(delivery.ResponseStatus),
+		LastError:      delivery.LastError,
+		Payload:        json.RawMessage(delivery.Payload),
+	}
+	if delivery.Status == string(webhook.StatusPending) {
+		result.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		result.LastAttemptAt = &delivery.LastAttemptAt.Time
+	}
+	return result
+}
@@ -1,54 +1,265 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"time"
 
 	"database/sql"
 
-	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/subscription"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
 	"github.com/google/uuid"
 )
 
+const maxWebhookBodyBytes = 1 << 20
+
+// errWebhookRejected is a permanent failure: retrying the same event
+// can't succeed, so we answer with a 4xx to stop Polka retrying and keep
+// the event as a dead letter for an admin to look at. Any other error is
+// transient and answered with a 500 so Polka tries again.
+type errWebhookRejected struct {
+	code    int
+	message string
+	err     error
+}
+
+func (e errWebhookRejected) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+	return e.message + ": " + e.err.Error()
+}
+
+func (e errWebhookRejected) Unwrap() error {
+	return e.err
+}
+
+// polkaEvent is the body of a Polka webhook.
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           uuid.UUID `json:"user_id"`
+		Plan             string    `json:"plan"`
+		CurrentPeriodEnd time.Time `json:"current_period_end"`
+	}
+}
+
+// handlerWebHook applies Polka events. Deliveries must be signed with
+// POLKA_KEY (see internal/webhook). Each event ID is applied once:
+// retries of an event we've already processed are acknowledged without
+// doing anything.
 func (cfg *apiConfig) handlerWebHook(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read body", err)
+		return
 	}
 
-	apiKey, err := auth.GetAPIKey(r.Header)
+	// Unsigned deliveries aren't dead-lettered: anyone can send them.
+	err = webhook.Verify(cfg.polkaKey, r.Header.Get("Polka-Signature"), body, time.Now(), cfg.polkaTolerance)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get ApiKey from header", err)
+		respondWithError(w, http.StatusUnauthorized, "Couldn't verify signature", err)
 		return
 	}
-	if apiKey != cfg.polkaKey {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't verify ApiKey", err)
+
+	err = cfg.processPolkaEvent(r.Context(), body)
+	var rejected errWebhookRejected
+	if errors.As(err, &rejected) {
+		cfg.recordDeadLetter(r.Context(), body, rejected)
+		respondWithError(w, rejected.code, rejected.message, err)
 		return
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't process event", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// processPolkaEvent applies a verified Polka event once. Errors are
+// errWebhookRejected when retrying can't help.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, body []byte) error {
+	event := polkaEvent{}
+	err := json.Unmarshal(body, &event)
+	if err != nil {
+		return errWebhookRejected{http.StatusBadRequest, "Couldn't decode parameters", err}
+	}
+	if event.ID == "" {
+		return errWebhookRejected{http.StatusBadRequest, "Event ID is required", nil}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Recording the event first holds its row lock until we commit, so a
+	// concurrent retry of the same event waits and then sees it as done.
+	recorded, err := qtx.RecordWebhookEvent(ctx, database.RecordWebhookEventParams{
+		Source: "polka",
+		ID:     event.ID,
+		Event:  event.Event,
+	})
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		return nil
+	}
+
+	if subscription.Handles(event.Event) {
+		err = cfg.applySubscriptionEvent(ctx, qtx, event.Data.UserID, subscription.Event{
+			Type:      event.Event,
+			Plan:      event.Data.Plan,
+			PeriodEnd: event.Data.CurrentPeriodEnd.UTC(),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errWebhookRejected{http.StatusNotFound, "Couldn't find user", err}
+		}
+		if errors.Is(err, subscription.ErrNoSubscription) {
+			return errWebhookRejected{http.StatusUnprocessableEntity, "User has no subscription", err}
+		}
+		if err != nil {
+			return err
+		}
+	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	return tx.Commit()
+}
+
+// recordDeadLetter keeps a rejected event so an admin can inspect it and
+// replay it once the cause is fixed.
+func (cfg *apiConfig) recordDeadLetter(ctx context.Context, body []byte, rejected errWebhookRejected) {
+	// The body may not be valid JSON, so take what we can from it.
+	event := polkaEvent{}
+	json.Unmarshal(body, &event)
+
+	_, err := cfg.db.CreateWebhookDeadLetter(ctx, database.CreateWebhookDeadLetterParams{
+		Source:     "polka",
+		EventID:    event.ID,
+		Event:      event.Event,
+		Payload:    string(body),
+		StatusCode: int32(rejected.code),
+		Error:      rejected.Error(),
+	})
+	if err != nil {
+		log.Printf("Couldn't record dead letter for event %q: %s", event.ID, err)
+	}
+}
+
+type WebhookDeadLetter struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Source     string     `json:"source"`
+	EventID    string     `json:"event_id"`
+	Event      string     `json:"event"`
+	Payload    string     `json:"payload"`
+	StatusCode int        `json:"status_code"`
+	Error      string     `json:"error"`
+	ReplayedAt *time.Time `json:"replayed_at"`
+}
+
+// handlerWebhookDeadLettersList lists rejected events, newest first.
+// Replayed ones are hidden unless ?include_replayed=true.
+func (cfg *apiConfig) handlerWebhookDeadLettersList(w http.ResponseWriter, r *http.Request) {
+	dbDeadLetters, err := cfg.db.ListWebhookDeadLetters(r.Context(), database.ListWebhookDeadLettersParams{
+		IncludeReplayed: r.URL.Query().Get("include_replayed") == "true",
+		MaxResults:      200,
+	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get dead letters", err)
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	deadLetters := []WebhookDeadLetter{}
+	for _, dbDeadLetter := range dbDeadLetters {
+		deadLetters = append(deadLetters, webhookDeadLetterFromDB(dbDeadLetter))
+	}
+
+	respondWithJSON(w, http.StatusOK, deadLetters)
+}
+
+// handlerWebhookDeadLettersReplay processes a dead letter again, for
+// example after creating the user it was missing. If it's rejected again
+// the dead letter stays open with the new error.
+func (cfg *apiConfig) handlerWebhookDeadLettersReplay(w http.ResponseWriter, r *http.Request) {
+	deadLetterID, err := uuid.Parse(r.PathValue("deadLetterID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Invalid dead letter ID", err)
 		return
 	}
 
-	_, err = cfg.db.UpgradeToChirpyRedById(r.Context(), params.Data.UserID)
+	deadLetter, err := cfg.db.GetWebhookDeadLetter(r.Context(), deadLetterID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Dead letter not found", err)
+		return
+	}
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get dead letter", err)
+		return
+	}
+	if deadLetter.ReplayedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Dead letter was already replayed", nil)
+		return
+	}
+
+	err = cfg.processPolkaEvent(r.Context(), []byte(deadLetter.Payload))
+	var rejected errWebhookRejected
+	if errors.As(err, &rejected) {
+		updateErr := cfg.db.UpdateWebhookDeadLetterError(r.Context(), database.UpdateWebhookDeadLetterErrorParams{
+			ID:         deadLetter.ID,
+			StatusCode: int32(rejected.code),
+			Error:      rejected.Error(),
+		})
+		if updateErr != nil {
+			log.Printf("Couldn't update dead letter %s: %s", deadLetter.ID, updateErr)
 		}
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		respondWithError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Replay rejected: %s", rejected.message), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't process event", err)
+		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	err = cfg.db.MarkWebhookDeadLetterReplayed(r.Context(), deadLetter.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update dead letter", err)
+		return
+	}
+	deadLetter, err = cfg.db.GetWebhookDeadLetter(r.Context(), deadLetter.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get dead letter", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookDeadLetterFromDB(deadLetter))
+}
+
+func webhookDeadLetterFromDB(deadLetter database.WebhookDeadLetter) WebhookDeadLetter {
+	result := WebhookDeadLetter{
+		ID:         deadLetter.ID,
+		CreatedAt:  deadLetter.CreatedAt,
+		Source:     deadLetter.Source,
+		EventID:    deadLetter.EventID,
+		Event:      deadLetter.Event,
+		Payload:    deadLetter.Payload,
+		StatusCode: int(deadLetter.StatusCode),
+		Error:      deadLetter.Error,
+	}
+	if deadLetter.ReplayedAt.Valid {
+		result.ReplayedAt = &deadLetter.ReplayedAt.Time
+	}
+	return result
 }
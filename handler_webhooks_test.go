@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// postPolka sends body to the Polka webhook, signed with key unless key
+// is empty.
+func postPolka(cfg *apiConfig, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set("Polka-Signature", webhook.Sign(key, time.Now(), []byte(body)))
+	}
+	w := httptest.NewRecorder()
+	cfg.handlerWebHook(w, r)
+	return w
+}
+
+// serveAdmin calls h behind middlewareAdmin with the given API key.
+func serveAdmin(cfg *apiConfig, h http.HandlerFunc, apiKey, method, target string, pathValues ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	if apiKey != "" {
+		r.Header.Set("Authorization", "ApiKey "+apiKey)
+	}
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		r.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	w := httptest.NewRecorder()
+	cfg.middlewareAdmin(h)(w, r)
+	return w
+}
+
+func listDeadLetters(t *testing.T, cfg *apiConfig, target string) []WebhookDeadLetter {
+	t.Helper()
+	w := serveAdmin(cfg, cfg.handlerWebhookDeadLettersList, cfg.adminKey, http.MethodGet, target)
+	expectStatus(t, w, http.StatusOK)
+	deadLetters := []WebhookDeadLetter{}
+	err := json.Unmarshal(w.Body.Bytes(), &deadLetters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deadLetters
+}
+
+func polkaBody(id, event string, userID uuid.UUID) string {
+	return fmt.Sprintf(`{"id": %q, "event": %q, "data": {"user_id": %q}}`, id, event, userID)
+}
+
+func TestMiddlewareAdmin(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	disabled := &apiConfig{}
+	expectStatus(t, serveAdmin(disabled, next, "anything", http.MethodGet, "/admin/webhooks/dead-letters"), http.StatusForbidden)
+
+	cfg := &apiConfig{adminKey: "admin-key"}
+	expectStatus(t, serveAdmin(cfg, next, "", http.MethodGet, "/admin/webhooks/dead-letters"), http.StatusUnauthorized)
+	expectStatus(t, serveAdmin(cfg, next, "wrong-key", http.MethodGet, "/admin/webhooks/dead-letters"), http.StatusUnauthorized)
+	expectStatus(t, serveAdmin(cfg, next, "admin-key", http.MethodGet, "/admin/webhooks/dead-letters"), http.StatusNoContent)
+}
+
+func TestHandlerWebHookFailures(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice")
+
+	tests := []struct {
+		name       string
+		key        string
+		body       string
+		status     int
+		deadLetter bool
+	}{
+		{"unsigned", "", polkaBody("evt-1", "user.upgraded", user.ID), http.StatusUnauthorized, false},
+		{"wrong key", "not-the-key", polkaBody("evt-1", "user.upgraded", user.ID), http.StatusUnauthorized, false},
+		{"not JSON", cfg.polkaKey, `{"id": `, http.StatusBadRequest, true},
+		{"no event ID", cfg.polkaKey, polkaBody("", "user.upgraded", user.ID), http.StatusBadRequest, true},
+		{"unknown user", cfg.polkaKey, polkaBody("evt-2", "user.upgraded", uuid.New()), http.StatusNotFound, true},
+		{"no subscription", cfg.polkaKey, polkaBody("evt-3", "subscription.renewed", user.ID), http.StatusUnprocessableEntity, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(listDeadLetters(t, cfg, "/admin/webhooks/dead-letters"))
+			w := postPolka(cfg, tt.key, tt.body)
+			expectStatus(t, w, tt.status)
+
+			deadLetters := listDeadLetters(t, cfg, "/admin/webhooks/dead-letters")
+			if got := len(deadLetters) > before; got != tt.deadLetter {
+				t.Fatalf("dead letter recorded = %v, want %v", got, tt.deadLetter)
+			}
+			if tt.deadLetter && (deadLetters[0].Payload != tt.body || deadLetters[0].StatusCode != tt.status) {
+				t.Errorf("dead letter = %+v, want the body and status", deadLetters[0])
+			}
+		})
+	}
+}
+
+func TestHandlerWebHookAppliesEventOnce(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	user := createTestUser(t, cfg, "alice")
+
+	body := polkaBody("evt-1", "user.upgraded", user.ID)
+	expectStatus(t, postPolka(cfg, cfg.polkaKey, body), http.StatusNoContent)
+	if red, err := cfg.isChirpyRed(ctx, user.ID); err != nil || !red {
+		t.Fatalf("isChirpyRed() = %v, %v after upgrade", red, err)
+	}
+
+	// A retry of an event already applied is acknowledged and ignored,
+	// even if it would now fail.
+	expectStatus(t, postPolka(cfg, cfg.polkaKey, polkaBody("evt-2", "user.downgraded", user.ID)), http.StatusNoContent)
+	expectStatus(t, postPolka(cfg, cfg.polkaKey, body), http.StatusNoContent)
+	if red, err := cfg.isChirpyRed(ctx, user.ID); err != nil || red {
+		t.Fatalf("isChirpyRed() = %v, %v; a retried upgrade was applied again", red, err)
+	}
+}
+
+func TestHandlerWebhookDeadLettersReplay(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := createTestUser(t, cfg, "alice")
+	expectStatus(t, postPolka(cfg, cfg.polkaKey, polkaBody("evt-1", "subscription.renewed", user.ID)), http.StatusUnprocessableEntity)
+
+	deadLetters := listDeadLetters(t, cfg, "/admin/webhooks/dead-letters")
+	if len(deadLetters) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(deadLetters))
+	}
+	id := deadLetters[0].ID.String()
+	replay := func(apiKey string) *httptest.ResponseRecorder {
+		return serveAdmin(cfg, cfg.handlerWebhookDeadLettersReplay, apiKey, http.MethodPost,
+			"/admin/webhooks/dead-letters/"+id+"/replay", "deadLetterID", id)
+	}
+
+	expectStatus(t, replay(""), http.StatusUnauthorized)
+
+	// Still no subscription to renew, so it stays open.
+	expectStatus(t, replay(cfg.adminKey), http.StatusUnprocessableEntity)
+	if got := listDeadLetters(t, cfg, "/admin/webhooks/dead-letters"); len(got) != 1 {
+		t.Fatalf("%d open dead letters after a failed replay, want 1", len(got))
+	}
+
+	expectStatus(t, postPolka(cfg, cfg.polkaKey, polkaBody("evt-2", "user.upgraded", user.ID)), http.StatusNoContent)
+	w := replay(cfg.adminKey)
+	expectStatus(t, w, http.StatusOK)
+	replayed := WebhookDeadLetter{}
+	json.Unmarshal(w.Body.Bytes(), &replayed)
+	if replayed.ReplayedAt == nil {
+		t.Errorf("replayed dead letter = %+v, want replayed_at set", replayed)
+	}
+
+	expectStatus(t, replay(cfg.adminKey), http.StatusConflict)
+	if got := listDeadLetters(t, cfg, "/admin/webhooks/dead-letters"); len(got) != 0 {
+		t.Errorf("%d open dead letters after replaying, want 0", len(got))
+	}
+	if got := listDeadLetters(t, cfg, "/admin/webhooks/dead-letters?include_replayed=true"); len(got) != 1 {
+		t.Errorf("%d dead letters including replayed, want 1", len(got))
+	}
+
+	missing := uuid.New().String()
+	w = serveAdmin(cfg, cfg.handlerWebhookDeadLettersReplay, cfg.adminKey, http.MethodPost,
+		"/admin/webhooks/dead-letters/"+missing+"/replay", "deadLetterID", missing)
+	expectStatus(t, w, http.StatusNotFound)
+}
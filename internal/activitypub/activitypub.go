@@ -0,0 +1,200 @@
+// Package activitypub federates Chirpy with other fediverse servers: it
+// serves WebFinger, actors, outboxes and inboxes, signs and verifies
+// requests with HTTP Signatures, and queues deliveries to remote inboxes.
+// What activities mean for Chirpy's own data is up to a Backend.
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ContentType is the media type of ActivityPub documents.
+const ContentType = "application/activity+json"
+
+const (
+	contextActivityStreams = "https://www.w3.org/ns/activitystreams"
+	contextSecurity        = "https://w3id.org/security/v1"
+	// Public addresses an activity to everyone.
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// ErrNotFound is returned by a Backend for users, notes and actors it
+// doesn't have.
+var ErrNotFound = errors.New("not found")
+
+// Actor is a user's ActivityPub profile.
+type Actor struct {
+	Context           interface{} `json:"@context,omitempty"`
+	ID                string      `json:"id"`
+	Type              string      `json:"type"`
+	PreferredUsername string      `json:"preferredUsername"`
+	Inbox             string      `json:"inbox"`
+	Outbox            string      `json:"outbox"`
+	Followers         string      `json:"followers,omitempty"`
+	Endpoints         *Endpoints  `json:"endpoints,omitempty"`
+	PublicKey         PublicKey   `json:"publicKey"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPEM string `json:"publicKeyPem"`
+}
+
+// Activity is any activity. Object is either an embedded object or the
+// ID of one; ObjectID reads it either way.
+type Activity struct {
+	Context   interface{}     `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+	Published *time.Time      `json:"published,omitempty"`
+}
+
+// ObjectID returns the ID of the activity's object.
+func (a Activity) ObjectID() string {
+	id := ""
+	if json.Unmarshal(a.Object, &id) == nil {
+		return id
+	}
+	object := struct {
+		ID string `json:"id"`
+	}{}
+	json.Unmarshal(a.Object, &object)
+	return object.ID
+}
+
+// Note is a chirp as ActivityPub sees it.
+type Note struct {
+	Context      interface{} `json:"@context,omitempty"`
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	AttributedTo string      `json:"attributedTo"`
+	Content      string      `json:"content"`
+	InReplyTo    string      `json:"inReplyTo,omitempty"`
+	Published    time.Time   `json:"published"`
+	To           []string    `json:"to,omitempty"`
+	Cc           []string    `json:"cc,omitempty"`
+}
+
+// OrderedCollection is an outbox or followers collection.
+type OrderedCollection struct {
+	Context      interface{}   `json:"@context,omitempty"`
+	ID           string        `json:"id"`
+	Type         string        `json:"type"`
+	TotalItems   int           `json:"totalItems"`
+	OrderedItems []interface{} `json:"orderedItems,omitempty"`
+}
+
+// LocalUser is a Chirpy user as seen from other servers. Only users with
+// a username are federated.
+type LocalUser struct {
+	ID           uuid.UUID
+	Username     string
+	PublicKeyPEM string
+	PrivateKey   *rsa.PrivateKey
+}
+
+// LocalNote is a chirp by a local user.
+type LocalNote struct {
+	ID        uuid.UUID
+	AuthorID  uuid.UUID
+	Body      string
+	Published time.Time
+	// InReplyTo is the ID of the note replied to, local or remote, and
+	// ReplyTo its author if they're remote, so the reply reaches them.
+	InReplyTo string
+	ReplyTo   *RemoteActor
+}
+
+// RemoteActor is a user on another server. Each is given a local user,
+// UserID, so that their follows, likes and replies fit Chirpy's own
+// tables.
+type RemoteActor struct {
+	UserID       uuid.UUID
+	ID           string
+	Inbox        string
+	SharedInbox  string
+	PublicKeyID  string
+	PublicKeyPEM string
+}
+
+// RemoteNote is a note from another server that's stored as a chirp.
+type RemoteNote struct {
+	ID        string
+	Content   string
+	Published time.Time
+	// InReplyTo is the local chirp it replies to, if any.
+	InReplyTo uuid.NullUUID
+}
+
+// Backend connects federation to Chirpy's data.
+type Backend interface {
+	LocalUser(ctx context.Context, username string) (LocalUser, error)
+	LocalUserByID(ctx context.Context, id uuid.UUID) (LocalUser, error)
+	// Notes returns up to limit of the user's newest chirps, newest first.
+	Notes(ctx context.Context, userID uuid.UUID, limit int) ([]LocalNote, error)
+	Note(ctx context.Context, id uuid.UUID) (LocalNote, error)
+
+	RemoteActor(ctx context.Context, id string) (RemoteActor, error)
+	// SaveRemoteActor stores an actor fetched from its server, creating
+	// its local user the first time, and returns it with UserID set.
+	SaveRemoteActor(ctx context.Context, actor RemoteActor) (RemoteActor, error)
+
+	AddFollower(ctx context.Context, userID uuid.UUID, follower RemoteActor) error
+	RemoveFollower(ctx context.Context, userID uuid.UUID, follower RemoteActor) error
+	Followers(ctx context.Context, userID uuid.UUID) ([]RemoteActor, error)
+	// AddPendingFollow records the ID of a Follow userID sent to
+	// followee, so that its Accept can be matched to it.
+	AddPendingFollow(ctx context.Context, id string, userID uuid.UUID, followee RemoteActor) error
+	// RemovePendingFollows forgets userID's unaccepted Follows of followee.
+	RemovePendingFollows(ctx context.Context, userID uuid.UUID, followee RemoteActor) error
+	// AddFollowing records that followee accepted the pending Follow with
+	// ID followID. It returns ErrNotFound if no such Follow was sent to
+	// them.
+	AddFollowing(ctx context.Context, followID string, followee RemoteActor) error
+	// IsFollowed reports whether any local user follows the actor.
+	IsFollowed(ctx context.Context, actor RemoteActor) (bool, error)
+
+	AddLike(ctx context.Context, chirpID uuid.UUID, actor RemoteActor) error
+	RemoveLike(ctx context.Context, chirpID uuid.UUID, actor RemoteActor) error
+	// AddNote stores a remote note as a chirp, once.
+	AddNote(ctx context.Context, actor RemoteActor, note RemoteNote) error
+	// RemoveNote deletes the chirp stored from the actor's note with ID
+	// id, if there is one.
+	RemoveNote(ctx context.Context, actor RemoteActor, id string) error
+}
+
+var (
+	tagPattern       = regexp.MustCompile(`<[^>]*>`)
+	paragraphPattern = regexp.MustCompile(`(?i)</p>\s*<p[^>]*>|<br\s*/?>`)
+)
+
+// PlainText turns the HTML content of a remote note into a chirp body.
+func PlainText(content string) string {
+	content = paragraphPattern.ReplaceAllString(content, "\n")
+	content = tagPattern.ReplaceAllString(content, "")
+	return strings.TrimSpace(html.UnescapeString(content))
+}
+
+// HTML turns a chirp body into note content.
+func HTML(body string) string {
+	lines := strings.Split(html.EscapeString(body), "\n")
+	return "<p>" + strings.Join(lines, "<br>") + "</p>"
+}
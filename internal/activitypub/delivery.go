@@ -0,0 +1,77 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// Delivery is one activity queued for one remote inbox, signed with the
+// key of the local user who sent it.
+type Delivery struct {
+	webhook.Queued
+	SenderID uuid.UUID
+	Inbox    string
+	Payload  []byte
+}
+
+// Store is the persistent delivery queue.
+type Store interface {
+	webhook.Store[Delivery]
+	Enqueue(ctx context.Context, delivery Delivery) error
+}
+
+// Run sends due deliveries every interval until ctx is done.
+func (s *Server) Run(ctx context.Context, interval time.Duration) {
+	s.dispatcher.Run(ctx, interval)
+}
+
+// RunOnce sends one batch of due deliveries and returns how many it
+// attempted.
+func (s *Server) RunOnce(ctx context.Context) (int, error) {
+	return s.dispatcher.RunOnce(ctx)
+}
+
+// newDispatcher returns a dispatcher for s.queue that signs deliveries as
+// their sender.
+func (s *Server) newDispatcher() *webhook.Dispatcher[Delivery, *Delivery] {
+	dispatcher := webhook.NewDispatcherFunc("ActivityPub", s.queue, s.policy, s.send)
+	dispatcher.Now = func() time.Time { return s.now() }
+	return dispatcher
+}
+
+func (s *Server) send(ctx context.Context, delivery Delivery, now time.Time) (int, error) {
+	sender, err := s.backend.LocalUserByID(ctx, delivery.SenderID)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get sender: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Inbox, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	err = Sign(req, s.keyID(sender.Username), sender.PrivateKey, delivery.Payload, now)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("inbox responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,701 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/problem"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// memoryBackend is a Chirpy instance's data, kept in maps.
+type memoryBackend struct {
+	mu          sync.Mutex
+	users       map[string]LocalUser
+	notes       map[uuid.UUID]LocalNote
+	actors      map[string]RemoteActor
+	followers   map[uuid.UUID]map[string]RemoteActor
+	pending     map[string]pendingFollow
+	following   map[uuid.UUID][]string
+	likes       map[uuid.UUID][]string
+	remoteNotes []RemoteNote
+	noteAuthors map[string]string
+}
+
+// pendingFollow is a Follow sent to a remote actor.
+type pendingFollow struct {
+	userID  uuid.UUID
+	actorID string
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		users:       map[string]LocalUser{},
+		notes:       map[uuid.UUID]LocalNote{},
+		actors:      map[string]RemoteActor{},
+		followers:   map[uuid.UUID]map[string]RemoteActor{},
+		pending:     map[string]pendingFollow{},
+		following:   map[uuid.UUID][]string{},
+		likes:       map[uuid.UUID][]string{},
+		noteAuthors: map[string]string{},
+	}
+}
+
+func (b *memoryBackend) LocalUser(ctx context.Context, username string) (LocalUser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	user, ok := b.users[username]
+	if !ok {
+		return LocalUser{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (b *memoryBackend) LocalUserByID(ctx context.Context, id uuid.UUID) (LocalUser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, user := range b.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return LocalUser{}, ErrNotFound
+}
+
+func (b *memoryBackend) Notes(ctx context.Context, userID uuid.UUID, limit int) ([]LocalNote, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	notes := []LocalNote{}
+	for _, note := range b.notes {
+		if note.AuthorID == userID {
+			notes = append(notes, note)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Published.After(notes[j].Published) })
+	if len(notes) > limit {
+		notes = notes[:limit]
+	}
+	return notes, nil
+}
+
+func (b *memoryBackend) Note(ctx context.Context, id uuid.UUID) (LocalNote, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	note, ok := b.notes[id]
+	if !ok {
+		return LocalNote{}, ErrNotFound
+	}
+	return note, nil
+}
+
+func (b *memoryBackend) RemoteActor(ctx context.Context, id string) (RemoteActor, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	actor, ok := b.actors[id]
+	if !ok {
+		return RemoteActor{}, ErrNotFound
+	}
+	return actor, nil
+}
+
+func (b *memoryBackend) SaveRemoteActor(ctx context.Context, actor RemoteActor) (RemoteActor, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	actor.UserID = uuid.New()
+	if existing, ok := b.actors[actor.ID]; ok {
+		actor.UserID = existing.UserID
+	}
+	b.actors[actor.ID] = actor
+	return actor, nil
+}
+
+func (b *memoryBackend) AddFollower(ctx context.Context, userID uuid.UUID, follower RemoteActor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.followers[userID] == nil {
+		b.followers[userID] = map[string]RemoteActor{}
+	}
+	b.followers[userID][follower.ID] = follower
+	return nil
+}
+
+func (b *memoryBackend) RemoveFollower(ctx context.Context, userID uuid.UUID, follower RemoteActor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.followers[userID], follower.ID)
+	return nil
+}
+
+func (b *memoryBackend) Followers(ctx context.Context, userID uuid.UUID) ([]RemoteActor, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	followers := []RemoteActor{}
+	for _, follower := range b.followers[userID] {
+		followers = append(followers, follower)
+	}
+	return followers, nil
+}
+
+func (b *memoryBackend) AddPendingFollow(ctx context.Context, id string, userID uuid.UUID, followee RemoteActor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[id] = pendingFollow{userID: userID, actorID: followee.ID}
+	return nil
+}
+
+func (b *memoryBackend) RemovePendingFollows(ctx context.Context, userID uuid.UUID, followee RemoteActor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, follow := range b.pending {
+		if follow.userID == userID && follow.actorID == followee.ID {
+			delete(b.pending, id)
+		}
+	}
+	return nil
+}
+
+func (b *memoryBackend) AddFollowing(ctx context.Context, followID string, followee RemoteActor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	follow, ok := b.pending[followID]
+	if !ok || follow.actorID != followee.ID {
+		return ErrNotFound
+	}
+	delete(b.pending, followID)
+	b.following[follow.userID] = append(b.following[follow.userID], followee.ID)
+	return nil
+}
+
+func (b *memoryBackend) IsFollowed(ctx context.Context, actor RemoteActor) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, followees := range b.following {
+		for _, id := range followees {
+			if id == actor.ID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (b *memoryBackend) AddLike(ctx context.Context, chirpID uuid.UUID, actor RemoteActor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.likes[chirpID] = append(b.likes[chirpID], actor.ID)
+	return nil
+}
+
+func (b *memoryBackend) RemoveLike(ctx context.Context, chirpID uuid.UUID, actor RemoteActor) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.likes[chirpID] = slices.DeleteFunc(b.likes[chirpID], func(id string) bool { return id == actor.ID })
+	return nil
+}
+
+func (b *memoryBackend) AddNote(ctx context.Context, actor RemoteActor, note RemoteNote) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remoteNotes = append(b.remoteNotes, note)
+	b.noteAuthors[note.ID] = actor.ID
+	return nil
+}
+
+func (b *memoryBackend) RemoveNote(ctx context.Context, actor RemoteActor, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.noteAuthors[id] != actor.ID {
+		return nil
+	}
+	delete(b.noteAuthors, id)
+	b.remoteNotes = slices.DeleteFunc(b.remoteNotes, func(note RemoteNote) bool { return note.ID == id })
+	return nil
+}
+
+// instance is one Chirpy server running in httptest.
+type instance struct {
+	server  *Server
+	backend *memoryBackend
+	queue   *MemoryStore
+	url     string
+	// failing makes every POST fail, as if the server were down.
+	failing atomic.Bool
+}
+
+func newInstance(t *testing.T, usernames ...string) *instance {
+	t.Helper()
+	inst := &instance{backend: newMemoryBackend(), queue: NewMemoryStore()}
+	mux := http.NewServeMux()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && inst.failing.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(httpServer.Close)
+	inst.url = httpServer.URL
+
+	policy := webhook.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	srv, err := NewServer(httpServer.URL, inst.backend, inst.queue, webhook.NewClient(true), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Register(mux)
+	inst.server = srv
+
+	for _, username := range usernames {
+		publicPEM, privatePEM := testKey(t)
+		key, err := ParsePrivateKey(privatePEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst.backend.users[username] = LocalUser{ID: uuid.New(), Username: username, PublicKeyPEM: publicPEM, PrivateKey: key}
+	}
+	return inst
+}
+
+func (inst *instance) user(username string) LocalUser {
+	return inst.backend.users[username]
+}
+
+// flush sends everything the instance has queued.
+func (inst *instance) flush(t *testing.T) {
+	t.Helper()
+	for {
+		n, err := inst.server.RunOnce(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			return
+		}
+	}
+}
+
+// post sends activity to an inbox signed by a user of inst and returns
+// the response status.
+func (inst *instance) post(t *testing.T, username, inbox string, activity Activity) int {
+	t.Helper()
+	if username == "" {
+		return postSigned(t, "", nil, inbox, activity)
+	}
+	return postSigned(t, inst.server.keyID(username), inst.user(username).PrivateKey, inbox, activity)
+}
+
+// postSigned sends activity to an inbox signed with key under keyID, or
+// unsigned if key is nil, and returns the response status.
+func postSigned(t *testing.T, keyID string, key *rsa.PrivateKey, inbox string, activity Activity) int {
+	t.Helper()
+	body, _ := json.Marshal(activity)
+	req, err := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if key != nil {
+		err = Sign(req, keyID, key, body, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWebFinger(t *testing.T) {
+	a := newInstance(t, "alice")
+	host := strings.TrimPrefix(a.url, "http://")
+
+	resp, err := http.Get(a.url + "/.well-known/webfinger?resource=" + url.QueryEscape("acct:alice@"+host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	jrd := struct {
+		Subject string `json:"subject"`
+		Links   []struct {
+			Rel  string `json:"rel"`
+			Href string `json:"href"`
+		} `json:"links"`
+	}{}
+	json.NewDecoder(resp.Body).Decode(&jrd)
+	if resp.StatusCode != http.StatusOK || len(jrd.Links) != 1 || jrd.Links[0].Href != a.server.ActorID("alice") {
+		t.Fatalf("got %d %+v, want alice's actor", resp.StatusCode, jrd)
+	}
+
+	for _, resource := range []string{"acct:nobody@" + host, "acct:alice@elsewhere.example"} {
+		resp, err := http.Get(a.url + "/.well-known/webfinger?resource=" + url.QueryEscape(resource))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", resource, resp.StatusCode)
+		}
+	}
+}
+
+func TestFederation(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t, "alice")
+	b := newInstance(t, "bob")
+	alice, bob := a.user("alice"), b.user("bob")
+	aliceID := a.server.ActorID("alice")
+
+	// Bob follows Alice, and Alice's server accepts.
+	err := b.server.Follow(ctx, bob.ID, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.flush(t)
+	if followers, _ := a.backend.Followers(ctx, alice.ID); len(followers) != 1 || followers[0].ID != b.server.ActorID("bob") {
+		t.Fatalf("alice's followers = %+v, want bob", followers)
+	}
+	a.flush(t)
+	if following := b.backend.following[bob.ID]; len(following) != 1 || following[0] != aliceID {
+		t.Fatalf("bob follows %v, want alice", following)
+	}
+
+	// Alice's chirp reaches Bob's server.
+	note := LocalNote{ID: uuid.New(), AuthorID: alice.ID, Body: "hello <fediverse>", Published: time.Now()}
+	a.backend.notes[note.ID] = note
+	err = a.server.PublishNote(ctx, note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.flush(t)
+	if len(b.backend.remoteNotes) != 1 || b.backend.remoteNotes[0].Content != "hello <fediverse>" || b.backend.remoteNotes[0].ID != a.server.NoteID(note.ID) {
+		t.Fatalf("bob's server has %+v, want alice's note", b.backend.remoteNotes)
+	}
+
+	// Bob replies and likes it.
+	aliceRemote, err := b.backend.RemoteActor(ctx, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := LocalNote{
+		ID:        uuid.New(),
+		AuthorID:  bob.ID,
+		Body:      "hi alice",
+		Published: time.Now(),
+		InReplyTo: a.server.NoteID(note.ID),
+		ReplyTo:   &aliceRemote,
+	}
+	b.backend.notes[reply.ID] = reply
+	err = b.server.PublishNote(ctx, reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.server.Like(ctx, bob.ID, a.server.NoteID(note.ID), aliceRemote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.flush(t)
+	if len(a.backend.remoteNotes) != 1 || a.backend.remoteNotes[0].InReplyTo.UUID != note.ID {
+		t.Errorf("alice's server has %+v, want bob's reply", a.backend.remoteNotes)
+	}
+	if likes := a.backend.likes[note.ID]; len(likes) != 1 || likes[0] != b.server.ActorID("bob") {
+		t.Errorf("likes = %v, want bob's", likes)
+	}
+
+	// Bob unfollows.
+	err = b.server.Unfollow(ctx, bob.ID, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.flush(t)
+	if followers, _ := a.backend.Followers(ctx, alice.ID); len(followers) != 0 {
+		t.Errorf("after unfollowing, alice's followers = %+v, want none", followers)
+	}
+}
+
+func TestInboxRejects(t *testing.T) {
+	a := newInstance(t, "alice")
+	b := newInstance(t, "bob", "mallory")
+	aliceID := a.server.ActorID("alice")
+	follow := Activity{ID: "x", Type: "Follow", Actor: b.server.ActorID("bob"), Object: mustMarshal(aliceID)}
+
+	if status := b.post(t, "", aliceID+"/inbox", follow); status != http.StatusUnauthorized {
+		t.Errorf("unsigned: status = %d, want 401", status)
+	}
+	if status := b.post(t, "mallory", aliceID+"/inbox", follow); status != http.StatusUnauthorized {
+		t.Errorf("signed by someone else: status = %d, want 401", status)
+	}
+	if len(a.backend.followers) != 0 {
+		t.Errorf("followers = %+v, want none", a.backend.followers)
+	}
+
+	// A forged signature doesn't get the actor it names saved.
+	forged := postSigned(t, b.server.keyID("bob"), b.user("mallory").PrivateKey, aliceID+"/inbox", follow)
+	if forged != http.StatusUnauthorized {
+		t.Errorf("forged signature: status = %d, want 401", forged)
+	}
+	if _, err := a.backend.RemoteActor(context.Background(), b.server.ActorID("bob")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("after a forged signature, bob's actor lookup error = %v, want %v", err, ErrNotFound)
+	}
+
+	// Notes from actors nobody here follows aren't kept.
+	note := Note{
+		ID:           b.server.NoteID(uuid.New()),
+		Type:         "Note",
+		AttributedTo: b.server.ActorID("bob"),
+		Content:      "<p>spam</p>",
+		Published:    time.Now(),
+	}
+	status := b.post(t, "bob", a.url+"/ap/inbox", Activity{
+		ID:     note.ID + "/activity",
+		Type:   "Create",
+		Actor:  b.server.ActorID("bob"),
+		Object: mustMarshal(note),
+	})
+	if status != http.StatusAccepted || len(a.backend.remoteNotes) != 0 {
+		t.Errorf("status = %d, notes = %+v, want the note dropped", status, a.backend.remoteNotes)
+	}
+
+	// Nor are notes claiming to live on another server.
+	note.ID = a.server.NoteID(uuid.New())
+	status = b.post(t, "bob", a.url+"/ap/inbox", Activity{
+		ID:     b.server.NoteID(uuid.New()) + "/activity",
+		Type:   "Create",
+		Actor:  b.server.ActorID("bob"),
+		Object: mustMarshal(note),
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("note on another server: status = %d, want 400", status)
+	}
+}
+
+func TestAcceptNeedsPendingFollow(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t, "alice")
+	b := newInstance(t, "bob", "mallory")
+	alice := a.user("alice")
+	aliceID, bobID := a.server.ActorID("alice"), b.server.ActorID("bob")
+
+	// Mallory accepts a follow alice never sent.
+	unsolicited := Activity{
+		ID:     b.server.ActorID("mallory") + "#accepts/1",
+		Type:   "Accept",
+		Actor:  b.server.ActorID("mallory"),
+		Object: mustMarshal(aliceID + "#follows/anything"),
+	}
+	if status := b.post(t, "mallory", a.url+"/ap/inbox", unsolicited); status != http.StatusBadRequest {
+		t.Errorf("unsolicited Accept: status = %d, want 400", status)
+	}
+
+	// Alice follows bob; mallory can't accept on bob's behalf.
+	err := a.server.Follow(ctx, alice.ID, bobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var followID string
+	for id := range a.backend.pending {
+		followID = id
+	}
+	unsolicited.Object = mustMarshal(followID)
+	if status := b.post(t, "mallory", a.url+"/ap/inbox", unsolicited); status != http.StatusBadRequest {
+		t.Errorf("Accept by someone else: status = %d, want 400", status)
+	}
+	if following := a.backend.following[alice.ID]; len(following) != 0 {
+		t.Fatalf("alice follows %v, want nobody yet", following)
+	}
+
+	a.flush(t)
+	b.flush(t)
+	if following := a.backend.following[alice.ID]; len(following) != 1 || following[0] != bobID {
+		t.Fatalf("alice follows %v, want bob", following)
+	}
+	if len(a.backend.pending) != 0 {
+		t.Errorf("pending follows = %+v, want the accepted one gone", a.backend.pending)
+	}
+}
+
+func TestUndoLikeAndDeleteNote(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t, "alice")
+	b := newInstance(t, "bob", "mallory")
+	alice, bob := a.user("alice"), b.user("bob")
+	bobID := b.server.ActorID("bob")
+
+	// Alice follows bob, so bob's notes are kept, and bob likes alice's chirp.
+	err := a.server.Follow(ctx, alice.ID, bobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.flush(t)
+	b.flush(t)
+	note := LocalNote{ID: uuid.New(), AuthorID: alice.ID, Body: "hello", Published: time.Now()}
+	a.backend.notes[note.ID] = note
+	like := Activity{
+		ID:     bobID + "#likes/1",
+		Type:   "Like",
+		Actor:  bobID,
+		Object: mustMarshal(a.server.NoteID(note.ID)),
+	}
+	if status := b.post(t, "bob", a.url+"/ap/inbox", like); status != http.StatusAccepted {
+		t.Fatalf("like: status = %d, want 202", status)
+	}
+	bobNote := LocalNote{ID: uuid.New(), AuthorID: bob.ID, Body: "hi", Published: time.Now()}
+	b.backend.notes[bobNote.ID] = bobNote
+	err = b.server.PublishNote(ctx, bobNote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.flush(t)
+	if len(a.backend.likes[note.ID]) != 1 || len(a.backend.remoteNotes) != 1 {
+		t.Fatalf("likes = %v, notes = %+v; want bob's like and note", a.backend.likes, a.backend.remoteNotes)
+	}
+
+	undo := Activity{ID: bobID + "#undo/1", Type: "Undo", Actor: bobID, Object: mustMarshal(like)}
+	if status := b.post(t, "bob", a.url+"/ap/inbox", undo); status != http.StatusAccepted {
+		t.Fatalf("undo like: status = %d, want 202", status)
+	}
+	if likes := a.backend.likes[note.ID]; len(likes) != 0 {
+		t.Errorf("after undo, likes = %v, want none", likes)
+	}
+
+	// Only bob can delete his note.
+	tombstone := map[string]string{"id": b.server.NoteID(bobNote.ID), "type": "Tombstone"}
+	b.post(t, "mallory", a.url+"/ap/inbox", Activity{
+		ID:     b.server.ActorID("mallory") + "#delete",
+		Type:   "Delete",
+		Actor:  b.server.ActorID("mallory"),
+		Object: mustMarshal(tombstone),
+	})
+	if len(a.backend.remoteNotes) != 1 {
+		t.Fatal("mallory deleted bob's note")
+	}
+	err = b.server.DeleteNote(ctx, bobNote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.flush(t)
+	if len(a.backend.remoteNotes) != 0 {
+		t.Errorf("after delete, notes = %+v, want none", a.backend.remoteNotes)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t, "alice")
+	b := newInstance(t, "bob")
+	a.backend.notes[uuid.Nil] = LocalNote{ID: uuid.Nil, AuthorID: a.user("alice").ID}
+	aliceRemote, err := b.server.FetchActor(ctx, a.server.ActorID("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.failing.Store(true)
+	err = b.server.Like(ctx, b.user("bob").ID, a.server.NoteID(uuid.Nil), aliceRemote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.flush(t)
+	deliveries := b.queue.All()
+	if len(deliveries) != 1 || deliveries[0].Status != webhook.StatusPending || deliveries[0].Attempts != 1 || deliveries[0].ResponseStatus != http.StatusServiceUnavailable {
+		t.Fatalf("deliveries = %+v, want one pending retry", deliveries)
+	}
+	if n, _ := b.server.RunOnce(ctx); n != 0 {
+		t.Errorf("RunOnce() = %d before the backoff, want 0", n)
+	}
+
+	a.failing.Store(false)
+	b.server.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	b.flush(t)
+	deliveries = b.queue.All()
+	if deliveries[0].Status != webhook.StatusSucceeded || deliveries[0].Attempts != 2 {
+		t.Errorf("delivery = %+v, want it to succeed on the second attempt", deliveries[0])
+	}
+	if len(a.backend.likes[uuid.Nil]) != 1 {
+		t.Errorf("likes = %v, want bob's", a.backend.likes)
+	}
+}
+
+// failingQueue fails to store the first delivery it is given.
+type failingQueue struct {
+	*MemoryStore
+	failed bool
+}
+
+func (q *failingQueue) Update(ctx context.Context, delivery Delivery) error {
+	if !q.failed {
+		q.failed = true
+		return errors.New("database is down")
+	}
+	return q.MemoryStore.Update(ctx, delivery)
+}
+
+func TestDeliveryContinuesAfterUpdateError(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t, "alice")
+	b := newInstance(t, "bob")
+	a.backend.notes[uuid.Nil] = LocalNote{ID: uuid.Nil, AuthorID: a.user("alice").ID}
+	aliceRemote, err := b.server.FetchActor(ctx, a.server.ActorID("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.server.queue = &failingQueue{MemoryStore: b.queue}
+	b.server.dispatcher = b.server.newDispatcher()
+
+	for range 2 {
+		err = b.server.Like(ctx, b.user("bob").ID, a.server.NoteID(uuid.Nil), aliceRemote)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := b.server.RunOnce(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("RunOnce() = %d, %v; want both deliveries attempted", n, err)
+	}
+	if len(a.backend.likes[uuid.Nil]) != 2 {
+		t.Errorf("likes = %v, want both delivered", a.backend.likes)
+	}
+	succeeded := 0
+	for _, delivery := range b.queue.All() {
+		if delivery.Status == webhook.StatusSucceeded {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d deliveries stored as succeeded, want 1", succeeded)
+	}
+}
+
+func TestInboxErrorsDontLeakDetails(t *testing.T) {
+	a := newInstance(t, "alice")
+	body, _ := json.Marshal(Activity{ID: "x", Type: "Follow", Actor: "https://elsewhere.example/users/eve", Object: mustMarshal(a.server.ActorID("alice"))})
+	req := httptest.NewRequest(http.MethodPost, "/ap/inbox", bytes.NewReader(body))
+	req.Header.Set("Signature", `keyId="http://127.0.0.1:1/users/eve#main-key",headers="(request-target)",signature="AAAA"`)
+	w := httptest.NewRecorder()
+	a.server.handleInbox(w, req)
+
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("got %d %s, want a 401 problem", w.Code, w.Header().Get("Content-Type"))
+	}
+	got := struct {
+		Detail string `json:"detail"`
+	}{}
+	json.NewDecoder(w.Body).Decode(&got)
+	if got.Detail != "Couldn't verify signature" {
+		t.Errorf("detail = %q, want only %q", got.Detail, "Couldn't verify signature")
+	}
+}
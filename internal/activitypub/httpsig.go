@@ -0,0 +1,207 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTP Signatures, as the fediverse uses them (draft-cavage-http-signatures
+// with rsa-sha256).
+
+var (
+	ErrNoSignature     = errors.New("request isn't signed")
+	ErrBadSignature    = errors.New("signature doesn't match")
+	ErrBadDigest       = errors.New("digest doesn't match the body")
+	ErrStaleSignature  = errors.New("signature date is too far from now")
+	ErrUnsignedHeaders = errors.New("signature doesn't cover the required headers")
+)
+
+// signedHeaders are signed on every request; POSTs also sign the digest.
+var signedHeaders = []string{"(request-target)", "host", "date"}
+
+// Sign adds Date, Digest (for a body) and Signature headers to req.
+func Sign(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte, now time.Time) error {
+	req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := signedHeaders
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers[:len(headers):len(headers)], "digest")
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(req, host, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// SignatureKeyID returns the keyId of req's signature, so the caller can
+// find the key to pass to Verify.
+func SignatureKeyID(req *http.Request) (string, error) {
+	params, err := signatureParams(req)
+	if err != nil {
+		return "", err
+	}
+	return params["keyId"], nil
+}
+
+// Verify checks req's signature with key. The signature must cover the
+// request target, host and date, and the digest if there's a body; the
+// date must be within maxSkew of now.
+func Verify(req *http.Request, body []byte, key *rsa.PublicKey, now time.Time, maxSkew time.Duration) error {
+	params, err := signatureParams(req)
+	if err != nil {
+		return err
+	}
+	algorithm := params["algorithm"]
+	if algorithm != "" && algorithm != "rsa-sha256" && algorithm != "hs2019" {
+		return fmt.Errorf("unsupported signature algorithm %q", algorithm)
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := signedHeaders
+	if len(body) > 0 {
+		required = append(required[:len(required):len(required)], "digest")
+	}
+	for _, name := range required {
+		if !contains(headers, name) {
+			return ErrUnsignedHeaders
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("invalid Date header: %w", err)
+	}
+	if date.Before(now.Add(-maxSkew)) || date.After(now.Add(maxSkew)) {
+		return ErrStaleSignature
+	}
+	if len(body) > 0 && req.Header.Get("Digest") != digest(body) {
+		return ErrBadDigest
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return ErrBadSignature
+	}
+	hashed := sha256.Sum256([]byte(signingString(req, req.Host, headers)))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature) != nil {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func signatureParams(req *http.Request) (map[string]string, error) {
+	header := req.Header.Get("Signature")
+	if header == "" {
+		return nil, ErrNoSignature
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, errors.New("malformed Signature header")
+		}
+		params[name] = strings.Trim(value, `"`)
+	}
+	if params["keyId"] == "" || params["signature"] == "" {
+		return nil, errors.New("malformed Signature header")
+	}
+	return params, nil
+}
+
+func signingString(req *http.Request, host string, headers []string) string {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		switch name {
+		case "(request-target)":
+			lines = append(lines, "(request-target): "+strings.ToLower(req.Method)+" "+req.URL.RequestURI())
+		case "host":
+			lines = append(lines, "host: "+host)
+		default:
+			lines = append(lines, name+": "+strings.Join(req.Header.Values(name), ", "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateKey returns a new key pair for a user, PEM-encoded.
+func GenerateKey() (publicPEM, privatePEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	publicPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	privatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	return publicPEM, privatePEM, nil
+}
+
+// ParsePrivateKey reads a key written by GenerateKey.
+func ParsePrivateKey(privatePEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("no PEM block in private key")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// ParsePublicKey reads the publicKeyPem of an actor.
+func ParsePublicKey(publicPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return nil, errors.New("no PEM block in public key")
+	}
+	var key interface{}
+	var err error
+	if block.Type == "RSA PUBLIC KEY" {
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key isn't RSA")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,115 @@
+package activitypub
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) (publicPEM, privatePEM string) {
+	t.Helper()
+	publicPEM, privatePEM, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicPEM, privatePEM
+}
+
+func signedRequest(t *testing.T, privatePEM string, body []byte, now time.Time) *http.Request {
+	t.Helper()
+	key, err := ParsePrivateKey(privatePEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "https://chirpy.example/ap/inbox", bytes.NewReader(body))
+	err = Sign(req, "https://remote.example/users/bob#main-key", key, body, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	publicPEM, privatePEM := testKey(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"Follow"}`)
+	req := signedRequest(t, privatePEM, body, now)
+
+	keyID, err := SignatureKeyID(req)
+	if err != nil || keyID != "https://remote.example/users/bob#main-key" {
+		t.Fatalf("SignatureKeyID() = %q, %v", keyID, err)
+	}
+	key, err := ParsePublicKey(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Verify(req, body, key, now.Add(time.Minute), 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	publicPEM, privatePEM := testKey(t)
+	otherPublicPEM, _ := testKey(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"Follow"}`)
+
+	tests := []struct {
+		name    string
+		key     string
+		modify  func(req *http.Request)
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "wrong key", key: otherPublicPEM, body: body, now: now, wantErr: ErrBadSignature},
+		{name: "tampered body", key: publicPEM, body: []byte(`{"type":"Undo"}`), now: now, wantErr: ErrBadDigest},
+		{name: "stale", key: publicPEM, body: body, now: now.Add(time.Hour), wantErr: ErrStaleSignature},
+		{
+			name: "different path", key: publicPEM, body: body, now: now, wantErr: ErrBadSignature,
+			modify: func(req *http.Request) { req.URL.Path = "/ap/users/alice/inbox" },
+		},
+		{
+			name: "unsigned", key: publicPEM, body: body, now: now, wantErr: ErrNoSignature,
+			modify: func(req *http.Request) { req.Header.Del("Signature") },
+		},
+		{
+			name: "digest not signed", key: publicPEM, body: body, now: now, wantErr: ErrUnsignedHeaders,
+			modify: func(req *http.Request) {
+				signature := req.Header.Get("Signature")
+				req.Header.Set("Signature", string(bytes.Replace([]byte(signature), []byte(" digest"), nil, 1)))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signedRequest(t, privatePEM, body, now)
+			if tt.modify != nil {
+				tt.modify(req)
+			}
+			key, err := ParsePublicKey(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Verify(req, tt.body, key, tt.now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlainTextAndHTML(t *testing.T) {
+	got := PlainText(`<p>Hello <a href="https://x.example">@bob</a> &amp; co</p><p>second<br/>line</p>`)
+	if got != "Hello @bob & co\nsecond\nline" {
+		t.Errorf("PlainText() = %q", got)
+	}
+	if got := HTML("a <b>\nc"); got != "<p>a &lt;b&gt;<br>c</p>" {
+		t.Errorf("HTML() = %q", got)
+	}
+}
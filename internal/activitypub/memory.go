@@ -0,0 +1,22 @@
+package activitypub
+
+import (
+	"context"
+
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+)
+
+// MemoryStore keeps the queue in process. Deliveries are lost on restart,
+// so use it only for tests.
+type MemoryStore struct {
+	*webhook.MemoryStore[Delivery, *Delivery]
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{MemoryStore: webhook.NewMemoryStore[Delivery]()}
+}
+
+func (s *MemoryStore) Enqueue(ctx context.Context, delivery Delivery) error {
+	s.Add(delivery)
+	return nil
+}
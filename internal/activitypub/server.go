@@ -0,0 +1,765 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/problem"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	userAgent       = "Chirpy-ActivityPub/1.0"
+	maxDocumentSize = 1 << 20
+	outboxSize      = 20
+)
+
+// errBadActivity is an activity Chirpy understands but won't accept.
+var errBadActivity = errors.New("bad activity")
+
+// Server serves one Chirpy instance's side of federation.
+type Server struct {
+	baseURL string
+	host    string
+	backend Backend
+	queue   Store
+	client  *http.Client
+	policy  webhook.RetryPolicy
+
+	// MaxSkew is how far a signature's date may be from now.
+	MaxSkew time.Duration
+
+	dispatcher *webhook.Dispatcher[Delivery, *Delivery]
+	timeout    time.Duration
+	now        func() time.Time
+}
+
+// NewServer returns a server for the instance at baseURL, such as
+// https://chirpy.example. client fetches remote actors and sends
+// deliveries; use webhook.NewClient so it can't reach private networks.
+func NewServer(baseURL string, backend Backend, queue Store, client *http.Client, policy webhook.RetryPolicy) (*Server, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	s := &Server{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		host:    parsed.Host,
+		backend: backend,
+		queue:   queue,
+		client:  client,
+		policy:  policy,
+		MaxSkew: 5 * time.Minute,
+		timeout: 10 * time.Second,
+		now:     time.Now,
+	}
+	s.dispatcher = s.newDispatcher()
+	return s, nil
+}
+
+// Register adds the federation routes to mux.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /.well-known/webfinger", s.handleWebFinger)
+	mux.HandleFunc("GET /ap/users/{username}", s.handleActor)
+	mux.HandleFunc("GET /ap/users/{username}/outbox", s.handleOutbox)
+	mux.HandleFunc("GET /ap/users/{username}/followers", s.handleFollowers)
+	mux.HandleFunc("POST /ap/users/{username}/inbox", s.handleInbox)
+	mux.HandleFunc("POST /ap/inbox", s.handleInbox)
+	mux.HandleFunc("GET /ap/notes/{noteID}", s.handleNote)
+}
+
+// ActorID is the ID of a local user's actor.
+func (s *Server) ActorID(username string) string {
+	return s.baseURL + "/ap/users/" + username
+}
+
+// NoteID is the ID of a local chirp's note.
+func (s *Server) NoteID(id uuid.UUID) string {
+	return s.baseURL + "/ap/notes/" + id.String()
+}
+
+func (s *Server) keyID(username string) string {
+	return s.ActorID(username) + "#main-key"
+}
+
+// localUsername returns the username in a local actor ID, or in the ID
+// of something under it such as "<actor>#follows/<id>".
+func (s *Server) localUsername(id string) (string, bool) {
+	rest, ok := strings.CutPrefix(id, s.baseURL+"/ap/users/")
+	if !ok {
+		return "", false
+	}
+	username, _, _ := strings.Cut(rest, "#")
+	username, _, _ = strings.Cut(username, "/")
+	return username, username != ""
+}
+
+// localNoteID returns the chirp ID in a local note ID.
+func (s *Server) localNoteID(id string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(id, s.baseURL+"/ap/notes/")
+	if !ok {
+		return uuid.Nil, false
+	}
+	chirpID, err := uuid.Parse(rest)
+	return chirpID, err == nil
+}
+
+// respondWithError writes a problem details response, like the rest of
+// Chirpy's API. detail is shown to the remote server, so it's never the
+// text of an internal error.
+func respondWithError(w http.ResponseWriter, status int, detail string) {
+	problem.Write(w, problem.New(status, detail))
+}
+
+func respondWithDocument(w http.ResponseWriter, contentType string, document interface{}) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		log.Printf("Couldn't encode ActivityPub document: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (s *Server) handleWebFinger(w http.ResponseWriter, r *http.Request) {
+	type link struct {
+		Rel  string `json:"rel"`
+		Type string `json:"type"`
+		Href string `json:"href"`
+	}
+	type jrd struct {
+		Subject string   `json:"subject"`
+		Aliases []string `json:"aliases"`
+		Links   []link   `json:"links"`
+	}
+
+	resource := r.URL.Query().Get("resource")
+	username, ok := s.localUsername(resource)
+	if !ok {
+		account, found := strings.CutPrefix(resource, "acct:")
+		name, host, _ := strings.Cut(account, "@")
+		if !found || host != s.host {
+			respondWithError(w, http.StatusNotFound, "Unknown resource")
+			return
+		}
+		username = name
+	}
+	user, err := s.backend.LocalUser(r.Context(), strings.ToLower(username))
+	if errors.Is(err, ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Unknown resource")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't get user for WebFinger: %s", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		return
+	}
+
+	actorID := s.ActorID(user.Username)
+	respondWithDocument(w, "application/jrd+json", jrd{
+		Subject: "acct:" + user.Username + "@" + s.host,
+		Aliases: []string{actorID},
+		Links:   []link{{Rel: "self", Type: ContentType, Href: actorID}},
+	})
+}
+
+// pathUser gets the local user named in the path, writing a 404 or 500
+// if there isn't one.
+func (s *Server) pathUser(w http.ResponseWriter, r *http.Request) (LocalUser, bool) {
+	user, err := s.backend.LocalUser(r.Context(), r.PathValue("username"))
+	if errors.Is(err, ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return LocalUser{}, false
+	}
+	if err != nil {
+		log.Printf("Couldn't get ActivityPub user: %s", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+		return LocalUser{}, false
+	}
+	return user, true
+}
+
+func (s *Server) handleActor(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.pathUser(w, r)
+	if !ok {
+		return
+	}
+	respondWithDocument(w, ContentType, s.actor(user))
+}
+
+func (s *Server) actor(user LocalUser) Actor {
+	id := s.ActorID(user.Username)
+	return Actor{
+		Context:           []string{contextActivityStreams, contextSecurity},
+		ID:                id,
+		Type:              "Person",
+		PreferredUsername: user.Username,
+		Inbox:             id + "/inbox",
+		Outbox:            id + "/outbox",
+		Followers:         id + "/followers",
+		Endpoints:         &Endpoints{SharedInbox: s.baseURL + "/ap/inbox"},
+		PublicKey: PublicKey{
+			ID:           s.keyID(user.Username),
+			Owner:        id,
+			PublicKeyPEM: user.PublicKeyPEM,
+		},
+	}
+}
+
+// handleOutbox lists the user's newest chirps as Create activities.
+func (s *Server) handleOutbox(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.pathUser(w, r)
+	if !ok {
+		return
+	}
+	notes, err := s.backend.Notes(r.Context(), user.ID, outboxSize)
+	if err != nil {
+		log.Printf("Couldn't get outbox: %s", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get outbox")
+		return
+	}
+
+	items := []interface{}{}
+	for _, note := range notes {
+		items = append(items, s.createActivity(user, note))
+	}
+	respondWithDocument(w, ContentType, OrderedCollection{
+		Context:      contextActivityStreams,
+		ID:           s.ActorID(user.Username) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	})
+}
+
+// handleFollowers only gives the count; who follows whom isn't public.
+func (s *Server) handleFollowers(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.pathUser(w, r)
+	if !ok {
+		return
+	}
+	followers, err := s.backend.Followers(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Couldn't get followers: %s", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get followers")
+		return
+	}
+	respondWithDocument(w, ContentType, OrderedCollection{
+		Context:    contextActivityStreams,
+		ID:         s.ActorID(user.Username) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: len(followers),
+	})
+}
+
+func (s *Server) handleNote(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("noteID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Note not found")
+		return
+	}
+	note, err := s.backend.Note(r.Context(), chirpID)
+	var user LocalUser
+	if err == nil {
+		user, err = s.backend.LocalUserByID(r.Context(), note.AuthorID)
+	}
+	if errors.Is(err, ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Note not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't get note: %s", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get note")
+		return
+	}
+
+	document := s.note(user, note)
+	document.Context = contextActivityStreams
+	respondWithDocument(w, ContentType, document)
+}
+
+func (s *Server) note(user LocalUser, note LocalNote) Note {
+	return Note{
+		ID:           s.NoteID(note.ID),
+		Type:         "Note",
+		AttributedTo: s.ActorID(user.Username),
+		Content:      HTML(note.Body),
+		InReplyTo:    note.InReplyTo,
+		Published:    note.Published.UTC(),
+		To:           []string{Public},
+		Cc:           []string{s.ActorID(user.Username) + "/followers"},
+	}
+}
+
+func (s *Server) createActivity(user LocalUser, note LocalNote) Activity {
+	object, _ := json.Marshal(s.note(user, note))
+	published := note.Published.UTC()
+	return Activity{
+		ID:        s.NoteID(note.ID) + "/activity",
+		Type:      "Create",
+		Actor:     s.ActorID(user.Username),
+		Object:    object,
+		To:        []string{Public},
+		Cc:        []string{s.ActorID(user.Username) + "/followers"},
+		Published: &published,
+	}
+}
+
+// handleInbox accepts a signed activity. Activities Chirpy has no use
+// for are accepted and dropped, as the protocol expects.
+func (s *Server) handleInbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDocumentSize+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read activity")
+		return
+	}
+	if len(body) > maxDocumentSize {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Activity is too large")
+		return
+	}
+	activity := Activity{}
+	err = json.Unmarshal(body, &activity)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode activity")
+		return
+	}
+
+	actor, err := s.verify(r.Context(), r, body)
+	if err != nil {
+		log.Printf("Couldn't verify signature of %s activity: %s", activity.Type, err)
+		respondWithError(w, http.StatusUnauthorized, "Couldn't verify signature")
+		return
+	}
+	if actor.ID != activity.Actor {
+		respondWithError(w, http.StatusUnauthorized, "Activity wasn't signed by its actor")
+		return
+	}
+
+	err = s.receive(r.Context(), actor, activity)
+	if errors.Is(err, errBadActivity) {
+		log.Printf("Rejected %s activity from %s: %s", activity.Type, actor.ID, err)
+		respondWithError(w, http.StatusBadRequest, "Activity was rejected")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't process %s activity from %s: %s", activity.Type, actor.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't process activity")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verify checks the request's signature and returns the actor who signed
+// it. A signature that fails with a stored key is tried again with a
+// freshly fetched one, in case the actor rotated their key. A fetched
+// actor is only saved once their signature checks out, so unsigned or
+// forged requests can't fill the database with actors.
+func (s *Server) verify(ctx context.Context, r *http.Request, body []byte) (RemoteActor, error) {
+	keyID, err := SignatureKeyID(r)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	actorID, _, _ := strings.Cut(keyID, "#")
+
+	actor, err := s.backend.RemoteActor(ctx, actorID)
+	fetched := false
+	if errors.Is(err, ErrNotFound) {
+		actor, err = s.fetchActor(ctx, actorID)
+		fetched = true
+	}
+	if err != nil {
+		return RemoteActor{}, err
+	}
+
+	for {
+		if actor.PublicKeyID != keyID {
+			return RemoteActor{}, errors.New("unknown key")
+		}
+		key, err := ParsePublicKey(actor.PublicKeyPEM)
+		if err != nil {
+			return RemoteActor{}, err
+		}
+		err = Verify(r, body, key, s.now(), s.MaxSkew)
+		if err == nil && fetched {
+			return s.backend.SaveRemoteActor(ctx, actor)
+		}
+		if !errors.Is(err, ErrBadSignature) || fetched {
+			return actor, err
+		}
+		actor, err = s.fetchActor(ctx, actorID)
+		if err != nil {
+			return RemoteActor{}, err
+		}
+		fetched = true
+	}
+}
+
+// FetchActor gets an actor from its server and saves it.
+func (s *Server) FetchActor(ctx context.Context, id string) (RemoteActor, error) {
+	actor, err := s.fetchActor(ctx, id)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	return s.backend.SaveRemoteActor(ctx, actor)
+}
+
+// fetchActor gets an actor from its server without saving it.
+func (s *Server) fetchActor(ctx context.Context, id string) (RemoteActor, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	req.Header.Set("Accept", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return RemoteActor{}, fmt.Errorf("fetching actor %s: %s", id, resp.Status)
+	}
+	actor := Actor{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&actor)
+	if err != nil {
+		return RemoteActor{}, fmt.Errorf("decoding actor %s: %w", id, err)
+	}
+	// An actor is only trusted from its own URL.
+	if actor.ID != id || actor.Inbox == "" || actor.PublicKey.Owner != id || actor.PublicKey.PublicKeyPEM == "" {
+		return RemoteActor{}, fmt.Errorf("actor document at %s is invalid", id)
+	}
+
+	remote := RemoteActor{
+		ID:           actor.ID,
+		Inbox:        actor.Inbox,
+		PublicKeyID:  actor.PublicKey.ID,
+		PublicKeyPEM: actor.PublicKey.PublicKeyPEM,
+	}
+	if actor.Endpoints != nil {
+		remote.SharedInbox = actor.Endpoints.SharedInbox
+	}
+	return remote, nil
+}
+
+func (s *Server) receive(ctx context.Context, actor RemoteActor, activity Activity) error {
+	switch activity.Type {
+	case "Follow":
+		user, ok, err := s.localUser(ctx, activity.ObjectID())
+		if err != nil || !ok {
+			return err
+		}
+		err = s.backend.AddFollower(ctx, user.ID, actor)
+		if err != nil {
+			return err
+		}
+		accept := Activity{
+			Context: contextActivityStreams,
+			ID:      s.ActorID(user.Username) + "#accepts/" + uuid.NewString(),
+			Type:    "Accept",
+			Actor:   s.ActorID(user.Username),
+			Object:  mustMarshal(activity),
+		}
+		return s.deliver(ctx, user, accept, []RemoteActor{actor})
+
+	case "Undo":
+		inner := Activity{}
+		err := json.Unmarshal(activity.Object, &inner)
+		if err != nil || inner.Actor != actor.ID {
+			return nil
+		}
+		switch inner.Type {
+		case "Follow":
+			user, ok, err := s.localUser(ctx, inner.ObjectID())
+			if err != nil || !ok {
+				return err
+			}
+			return s.backend.RemoveFollower(ctx, user.ID, actor)
+		case "Like":
+			chirpID, ok := s.localNoteID(inner.ObjectID())
+			if !ok {
+				return nil
+			}
+			return s.backend.RemoveLike(ctx, chirpID, actor)
+		}
+		return nil
+
+	case "Accept":
+		// The object is our Follow or its ID. Only a Follow we sent to
+		// this actor counts, so nobody can accept a follow on their own.
+		inner := Activity{}
+		if json.Unmarshal(activity.Object, &inner) == nil && inner.Type != "Follow" {
+			return nil
+		}
+		err := s.backend.AddFollowing(ctx, activity.ObjectID(), actor)
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%w: no pending follow of the actor", errBadActivity)
+		}
+		return err
+
+	case "Like":
+		chirpID, ok := s.localNoteID(activity.ObjectID())
+		if !ok {
+			return nil
+		}
+		_, err := s.backend.Note(ctx, chirpID)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.backend.AddLike(ctx, chirpID, actor)
+
+	case "Delete":
+		object := struct {
+			Type string `json:"type"`
+		}{}
+		json.Unmarshal(activity.Object, &object)
+		if object.Type != "" && object.Type != "Note" && object.Type != "Tombstone" {
+			return nil
+		}
+		return s.backend.RemoveNote(ctx, actor, activity.ObjectID())
+
+	case "Create":
+		note := Note{}
+		err := json.Unmarshal(activity.Object, &note)
+		if err != nil || note.Type != "Note" {
+			return nil
+		}
+		if note.AttributedTo != actor.ID {
+			return fmt.Errorf("%w: note isn't attributed to the actor", errBadActivity)
+		}
+		// Notes live on their author's server; one claiming another
+		// server's ID could later be deleted or replaced from there.
+		if !sameHost(note.ID, actor.ID) {
+			return fmt.Errorf("%w: note isn't on the actor's server", errBadActivity)
+		}
+		remote := RemoteNote{
+			ID:        note.ID,
+			Content:   PlainText(note.Content),
+			Published: note.Published,
+		}
+		if chirpID, ok := s.localNoteID(note.InReplyTo); ok {
+			remote.InReplyTo = uuid.NullUUID{UUID: chirpID, Valid: true}
+		}
+		// Chirpy keeps replies to its users' chirps and notes by actors
+		// they follow, not everything that's sent its way.
+		if !remote.InReplyTo.Valid {
+			followed, err := s.backend.IsFollowed(ctx, actor)
+			if err != nil || !followed {
+				return err
+			}
+		}
+		return s.backend.AddNote(ctx, actor, remote)
+	}
+	return nil
+}
+
+// localUser returns the local user with the actor ID id. ok is false if
+// it isn't one of ours.
+func (s *Server) localUser(ctx context.Context, id string) (LocalUser, bool, error) {
+	username, ok := s.localUsername(id)
+	if !ok {
+		return LocalUser{}, false, nil
+	}
+	user, err := s.backend.LocalUser(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		return LocalUser{}, false, nil
+	}
+	return user, err == nil, err
+}
+
+// sameHost reports whether two IDs are URLs on the same server.
+func sameHost(a, b string) bool {
+	urlA, err := url.Parse(a)
+	if err != nil || urlA.Host == "" {
+		return false
+	}
+	urlB, err := url.Parse(b)
+	return err == nil && urlA.Host == urlB.Host
+}
+
+// PublishNote sends a new chirp to its author's followers, and to the
+// author of the chirp it replies to if they're remote. Chirps by users
+// who aren't federated are skipped.
+func (s *Server) PublishNote(ctx context.Context, note LocalNote) error {
+	user, followers, err := s.audience(ctx, note.AuthorID)
+	if err != nil || user.ID == uuid.Nil {
+		return err
+	}
+	if note.ReplyTo != nil {
+		followers = append(followers, *note.ReplyTo)
+	}
+	activity := s.createActivity(user, note)
+	activity.Context = contextActivityStreams
+	return s.deliver(ctx, user, activity, followers)
+}
+
+// DeleteNote tells the author's followers a chirp was deleted.
+func (s *Server) DeleteNote(ctx context.Context, note LocalNote) error {
+	user, followers, err := s.audience(ctx, note.AuthorID)
+	if err != nil || user.ID == uuid.Nil {
+		return err
+	}
+	tombstone := map[string]string{"id": s.NoteID(note.ID), "type": "Tombstone"}
+	return s.deliver(ctx, user, Activity{
+		Context: contextActivityStreams,
+		ID:      s.NoteID(note.ID) + "#delete",
+		Type:    "Delete",
+		Actor:   s.ActorID(user.Username),
+		Object:  mustMarshal(tombstone),
+		To:      []string{Public},
+	}, followers)
+}
+
+// audience returns a federated user and their remote followers. The user
+// is zero if they aren't federated.
+func (s *Server) audience(ctx context.Context, userID uuid.UUID) (LocalUser, []RemoteActor, error) {
+	user, err := s.backend.LocalUserByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return LocalUser{}, nil, nil
+	}
+	if err != nil {
+		return LocalUser{}, nil, err
+	}
+	followers, err := s.backend.Followers(ctx, user.ID)
+	if err != nil {
+		return LocalUser{}, nil, err
+	}
+	return user, followers, nil
+}
+
+// Follow asks the remote actor with ID actorID to let userID follow them.
+// The follow is recorded when they accept.
+func (s *Server) Follow(ctx context.Context, userID uuid.UUID, actorID string) error {
+	user, actor, err := s.followParties(ctx, userID, actorID)
+	if err != nil || user.ID == uuid.Nil {
+		return err
+	}
+	follow := s.followActivity(user, actor)
+	err = s.backend.AddPendingFollow(ctx, follow.ID, user.ID, actor)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, user, follow, []RemoteActor{actor})
+}
+
+// Unfollow tells the remote actor with ID actorID that userID stopped
+// following them. A Follow they haven't accepted yet no longer counts.
+func (s *Server) Unfollow(ctx context.Context, userID uuid.UUID, actorID string) error {
+	user, actor, err := s.followParties(ctx, userID, actorID)
+	if err != nil || user.ID == uuid.Nil {
+		return err
+	}
+	err = s.backend.RemovePendingFollows(ctx, user.ID, actor)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, user, Activity{
+		Context: contextActivityStreams,
+		ID:      s.ActorID(user.Username) + "#undo/" + uuid.NewString(),
+		Type:    "Undo",
+		Actor:   s.ActorID(user.Username),
+		Object:  mustMarshal(s.followActivity(user, actor)),
+	}, []RemoteActor{actor})
+}
+
+// followParties gets both sides of a follow. The user is zero if they
+// aren't federated.
+func (s *Server) followParties(ctx context.Context, userID uuid.UUID, actorID string) (LocalUser, RemoteActor, error) {
+	user, err := s.backend.LocalUserByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return LocalUser{}, RemoteActor{}, nil
+	}
+	if err != nil {
+		return LocalUser{}, RemoteActor{}, err
+	}
+	actor, err := s.backend.RemoteActor(ctx, actorID)
+	if errors.Is(err, ErrNotFound) {
+		actor, err = s.FetchActor(ctx, actorID)
+	}
+	if err != nil {
+		return LocalUser{}, RemoteActor{}, err
+	}
+	return user, actor, nil
+}
+
+func (s *Server) followActivity(user LocalUser, actor RemoteActor) Activity {
+	return Activity{
+		Context: contextActivityStreams,
+		ID:      s.ActorID(user.Username) + "#follows/" + uuid.NewString(),
+		Type:    "Follow",
+		Actor:   s.ActorID(user.Username),
+		Object:  mustMarshal(actor.ID),
+	}
+}
+
+// Like tells a remote actor that userID liked their note noteID.
+func (s *Server) Like(ctx context.Context, userID uuid.UUID, noteID string, author RemoteActor) error {
+	user, err := s.backend.LocalUserByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, user, Activity{
+		Context: contextActivityStreams,
+		ID:      s.ActorID(user.Username) + "#likes/" + uuid.NewString(),
+		Type:    "Like",
+		Actor:   s.ActorID(user.Username),
+		Object:  mustMarshal(noteID),
+	}, []RemoteActor{author})
+}
+
+// deliver queues activity for each recipient's inbox, once per shared
+// inbox.
+func (s *Server) deliver(ctx context.Context, sender LocalUser, activity Activity, recipients []RemoteActor) error {
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, recipient := range recipients {
+		inbox := recipient.Inbox
+		if recipient.SharedInbox != "" {
+			inbox = recipient.SharedInbox
+		}
+		if seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+		err := s.queue.Enqueue(ctx, Delivery{
+			Queued:   webhook.Queued{NextAttemptAt: s.now()},
+			SenderID: sender.ID,
+			Inbox:    inbox,
+			Payload:  payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -2,64 +2,55 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
+var defaultPasswordHasher = NewPasswordHasher(DefaultArgon2Params())
+
+// HashPassword hashes with argon2id using the default parameters.
 func HashPassword(password string) (string, error) {
-	dat, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(dat), nil
+	return defaultPasswordHasher.Hash(password)
 }
 
+// CheckPasswordHash accepts argon2id and bcrypt hashes.
 func CheckPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	_, err := defaultPasswordHasher.Verify(password, hash)
+	return err
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	str, err := token.SignedString([]byte(tokenSecret))
-	return str, err
+// SessionClaims are the claims of an access token that was issued from a
+// refresh token session. SessionID is the refresh token family. ClientID
+// and Scope are only set for tokens issued to third-party OAuth clients.
+type SessionClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+// ids returns the user and session. The session is uuid.Nil when the token
+// has no "sid" claim.
+func (c SessionClaims) ids() (uuid.UUID, uuid.UUID, error) {
+	userID, err := uuid.Parse(c.Subject)
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.Nil, uuid.Nil, err
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		return uuid.UUID{}, fmt.Errorf("invalid token claims")
+	if c.SessionID == "" {
+		return userID, uuid.Nil, nil
 	}
-
-	userId, err := uuid.Parse(claims.Subject)
+	sessionID, err := uuid.Parse(c.SessionID)
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.Nil, uuid.Nil, err
 	}
-
-	return userId, nil
+	return userID, sessionID, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -90,6 +81,21 @@ func MakeRefreshToken() (string, error) {
 	return token, nil
 }
 
+// HashRefreshToken returns the hex-encoded SHA-256 digest of a refresh token.
+// Only the digest is stored, so a leaked refresh_tokens table can't be
+// replayed as live sessions.
+func HashRefreshToken(token string) string {
+	return HashToken(token)
+}
+
+// HashToken returns the hex-encoded SHA-256 digest of a random,
+// high-entropy secret such as an authorization code or client secret.
+// Don't use it for passwords.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	auth := headers.Get("Authorization")
 	if auth == "" {
@@ -2,7 +2,6 @@ package auth
 
 import (
 	"testing"
-	"time"
 
 	"github.com/google/uuid"
 )
@@ -62,60 +61,66 @@ func TestCheckPas(t *testing.T) {
 
 }
 
-func TestJWTCreationAndValidation(t *testing.T) {
-	// Create a test UUID and secret
-	userId := uuid.New()
-	secret := "your-test-secret"
-
-	// Test token creation
-	token, err := MakeJWT(userId, secret, time.Hour)
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
 	if err != nil {
-		t.Fatalf("Failed to create token: %v", err)
+		t.Fatalf("Failed to create refresh token: %v", err)
 	}
-	if token == "" {
-		t.Fatal("Token is empty")
+	other, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("Failed to create refresh token: %v", err)
 	}
 
-	// Test token validation
-	validatedId, err := ValidateJWT(token, secret)
-	if err != nil {
-		t.Fatalf("Failed to validate token: %v", err)
+	hash := HashRefreshToken(token)
+	if len(hash) != 64 {
+		t.Fatalf("Expected 64 character digest, got %d", len(hash))
 	}
-	if validatedId != userId {
-		t.Fatalf("User ID mismatch. Expected %v, got %v", userId, validatedId)
+	if hash == token {
+		t.Fatal("Digest must not equal the raw token")
+	}
+	if HashRefreshToken(token) != hash {
+		t.Fatal("Digest is not deterministic")
+	}
+	if HashRefreshToken(other) == hash {
+		t.Fatal("Different tokens produced the same digest")
 	}
 }
 
-func TestExpiredToken(t *testing.T) {
-	// Create a test UUID and secret
-	userId := uuid.New()
-	secret := "your-test-secret"
+func TestHashRefreshTokenKnownValue(t *testing.T) {
+	// Must match encode(sha256(convert_to(token, 'UTF8')), 'hex') in the
+	// 007_refresh_token_hashes migration.
+	got := HashRefreshToken("abc")
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got != want {
+		t.Fatalf("HashRefreshToken(\"abc\") = %s, want %s", got, want)
+	}
+}
 
-	// Create token that's already expired (negative duration)
-	token, err := MakeJWT(userId, secret, -time.Hour)
+func TestMakePersonalToken(t *testing.T) {
+	token, err := MakePersonalToken()
 	if err != nil {
 		t.Fatalf("Failed to create token: %v", err)
 	}
-
-	// Try to validate expired token
-	_, err = ValidateJWT(token, secret)
-	if err == nil {
-		t.Fatal("Expected error for expired token, got nil")
+	if !IsPersonalToken(token) {
+		t.Fatalf("Token %q isn't recognized as a personal token", token)
+	}
+	if len(token) != len(PersonalTokenPrefix)+64 {
+		t.Fatalf("Token %q has unexpected length %d", token, len(token))
 	}
-}
-
-func TestInvalidSecretTroken(t *testing.T) {
-	userId := uuid.New()
-	secret := "secret"
-	invalidSecret := "invalid-secret"
 
-	token, err := MakeJWT(userId, secret, time.Hour)
+	other, err := MakePersonalToken()
 	if err != nil {
 		t.Fatalf("Failed to create token: %v", err)
 	}
+	if token == other {
+		t.Fatal("Two tokens are identical")
+	}
 
-	_, err = ValidateJWT(token, invalidSecret)
-	if err == nil {
-		t.Fatal("Expected error for invalid secret, got nil")
+	jwt, err := newTestTokenService(t, DefaultTokenConfig()).MakeAccessToken(uuid.New(), uuid.New(), 0)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+	if IsPersonalToken(jwt) {
+		t.Fatal("JWT recognized as a personal token")
 	}
 }
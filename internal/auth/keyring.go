@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a JWT signing key identified by its "kid" header.
+type Key struct {
+	ID        string
+	Algorithm string // "EdDSA", "RS256" or "HS256"
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACKey(id, secret string) *Key {
+	return &Key{
+		ID:        id,
+		Algorithm: jwt.SigningMethodHS256.Alg(),
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+func GenerateEd25519Key(id string) (*Key, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:        id,
+		Algorithm: jwt.SigningMethodEdDSA.Alg(),
+		signKey:   private,
+		verifyKey: public,
+	}, nil
+}
+
+func GenerateRSAKey(id string, bits int) (*Key, error) {
+	private, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:        id,
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		signKey:   private,
+		verifyKey: &private.PublicKey,
+	}, nil
+}
+
+// ParsePrivateKeyPEM reads an Ed25519 or RSA private key in PKCS#8 or
+// PKCS#1 PEM form.
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		return &Key{
+			ID:        id,
+			Algorithm: jwt.SigningMethodEdDSA.Alg(),
+			signKey:   private,
+			verifyKey: private.Public(),
+		}, nil
+	case *rsa.PrivateKey:
+		return &Key{
+			ID:        id,
+			Algorithm: jwt.SigningMethodRS256.Alg(),
+			signKey:   private,
+			verifyKey: &private.PublicKey,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
+
+// Keyring signs tokens with its active key and verifies them with whichever
+// key the token's "kid" header names. Keeping the previous key in the ring
+// after switching the active key lets tokens it signed expire naturally.
+type Keyring struct {
+	mu     sync.RWMutex
+	keys   map[string]*Key
+	active string
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{keys: map[string]*Key{}}
+}
+
+// Add puts the key in the ring. The first key added becomes the active key.
+func (k *Keyring) Add(key *Key) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[key.ID] = key
+	if k.active == "" {
+		k.active = key.ID
+	}
+}
+
+func (k *Keyring) SetActive(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("no key with id %q", id)
+	}
+	k.active = id
+	return nil
+}
+
+func (k *Keyring) Remove(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, id)
+	if k.active == id {
+		k.active = ""
+	}
+}
+
+// Replace swaps every key in the ring at once.
+func (k *Keyring) Replace(keys []*Key, active string) error {
+	byID := map[string]*Key{}
+	for _, key := range keys {
+		byID[key.ID] = key
+	}
+	if _, ok := byID[active]; !ok {
+		return fmt.Errorf("no key with id %q", active)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = byID
+	k.active = active
+	return nil
+}
+
+// LoadDir replaces the ring with the "<kid>.pem" private keys in dir. The
+// active key is the kid named in the dir's "active" file, or the last kid
+// in lexical order when there is no such file.
+func (k *Keyring) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no .pem keys in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keys := []*Key{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParsePrivateKeyPEM(id, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	active := keys[len(keys)-1].ID
+	data, err := os.ReadFile(filepath.Join(dir, "active"))
+	if err == nil {
+		active = strings.TrimSpace(string(data))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return k.Replace(keys, active)
+}
+
+// Sign signs the claims with the active key and records its kid in the
+// token header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	key, ok := k.keys[k.active]
+	k.mu.RUnlock()
+	if !ok {
+		return "", errors.New("keyring has no active key")
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Parse verifies the token with the key its kid names and fills in claims.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, k.keyfunc, options...)
+	return err
+}
+
+func (k *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	// Tokens signed before the keyring existed carry no kid. They were
+	// short-lived and are no longer accepted.
+	kid, _ := token.Header["kid"].(string)
+	key := k.keys[kid]
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	// The key, not the token, decides the algorithm. Otherwise a token
+	// could claim HS256 and be "signed" with a published public key.
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is the public half of a signing key as published in a JWKS document.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys in the ring. HMAC keys are secret and are
+// never published.
+func (k *Keyring) JWKS() JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := k.keys[id]
+		switch public := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Algorithm: key.Algorithm,
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Algorithm: key.Algorithm,
+				Use:       "sig",
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		}
+	}
+	return jwks
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// testClaims are claims for a token that expires in an hour.
+func testClaims(userID uuid.UUID) jwt.RegisteredClaims {
+	now := time.Now().UTC()
+	return jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Subject:   userID.String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func TestKeyringSignAndValidate(t *testing.T) {
+	edKey, err := GenerateEd25519Key("ed-1")
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaKey, err := GenerateRSAKey("rsa-1", 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  *Key
+	}{
+		{name: "EdDSA", key: edKey},
+		{name: "RS256", key: rsaKey},
+		{name: "HS256", key: NewHMACKey("hs-1", "your-test-secret")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := NewKeyring()
+			keys.Add(tt.key)
+
+			userId := uuid.New()
+			token, err := keys.Sign(testClaims(userId))
+			if err != nil {
+				t.Fatalf("Failed to create token: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("Failed to parse token: %v", err)
+			}
+			if parsed.Header["kid"] != tt.key.ID {
+				t.Fatalf("kid = %v, want %v", parsed.Header["kid"], tt.key.ID)
+			}
+			if parsed.Method.Alg() != tt.key.Algorithm {
+				t.Fatalf("alg = %v, want %v", parsed.Method.Alg(), tt.key.Algorithm)
+			}
+
+			claims := jwt.RegisteredClaims{}
+			if err := keys.Parse(token, &claims); err != nil {
+				t.Fatalf("Failed to validate token: %v", err)
+			}
+			if claims.Subject != userId.String() {
+				t.Fatalf("Subject = %v, want %v", claims.Subject, userId)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, _ := GenerateEd25519Key("old")
+	newKey, _ := GenerateEd25519Key("new")
+	userId := uuid.New()
+
+	keys := NewKeyring()
+	keys.Add(oldKey)
+	oldToken, err := keys.Sign(testClaims(userId))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	// Publish the new key and start signing with it. Tokens from the old
+	// key must keep validating during the overlap.
+	keys.Add(newKey)
+	if err := keys.SetActive("new"); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	newToken, err := keys.Sign(testClaims(userId))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	if err := keys.Parse(oldToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("Old token rejected during overlap: %v", err)
+	}
+	if err := keys.Parse(newToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("New token rejected: %v", err)
+	}
+
+	// Once the old key is retired its tokens stop validating.
+	keys.Remove("old")
+	if err := keys.Parse(oldToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("Expected error for token signed by removed key, got nil")
+	}
+	if err := keys.Parse(newToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("New token rejected after removing old key: %v", err)
+	}
+}
+
+func TestKeyringRejectsTokenWithoutKid(t *testing.T) {
+	userId := uuid.New()
+	secret := "your-test-secret"
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(userId)).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	edKey, _ := GenerateEd25519Key("ed-1")
+	keys := NewKeyring()
+	keys.Add(edKey)
+
+	if err := keys.Parse(legacyToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("Expected error for token without kid, got nil")
+	}
+
+	// Not even when the ring holds a key with the legacy secret.
+	keys.Add(NewHMACKey("hmac-1", secret))
+	if err := keys.Parse(legacyToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("Expected error for token without kid, got nil")
+	}
+}
+
+func TestKeyringRejectsAlgorithmSwitch(t *testing.T) {
+	edKey, _ := GenerateEd25519Key("ed-1")
+	keys := NewKeyring()
+	keys.Add(edKey)
+
+	// An attacker who knows the public key signs an HS256 token with it
+	// and names the EdDSA key in the header.
+	public := edKey.verifyKey.(ed25519.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(uuid.New()))
+	forged.Header["kid"] = "ed-1"
+	token, err := forged.SignedString([]byte(public))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	if err := keys.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("Expected error for token with mismatched algorithm, got nil")
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	edKey, _ := GenerateEd25519Key("ed-1")
+	rsaKey, _ := GenerateRSAKey("rsa-1", 2048)
+	keys := NewKeyring()
+	keys.Add(edKey)
+	keys.Add(rsaKey)
+	keys.Add(NewHMACKey("hs-1", "your-test-secret"))
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 published keys, got %d", len(jwks.Keys))
+	}
+	for _, jwk := range jwks.Keys {
+		switch jwk.KeyID {
+		case "ed-1":
+			if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || jwk.X == "" {
+				t.Errorf("Unexpected Ed25519 JWK: %+v", jwk)
+			}
+		case "rsa-1":
+			if jwk.KeyType != "RSA" || jwk.N == "" || jwk.E != "AQAB" {
+				t.Errorf("Unexpected RSA JWK: %+v", jwk)
+			}
+		default:
+			t.Errorf("Unexpected key published: %s", jwk.KeyID)
+		}
+	}
+}
+
+func TestKeyringLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"2026-01", "2026-02"} {
+		_, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			t.Fatalf("Failed to marshal key: %v", err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(filepath.Join(dir, id+".pem"), data, 0600); err != nil {
+			t.Fatalf("Failed to write key: %v", err)
+		}
+	}
+
+	keys := NewKeyring()
+	if err := keys.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if keys.active != "2026-02" {
+		t.Fatalf("active = %s, want the last key 2026-02", keys.active)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "active"), []byte("2026-01\n"), 0600); err != nil {
+		t.Fatalf("Failed to write active file: %v", err)
+	}
+	if err := keys.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if keys.active != "2026-01" {
+		t.Fatalf("active = %s, want 2026-01 from the active file", keys.active)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "active"), []byte("missing"), 0600); err != nil {
+		t.Fatalf("Failed to write active file: %v", err)
+	}
+	if err := keys.LoadDir(dir); err == nil {
+		t.Fatal("Expected error for unknown active key, got nil")
+	}
+	if keys.active != "2026-01" {
+		t.Fatalf("A failed reload changed the active key to %s", keys.active)
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed breached_passwords.txt
+var breachedPasswordList string
+
+var ErrPasswordBreached = errors.New("password appears in a list of breached passwords")
+
+// PasswordPolicy rejects passwords that are too short, too long to hash
+// cheaply, or known from public breaches. Lengths count characters, not
+// bytes.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]struct{}
+}
+
+func NewPasswordPolicy(minLength, maxLength int) *PasswordPolicy {
+	breached := map[string]struct{}{}
+	for _, line := range strings.Split(breachedPasswordList, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breached[strings.ToLower(line)] = struct{}{}
+	}
+
+	return &PasswordPolicy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		breached:  breached,
+	}
+}
+
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return NewPasswordPolicy(8, 256)
+}
+
+// Check returns an error describing why the password isn't allowed, or nil.
+func (p *PasswordPolicy) Check(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if length > p.MaxLength {
+		return fmt.Errorf("password must be at most %d characters", p.MaxLength)
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testArgon2Params() Argon2Params {
+	params := DefaultArgon2Params()
+	params.Memory = 1024
+	params.Iterations = 1
+	return params
+}
+
+func TestPasswordHasherArgon2id(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params())
+	hash, err := hasher.Hash("securePassword123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=2$") {
+		t.Fatalf("Unexpected hash format: %s", hash)
+	}
+
+	needsRehash, err := hasher.Verify("securePassword123", hash)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if needsRehash {
+		t.Fatal("Current hash reported as needing a rehash")
+	}
+
+	_, err = hasher.Verify("wrongPassword", hash)
+	if err != ErrPasswordMismatch {
+		t.Fatalf("Verify() error = %v, want ErrPasswordMismatch", err)
+	}
+}
+
+func TestPasswordHasherLongPasswords(t *testing.T) {
+	// bcrypt ignores everything past 72 bytes. argon2id must not.
+	hasher := NewPasswordHasher(testArgon2Params())
+	prefix := strings.Repeat("a", 72)
+	hash, err := hasher.Hash(prefix + "first")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	if _, err := hasher.Verify(prefix+"second", hash); err == nil {
+		t.Fatal("Passwords differing after 72 bytes matched")
+	}
+	if _, err := hasher.Verify(prefix+"first", hash); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestPasswordHasherRehash(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params())
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("securePassword123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to create bcrypt hash: %v", err)
+	}
+	needsRehash, err := hasher.Verify("securePassword123", string(legacy))
+	if err != nil {
+		t.Fatalf("Verify() error = %v for bcrypt hash", err)
+	}
+	if !needsRehash {
+		t.Fatal("bcrypt hash not reported as needing a rehash")
+	}
+	if _, err := hasher.Verify("wrongPassword", string(legacy)); err == nil {
+		t.Fatal("Expected error for wrong password against bcrypt hash, got nil")
+	}
+
+	stronger := testArgon2Params()
+	stronger.Iterations = 2
+	hash, err := NewPasswordHasher(stronger).Hash("securePassword123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	needsRehash, err = hasher.Verify("securePassword123", hash)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !needsRehash {
+		t.Fatal("Hash with different parameters not reported as needing a rehash")
+	}
+}
+
+func TestPasswordHasherMalformedHash(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params())
+	tests := []string{
+		"$argon2id$v=19$m=1024,t=1,p=2$onlysalt",
+		"$argon2id$v=18$m=1024,t=1,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=2$!!!$a2V5",
+	}
+	for _, hash := range tests {
+		if _, err := hasher.Verify("password", hash); err == nil {
+			t.Errorf("Expected error for %q, got nil", hash)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := NewPasswordPolicy(8, 16)
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "acceptable", password: "correct horse", wantErr: false},
+		{name: "too short", password: "short", wantErr: true},
+		{name: "too long", password: strings.Repeat("x", 17), wantErr: true},
+		{name: "counts characters not bytes", password: "ééééééé", wantErr: true},
+		{name: "breached", password: "password123", wantErr: true},
+		{name: "breached ignores case", password: "PassWord123", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherVerifyDummy(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params())
+	hasher.VerifyDummy("securePassword123")
+
+	// The dummy hash must cost what a real one does.
+	params, _, _, err := decodeArgon2Hash(hasher.dummy)
+	if err != nil {
+		t.Fatalf("Dummy hash isn't argon2id: %v", err)
+	}
+	if params != hasher.params {
+		t.Fatalf("Dummy hash params = %+v, want %+v", params, hasher.params)
+	}
+}
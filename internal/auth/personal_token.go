@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// PersonalTokenPrefix starts every personal access token. It tells them
+// apart from JWTs without a database lookup and makes leaked tokens easy
+// for secret scanners to spot.
+const PersonalTokenPrefix = "chirpy_pat_"
+
+// MakePersonalToken returns a new random personal access token. Like
+// refresh tokens, only its HashToken digest should be stored.
+func MakePersonalToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return PersonalTokenPrefix + hex.EncodeToString(tokenBytes), nil
+}
+
+// IsPersonalToken reports whether a bearer token is a personal access
+// token rather than a JWT.
+func IsPersonalToken(token string) bool {
+	return strings.HasPrefix(token, PersonalTokenPrefix)
+}
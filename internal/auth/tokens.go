@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenConfig controls the shape and lifetime of the tokens a TokenService
+// issues.
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+
+	// AccessTokenTTL is the lifetime used when the caller doesn't ask for
+	// one. Requested lifetimes are clamped to MaxAccessTokenTTL.
+	AccessTokenTTL    time.Duration
+	MaxAccessTokenTTL time.Duration
+	RefreshTokenTTL   time.Duration
+
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
+func DefaultTokenConfig() TokenConfig {
+	return TokenConfig{
+		Issuer:            "chirpy",
+		Audience:          "chirpy-api",
+		AccessTokenTTL:    time.Hour,
+		MaxAccessTokenTTL: time.Hour,
+		RefreshTokenTTL:   60 * 24 * time.Hour, // 60 days
+		Leeway:            30 * time.Second,
+	}
+}
+
+// TokenService mints and validates every token handed to clients, so that
+// all of them carry the same claims.
+type TokenService struct {
+	keys   *Keyring
+	config TokenConfig
+	now    func() time.Time
+}
+
+func NewTokenService(keys *Keyring, config TokenConfig) *TokenService {
+	return &TokenService{
+		keys:   keys,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// AccessToken is what a validated access token says about its bearer.
+type AccessToken struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID // uuid.Nil when the token isn't tied to a session
+	ExpiresAt time.Time
+
+	// ClientID is the OAuth client the token was issued to, or "" for
+	// tokens issued by Chirpy's own login.
+	ClientID string
+	Scopes   []string
+
+	// PersonalTokenID is set when the bearer presented a personal access
+	// token instead of a JWT.
+	PersonalTokenID uuid.UUID
+}
+
+// FirstParty reports whether the token came from Chirpy's own login, as
+// opposed to an OAuth client or a personal access token.
+func (t AccessToken) FirstParty() bool {
+	return t.ClientID == "" && t.PersonalTokenID == uuid.Nil
+}
+
+// HasScope reports whether the token grants scope. Tokens from Chirpy's
+// own login grant every scope.
+func (t AccessToken) HasScope(scope string) bool {
+	if t.FirstParty() {
+		return true
+	}
+	return slices.Contains(t.Scopes, scope)
+}
+
+// MakeAccessToken signs an access token for the user's session. A zero
+// expiresIn uses the configured default; longer lifetimes than the
+// configured maximum are clamped.
+func (s *TokenService) MakeAccessToken(userID, sessionID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return s.MakeClientAccessToken(userID, sessionID, "", nil, expiresIn)
+}
+
+// MakeClientAccessToken is MakeAccessToken for a token issued to a
+// third-party OAuth client, limited to the granted scopes.
+func (s *TokenService) MakeClientAccessToken(userID, sessionID uuid.UUID, clientID string, scopes []string, expiresIn time.Duration) (string, error) {
+	if expiresIn <= 0 {
+		expiresIn = s.config.AccessTokenTTL
+	}
+	if expiresIn > s.config.MaxAccessTokenTTL {
+		expiresIn = s.config.MaxAccessTokenTTL
+	}
+
+	now := s.now().UTC()
+	claims := SessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.config.Issuer,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		},
+	}
+	if s.config.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.config.Audience}
+	}
+	if sessionID != uuid.Nil {
+		claims.SessionID = sessionID.String()
+	}
+	if clientID != "" {
+		claims.ClientID = clientID
+		claims.Scope = strings.Join(scopes, " ")
+	}
+
+	return s.keys.Sign(claims)
+}
+
+func (s *TokenService) ValidateAccessToken(tokenString string) (AccessToken, error) {
+	options := []jwt.ParserOption{
+		jwt.WithIssuer(s.config.Issuer),
+		jwt.WithLeeway(s.config.Leeway),
+		jwt.WithTimeFunc(s.now),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if s.config.Audience != "" {
+		options = append(options, jwt.WithAudience(s.config.Audience))
+	}
+
+	claims := SessionClaims{}
+	err := s.keys.Parse(tokenString, &claims, options...)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	// The parser only checks nbf when it is present.
+	if claims.NotBefore == nil {
+		return AccessToken{}, errors.New("token has no nbf claim")
+	}
+
+	userID, sessionID, err := claims.ids()
+	if err != nil {
+		return AccessToken{}, err
+	}
+	return AccessToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		ExpiresAt: claims.ExpiresAt.Time,
+		ClientID:  claims.ClientID,
+		Scopes:    strings.Fields(claims.Scope),
+	}, nil
+}
+
+// AccessTokenTTL is the lifetime of access tokens minted without an
+// explicit one.
+func (s *TokenService) AccessTokenTTL() time.Duration {
+	return s.config.AccessTokenTTL
+}
+
+// RefreshToken is a newly minted refresh token. Only Hash is stored.
+type RefreshToken struct {
+	Token     string
+	Hash      string
+	ExpiresAt time.Time
+}
+
+func (s *TokenService) MakeRefreshToken() (RefreshToken, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	return RefreshToken{
+		Token:     token,
+		Hash:      HashRefreshToken(token),
+		ExpiresAt: s.now().UTC().Add(s.config.RefreshTokenTTL),
+	}, nil
+}
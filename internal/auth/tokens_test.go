@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newTestTokenService(t *testing.T, config TokenConfig) *TokenService {
+	t.Helper()
+	key, err := GenerateEd25519Key("test")
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keys := NewKeyring()
+	keys.Add(key)
+	return NewTokenService(keys, config)
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	tokens := newTestTokenService(t, DefaultTokenConfig())
+	userId := uuid.New()
+	sessionId := uuid.New()
+
+	token, err := tokens.MakeAccessToken(userId, sessionId, 0)
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	got, err := tokens.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if got.UserID != userId || got.SessionID != sessionId {
+		t.Fatalf("got %v/%v, want %v/%v", got.UserID, got.SessionID, userId, sessionId)
+	}
+
+	claims := SessionClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, &claims)
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	if claims.Issuer != "chirpy" || claims.IssuedAt == nil || claims.NotBefore == nil {
+		t.Fatalf("Missing standard claims: %+v", claims.RegisteredClaims)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "chirpy-api" {
+		t.Fatalf("Audience = %v, want [chirpy-api]", claims.Audience)
+	}
+}
+
+func TestAccessTokenLifetime(t *testing.T) {
+	config := DefaultTokenConfig()
+	config.AccessTokenTTL = 15 * time.Minute
+	config.MaxAccessTokenTTL = time.Hour
+	tokens := newTestTokenService(t, config)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tokens.now = func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		want      time.Duration
+	}{
+		{name: "default", expiresIn: 0, want: 15 * time.Minute},
+		{name: "requested", expiresIn: 5 * time.Minute, want: 5 * time.Minute},
+		{name: "clamped", expiresIn: 24 * time.Hour, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tokens.MakeAccessToken(uuid.New(), uuid.Nil, tt.expiresIn)
+			if err != nil {
+				t.Fatalf("Failed to create token: %v", err)
+			}
+			got, err := tokens.ValidateAccessToken(token)
+			if err != nil {
+				t.Fatalf("Failed to validate token: %v", err)
+			}
+			if !got.ExpiresAt.Equal(now.Add(tt.want)) {
+				t.Fatalf("ExpiresAt = %v, want %v", got.ExpiresAt, now.Add(tt.want))
+			}
+		})
+	}
+}
+
+func TestAccessTokenValidation(t *testing.T) {
+	tokens := newTestTokenService(t, DefaultTokenConfig())
+	now := time.Now()
+
+	sign := func(claims jwt.RegisteredClaims) string {
+		token, err := tokens.keys.Sign(SessionClaims{RegisteredClaims: claims})
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+	valid := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Audience:  jwt.ClaimStrings{"chirpy-api"},
+			Subject:   uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(c *jwt.RegisteredClaims)
+		wantErr bool
+	}{
+		{name: "valid", mutate: func(c *jwt.RegisteredClaims) {}, wantErr: false},
+		{name: "wrong audience", mutate: func(c *jwt.RegisteredClaims) { c.Audience = jwt.ClaimStrings{"other"} }, wantErr: true},
+		{name: "no audience", mutate: func(c *jwt.RegisteredClaims) { c.Audience = nil }, wantErr: true},
+		{name: "wrong issuer", mutate: func(c *jwt.RegisteredClaims) { c.Issuer = "other" }, wantErr: true},
+		{name: "no nbf", mutate: func(c *jwt.RegisteredClaims) { c.NotBefore = nil }, wantErr: true},
+		{name: "nbf in future", mutate: func(c *jwt.RegisteredClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) }, wantErr: true},
+		{name: "nbf within leeway", mutate: func(c *jwt.RegisteredClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Second)) }, wantErr: false},
+		{name: "expired", mutate: func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }, wantErr: true},
+		{name: "expired within leeway", mutate: func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) }, wantErr: false},
+		{name: "no expiry", mutate: func(c *jwt.RegisteredClaims) { c.ExpiresAt = nil }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.mutate(&claims)
+			_, err := tokens.ValidateAccessToken(sign(claims))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAccessToken() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessTokenFromAnotherKeyring(t *testing.T) {
+	issuer := newTestTokenService(t, DefaultTokenConfig())
+	verifier := newTestTokenService(t, DefaultTokenConfig())
+
+	token, err := issuer.MakeAccessToken(uuid.New(), uuid.New(), 0)
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	// Both keyrings name their key "test", so only the signature differs.
+	if _, err := verifier.ValidateAccessToken(token); err == nil {
+		t.Fatal("Expected error for token signed by another key, got nil")
+	}
+}
+
+func TestMakeRefreshTokenService(t *testing.T) {
+	config := DefaultTokenConfig()
+	config.RefreshTokenTTL = 24 * time.Hour
+	tokens := newTestTokenService(t, config)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tokens.now = func() time.Time { return now }
+
+	refresh, err := tokens.MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("Failed to create refresh token: %v", err)
+	}
+	if refresh.Hash != HashRefreshToken(refresh.Token) {
+		t.Fatal("Hash doesn't match token")
+	}
+	if !refresh.ExpiresAt.Equal(now.Add(24 * time.Hour)) {
+		t.Fatalf("ExpiresAt = %v, want %v", refresh.ExpiresAt, now.Add(24*time.Hour))
+	}
+}
+
+func TestClientAccessTokenScopes(t *testing.T) {
+	tokens := newTestTokenService(t, DefaultTokenConfig())
+
+	token, err := tokens.MakeClientAccessToken(uuid.New(), uuid.New(), "client-1", []string{"chirps:read", "profile"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	got, err := tokens.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if got.ClientID != "client-1" {
+		t.Fatalf("ClientID = %q, want client-1", got.ClientID)
+	}
+	if !got.HasScope("chirps:read") || !got.HasScope("profile") {
+		t.Fatalf("Granted scopes missing: %v", got.Scopes)
+	}
+	if got.HasScope("chirps:write") {
+		t.Fatal("Token has a scope that wasn't granted")
+	}
+
+	firstParty, err := tokens.MakeAccessToken(uuid.New(), uuid.New(), 0)
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	got, err = tokens.ValidateAccessToken(firstParty)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if !got.HasScope("chirps:write") {
+		t.Fatal("First-party token should grant every scope")
+	}
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	token := AccessToken{
+		UserID:          uuid.New(),
+		PersonalTokenID: uuid.New(),
+		Scopes:          []string{"chirps:write"},
+	}
+	if token.FirstParty() {
+		t.Fatal("Personal token reported as first-party")
+	}
+	if !token.HasScope("chirps:write") {
+		t.Fatal("Granted scope missing")
+	}
+	if token.HasScope("profile") {
+		t.Fatal("Personal token has a scope that wasn't granted")
+	}
+}
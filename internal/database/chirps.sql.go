@@ -7,28 +7,44 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2)
-RETURNING id, created_at, updated_at, body, user_id
+    $2,
+    $3,
+    $4,
+    $4::UUID IS NOT NULL,
+    COALESCE($5::TEXT[], '{}'))
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
+	QuoteOfID uuid.NullUUID
+	Hashtags  []string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.QuoteOfID,
+		pq.Array(arg.Hashtags),
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -36,6 +52,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.QuoteOfID,
+		&i.IsQuote,
+		pq.Array(&i.Hashtags),
 	)
 	return i, err
 }
@@ -59,7 +79,7 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, arg DeleteChirpByIDParams
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags FROM chirps
 ORDER BY created_at ASC
 `
 
@@ -78,6 +98,10 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+			pq.Array(&i.Hashtags),
 		); err != nil {
 			return nil, err
 		}
@@ -93,7 +117,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags FROM chirps
 WHERE id  = $1
 `
 
@@ -106,6 +130,211 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.QuoteOfID,
+		&i.IsQuote,
+		pq.Array(&i.Hashtags),
 	)
 	return i, err
 }
+
+const getChirpsByAuthors = `-- name: GetChirpsByAuthors :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags FROM chirps
+WHERE user_id = ANY($1::UUID[])
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetChirpsByAuthors(ctx context.Context, authorIds []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthors, pq.Array(authorIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+			pq.Array(&i.Hashtags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags FROM chirps
+WHERE id = ANY($1::UUID[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+			pq.Array(&i.Hashtags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsState = `-- name: GetChirpsState :one
+SELECT COUNT(*) AS chirp_count,
+    GREATEST(
+        COALESCE(MAX(updated_at), TIMESTAMP 'epoch'),
+        COALESCE((
+            SELECT MAX(deleted_at) FROM feed_deletions
+            WHERE feed_key IN ('author:' || $1::TEXT, 'tag:' || $2::TEXT)
+        ), TIMESTAMP 'epoch')
+    )::TIMESTAMP AS last_changed
+FROM chirps
+WHERE ($1::UUID IS NULL OR user_id = $1)
+    AND ($2::TEXT IS NULL OR hashtags @> ARRAY[$2::TEXT])
+`
+
+type GetChirpsStateParams struct {
+	AuthorID uuid.NullUUID
+	Tag      sql.NullString
+}
+
+type GetChirpsStateRow struct {
+	ChirpCount  int64
+	LastChanged time.Time
+}
+
+// How many chirps match, and when the matching chirps last changed,
+// counting deletes, for feed validators. author_id and tag are optional
+// filters.
+func (q *Queries) GetChirpsState(ctx context.Context, arg GetChirpsStateParams) (GetChirpsStateRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirpsState, arg.AuthorID, arg.Tag)
+	var i GetChirpsStateRow
+	err := row.Scan(&i.ChirpCount, &i.LastChanged)
+	return i, err
+}
+
+const listRecentChirpsByAuthor = `-- name: ListRecentChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags FROM chirps
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListRecentChirpsByAuthorParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) ListRecentChirpsByAuthor(ctx context.Context, arg ListRecentChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listRecentChirpsByAuthor, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+			pq.Array(&i.Hashtags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRecentChirpsByHashtag = `-- name: ListRecentChirpsByHashtag :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags FROM chirps
+WHERE hashtags @> ARRAY[$1::TEXT]
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListRecentChirpsByHashtagParams struct {
+	Tag        string
+	MaxResults int32
+}
+
+func (q *Queries) ListRecentChirpsByHashtag(ctx context.Context, arg ListRecentChirpsByHashtagParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listRecentChirpsByHashtag, arg.Tag, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+			pq.Array(&i.Hashtags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
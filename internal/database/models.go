@@ -11,21 +11,230 @@ import (
 	"github.com/google/uuid"
 )
 
+type ActivitypubDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	SenderID       uuid.UUID
+	Inbox          string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus int32
+	LastError      string
+}
+
+type ActivitypubPendingFollow struct {
+	FollowUri  string
+	CreatedAt  time.Time
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+type AuditLog struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Event     string
+	UserID    uuid.NullUUID
+	IpAddress string
+	Details   string
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
+	QuoteOfID uuid.NullUUID
+	IsQuote   bool
+	Hashtags  []string
 }
 
-type RefreshToken struct {
-	Token     string
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy uuid.UUID
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     time.Time
+}
+
+type FeedDeletion struct {
+	FeedKey   string
+	DeletedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type List struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	Private   bool
+}
+
+type ListMember struct {
+	ListID  uuid.UUID
+	UserID  uuid.UUID
+	AddedAt time.Time
+}
+
+type LoginAttempt struct {
+	AttemptKey    string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type Notification struct {
+	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	Type      string
+	GroupKey  string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	SessionID     uuid.UUID
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris string
+	Scopes       string
+}
+
+type PersonalToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type PinnedChirp struct {
+	UserID   uuid.UUID
+	ChirpID  uuid.UUID
+	PinnedAt time.Time
+}
+
+type RefreshToken struct {
+	TokenHash        string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	ExpiresAt        time.Time
+	RevokedAt        sql.NullTime
+	FamilyID         uuid.UUID
+	ReplacedBy       sql.NullString
+	UserAgent        string
+	IpAddress        string
+	SessionCreatedAt time.Time
+	LastUsedAt       time.Time
+	ClientID         sql.NullString
+	Scope            string
+}
+
+type RemoteActor struct {
+	UserID       uuid.UUID
+	ActorUri     string
+	Inbox        string
+	SharedInbox  string
+	PublicKeyID  string
+	PublicKeyPem string
+	UpdatedAt    time.Time
+}
+
+type RemoteNote struct {
+	ChirpID   uuid.UUID
+	ObjectUri string
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd sql.NullTime
+	CanceledAt       sql.NullTime
 }
 
 type User struct {
@@ -34,5 +243,71 @@ type User struct {
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
-	IsChirpyRed    bool
+	Username       sql.NullString
+}
+
+type UserKey struct {
+	UserID        uuid.UUID
+	CreatedAt     time.Time
+	PublicKeyPem  string
+	PrivateKeyPem string
+}
+
+type WebhookDeadLetter struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Source     string
+	EventID    string
+	Event      string
+	Payload    string
+	StatusCode int32
+	Error      string
+	ReplayedAt sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	EndpointID     uuid.UUID
+	EventID        uuid.UUID
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus int32
+	LastError      string
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	Events    string
+}
+
+type WebhookEvent struct {
+	Source      string
+	ID          string
+	Event       string
+	ProcessedAt time.Time
+}
+
+type WebsocketTicket struct {
+	TicketHash      string
+	CreatedAt       time.Time
+	UserID          uuid.UUID
+	SessionID       uuid.NullUUID
+	ClientID        sql.NullString
+	PersonalTokenID uuid.NullUUID
+	Scope           string
+	TokenExpiresAt  sql.NullTime
+	ExpiresAt       time.Time
+	UsedAt          sql.NullTime
 }
@@ -0,0 +1,129 @@
+// Package entitlements maps a user's tier to what they may do. Handlers
+// ask it for a capability instead of checking the tier themselves, so
+// limits can change, or move between tiers, without touching them.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Tier is a level of membership.
+type Tier string
+
+const (
+	TierFree Tier = "free"
+	TierRed  Tier = "red"
+)
+
+// Capability is something a tier may be allowed to do, possibly up to a
+// limit. Clients are shown every capability, so one is only added here
+// once a handler enforces it.
+type Capability string
+
+const (
+	// ChirpLength limits the characters in a chirp.
+	ChirpLength Capability = "chirp_length"
+	// PinnedChirps limits how many of their chirps a user can pin to their
+	// profile.
+	PinnedChirps Capability = "pinned_chirps"
+)
+
+// Capabilities lists every capability, in display order.
+var Capabilities = []Capability{ChirpLength, PinnedChirps}
+
+// Grant is what a tier gets for one capability. A Limit of 0 means no
+// limit.
+type Grant struct {
+	Allowed bool `json:"allowed"`
+	Limit   int  `json:"limit,omitempty"`
+}
+
+// Entitlements holds the grants for every tier.
+type Entitlements struct {
+	tiers map[Tier]map[Capability]Grant
+}
+
+// Default returns the built-in grants.
+func Default() *Entitlements {
+	return &Entitlements{tiers: map[Tier]map[Capability]Grant{
+		TierFree: {
+			ChirpLength:  {Allowed: true, Limit: 140},
+			PinnedChirps: {Allowed: true, Limit: 1},
+		},
+		TierRed: {
+			ChirpLength:  {Allowed: true, Limit: 1000},
+			PinnedChirps: {Allowed: true, Limit: 5},
+		},
+	}}
+}
+
+// Load reads grants from JSON shaped like
+//
+//	{"red": {"chirp_length": {"allowed": true, "limit": 500}}}
+//
+// on top of the defaults, so the file only needs what it changes.
+func Load(r io.Reader) (*Entitlements, error) {
+	overrides := map[Tier]map[Capability]Grant{}
+	err := json.NewDecoder(r).Decode(&overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	e := Default()
+	for tier, grants := range overrides {
+		if e.tiers[tier] == nil {
+			e.tiers[tier] = map[Capability]Grant{}
+		}
+		for capability, grant := range grants {
+			if !known(capability) {
+				return nil, fmt.Errorf("unknown capability %q for tier %q", capability, tier)
+			}
+			if grant.Limit < 0 {
+				return nil, fmt.Errorf("negative limit for %q in tier %q", capability, tier)
+			}
+			e.tiers[tier][capability] = grant
+		}
+	}
+	return e, nil
+}
+
+// LoadFile is Load for a file on disk.
+func LoadFile(path string) (*Entitlements, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Check returns what tier gets for capability. Unknown tiers get the free
+// tier's grants; capabilities a tier doesn't list aren't allowed.
+func (e *Entitlements) Check(tier Tier, capability Capability) Grant {
+	grants, ok := e.tiers[tier]
+	if !ok {
+		grants = e.tiers[TierFree]
+	}
+	return grants[capability]
+}
+
+// For returns every grant for tier, keyed by capability.
+func (e *Entitlements) For(tier Tier) map[Capability]Grant {
+	grants := map[Capability]Grant{}
+	for _, capability := range Capabilities {
+		grants[capability] = e.Check(tier, capability)
+	}
+	return grants
+}
+
+func known(capability Capability) bool {
+	for _, c := range Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
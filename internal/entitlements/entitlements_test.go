@@ -0,0 +1,67 @@
+package entitlements
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultTiers(t *testing.T) {
+	e := Default()
+
+	free := e.Check(TierFree, ChirpLength)
+	red := e.Check(TierRed, ChirpLength)
+	if !free.Allowed || !red.Allowed || red.Limit <= free.Limit {
+		t.Fatalf("Red chirp length %+v should exceed free %+v", red, free)
+	}
+	if free, red := e.Check(TierFree, PinnedChirps), e.Check(TierRed, PinnedChirps); free.Limit == 0 || red.Limit <= free.Limit {
+		t.Fatalf("Red pins %+v should exceed free %+v", red, free)
+	}
+	if got := e.Check("platinum", ChirpLength); got != free {
+		t.Fatalf("Unknown tier got %+v, want the free grant %+v", got, free)
+	}
+	if e.Check(TierRed, "time_travel").Allowed {
+		t.Fatal("Unknown capability allowed")
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	e, err := Load(strings.NewReader(`{
+		"red": {"chirp_length": {"allowed": true, "limit": 500}},
+		"staff": {"pinned_chirps": {"allowed": true}}
+	}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got := e.Check(TierRed, ChirpLength); got.Limit != 500 {
+		t.Fatalf("Red chirp length limit = %d, want 500", got.Limit)
+	}
+	if !e.Check(TierRed, PinnedChirps).Allowed {
+		t.Fatal("Override dropped a default grant")
+	}
+	if !e.Check("staff", PinnedChirps).Allowed {
+		t.Fatal("New tier's grant missing")
+	}
+	if e.Check("staff", ChirpLength).Allowed {
+		t.Fatal("New tier got a grant it doesn't list")
+	}
+
+	grants := e.For(TierRed)
+	if len(grants) != len(Capabilities) {
+		t.Fatalf("For() returned %d grants, want %d", len(grants), len(Capabilities))
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`{"red": {"time_travel": {"allowed": true}}}`,
+		`{"red": {"scheduled_posts": {"allowed": true}}}`,
+		`{"red": {"chirp_length": {"allowed": true, "limit": -1}}}`,
+	}
+	for _, config := range tests {
+		if _, err := Load(strings.NewReader(config)); err == nil {
+			t.Errorf("Load(%s) error = nil", config)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package feed renders Atom and RSS feeds and answers conditional GETs
+// for them.
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Feed is the format-independent content of a feed. Entries should be
+// newest first.
+type Feed struct {
+	Title       string
+	Description string
+	// ID identifies the feed permanently; Atom readers use it to tell
+	// feeds apart.
+	ID       string
+	Link     string
+	SelfLink string
+	Updated  time.Time
+	Entries  []Entry
+}
+
+type Entry struct {
+	ID        string
+	Title     string
+	Link      string
+	Content   string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Links     []atomLink `xml:"link"`
+	Author    atomPerson `xml:"author"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Atom renders the feed as Atom 1.0.
+func (f Feed) Atom() ([]byte, error) {
+	feed := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.ID,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.SelfLink},
+			{Rel: "alternate", Href: f.Link},
+		},
+	}
+	for _, entry := range f.Entries {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     entry.Title,
+			ID:        entry.ID,
+			Published: entry.Published.UTC().Format(time.RFC3339),
+			Updated:   entry.Updated.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Href: entry.Link}},
+			Author:    atomPerson{Name: entry.Author},
+			Content:   atomText{Type: "text", Body: entry.Content},
+		})
+	}
+	return marshal(feed)
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS renders the feed as RSS 2.0. RSS has no per-item updated time, so
+// edits only show through lastBuildDate.
+func (f Feed) RSS() ([]byte, error) {
+	feed := rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, entry := range f.Entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			Description: entry.Content,
+			GUID:        rssGUID{Value: entry.ID},
+			PubDate:     entry.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshal(feed)
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// Title shortens a chirp body into an entry title of at most maxRunes.
+func Title(body string, maxRunes int) string {
+	body = strings.Join(strings.Fields(body), " ")
+	runes := []rune(body)
+	if len(runes) <= maxRunes {
+		return body
+	}
+	return strings.TrimSpace(string(runes[:maxRunes-1])) + "…"
+}
+
+// ETag is a strong validator for a feed built from parts, which together
+// must change whenever the rendered feed would. It can be computed
+// before the feed is loaded, so a 304 costs no rendering.
+func ETag(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// NotModified reports whether a conditional GET with header can be
+// answered with 304. As RFC 9110 requires, If-None-Match is used instead
+// of If-Modified-Since when both are sent.
+func NotModified(header http.Header, etag string, lastModified time.Time) bool {
+	if match := header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,135 @@
+package feed
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return Feed{
+		Title:    "Chirps by @alice",
+		ID:       "https://chirpy.example/users/1",
+		Link:     "https://chirpy.example/api/chirps?author_id=1",
+		SelfLink: "https://chirpy.example/users/1/feed.atom",
+		Updated:  published.Add(time.Hour),
+		Entries: []Entry{{
+			ID:        "urn:uuid:2",
+			Title:     "Hello <world>",
+			Link:      "https://chirpy.example/api/chirps/2",
+			Content:   "Hello <world> & friends",
+			Author:    "alice",
+			Published: published,
+			Updated:   published.Add(time.Hour),
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := testFeed().Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := atomFeed{}
+	err = xml.Unmarshal(body, &parsed)
+	if err != nil {
+		t.Fatalf("Atom() isn't valid XML: %v", err)
+	}
+	if parsed.Updated != "2024-03-01T13:00:00Z" {
+		t.Errorf("updated = %q", parsed.Updated)
+	}
+	if len(parsed.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(parsed.Entries))
+	}
+	entry := parsed.Entries[0]
+	if entry.Content.Body != "Hello <world> & friends" || entry.Published != "2024-03-01T12:00:00Z" || entry.Author.Name != "alice" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !strings.Contains(string(body), `xmlns="http://www.w3.org/2005/Atom"`) {
+		t.Error("missing the Atom namespace")
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := testFeed().RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := rss{}
+	err = xml.Unmarshal(body, &parsed)
+	if err != nil {
+		t.Fatalf("RSS() isn't valid XML: %v", err)
+	}
+	if parsed.Version != "2.0" || parsed.Channel.LastBuildDate != "Fri, 01 Mar 2024 13:00:00 +0000" {
+		t.Errorf("channel = %+v", parsed.Channel)
+	}
+	item := parsed.Channel.Items[0]
+	if item.GUID.Value != "urn:uuid:2" || item.GUID.IsPermaLink || item.PubDate != "Fri, 01 Mar 2024 12:00:00 +0000" {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	if got := Title("short\nchirp", 20); got != "short chirp" {
+		t.Errorf("Title() = %q", got)
+	}
+	if got := Title("héllo wörld", 6); got != "héllo…" {
+		t.Errorf("Title() = %q, want héllo…", got)
+	}
+	if got := Title("fits", 10); got != "fits" {
+		t.Errorf("Title() = %q, want fits", got)
+	}
+}
+
+func TestETag(t *testing.T) {
+	if ETag("a", "b") != ETag("a", "b") {
+		t.Error("ETag() isn't stable")
+	}
+	if ETag("ab", "c") == ETag("a", "bc") {
+		t.Error("ETag() doesn't tell its parts apart")
+	}
+	if got := ETag("a"); !strings.HasPrefix(got, `"`) || !strings.HasSuffix(got, `"`) {
+		t.Errorf("ETag() = %s, want a quoted strong validator", got)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 3, 1, 13, 0, 0, 500, time.UTC)
+	etag := ETag("feed")
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   bool
+	}{
+		{name: "unconditional", header: map[string]string{}, want: false},
+		{name: "matching etag", header: map[string]string{"If-None-Match": `"other", ` + etag}, want: true},
+		{name: "weak etag", header: map[string]string{"If-None-Match": "W/" + etag}, want: true},
+		{name: "stale etag", header: map[string]string{"If-None-Match": `"other"`}, want: false},
+		{name: "not modified since", header: map[string]string{"If-Modified-Since": "Fri, 01 Mar 2024 13:00:00 GMT"}, want: true},
+		{name: "modified since", header: map[string]string{"If-Modified-Since": "Fri, 01 Mar 2024 12:59:59 GMT"}, want: false},
+		{
+			name: "etag wins",
+			header: map[string]string{
+				"If-None-Match": `"other"`, "If-Modified-Since": "Fri, 01 Mar 2024 13:00:00 GMT",
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.header {
+				header.Set(name, value)
+			}
+			if got := NotModified(header, etag, lastModified); got != tt.want {
+				t.Errorf("NotModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Package hashtag finds the #hashtags in chirps.
+package hashtag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Extract returns the distinct hashtags in body, lowercased and without
+// the leading #, in the order they first appear. A hashtag is a # at the
+// start of a word followed by letters, digits or underscores.
+func Extract(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' || (i > 0 && isTagRune(runes[i-1])) {
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && isTagRune(runes[j]) {
+			j++
+		}
+		if j == i+1 {
+			continue
+		}
+		tag := strings.ToLower(string(runes[i+1 : j]))
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		i = j - 1
+	}
+	return tags
+}
+
+// Normalize turns user input such as "#Go" into the form Extract returns.
+func Normalize(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,36 @@
+package hashtag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "no tags here", want: []string{}},
+		{body: "#golang is fun", want: []string{"golang"}},
+		{body: "I like #Go and #go_lang, #GO!", want: []string{"go", "go_lang"}},
+		{body: "email me at me#example or see issue#12", want: []string{}},
+		{body: "lonely # and ## signs", want: []string{}},
+		{body: "#café #日本", want: []string{"café", "日本"}},
+		{body: "(#wrapped)", want: []string{"wrapped"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			got := Extract(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize(" #GoLang "); got != "golang" {
+		t.Errorf("Normalize() = %q, want golang", got)
+	}
+}
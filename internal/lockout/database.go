@@ -0,0 +1,65 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+)
+
+// DBStore keeps records in the login_attempts table so every instance
+// shares them.
+type DBStore struct {
+	db *database.Queries
+}
+
+func NewDBStore(db *database.Queries) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Get(ctx context.Context, key string) (Record, error) {
+	attempts, err := s.db.GetLoginAttempts(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return toRecord(attempts), nil
+}
+
+func (s *DBStore) Fail(ctx context.Context, key string, now, windowStart time.Time) (Record, error) {
+	attempts, err := s.db.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		AttemptKey:    key,
+		LastFailureAt: now.UTC(),
+		WindowStart:   windowStart.UTC(),
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	return toRecord(attempts), nil
+}
+
+func (s *DBStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.db.LockLoginAttempts(ctx, database.LockLoginAttemptsParams{
+		AttemptKey:  key,
+		LockedUntil: sql.NullTime{Time: until.UTC(), Valid: true},
+	})
+}
+
+func (s *DBStore) Reset(ctx context.Context, key string) error {
+	return s.db.ResetLoginAttempts(ctx, key)
+}
+
+func toRecord(attempts database.LoginAttempt) Record {
+	record := Record{
+		Failures:      int(attempts.Failures),
+		LastFailureAt: attempts.LastFailureAt,
+	}
+	if attempts.LockedUntil.Valid {
+		record.LockedUntil = attempts.LockedUntil.Time
+	}
+	return record
+}
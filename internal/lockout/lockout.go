@@ -0,0 +1,124 @@
+// Package lockout slows down and then temporarily blocks repeated failed
+// attempts, such as password guesses, against a key.
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Record is the failure history of one key, such as an email address or a
+// client IP.
+type Record struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store keeps records. Stores shared between instances, like the database
+// store, let every instance see the same counts.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	// Fail adds a failure to key and returns the updated record. Failures
+	// before windowStart are forgotten first.
+	Fail(ctx context.Context, key string, now, windowStart time.Time) (Record, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy decides how long a key must wait after failing.
+type Policy struct {
+	// MaxFailures within Window locks the key for LockDuration.
+	MaxFailures  int
+	Window       time.Duration
+	LockDuration time.Duration
+
+	// Below MaxFailures, the n-th failure makes the key wait
+	// BaseDelay * 2^(n-1), capped at MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxFailures:  5,
+		Window:       15 * time.Minute,
+		LockDuration: 15 * time.Minute,
+		BaseDelay:    time.Second,
+		MaxDelay:     30 * time.Second,
+	}
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Check returns how long key must wait before its next attempt, or zero if
+// it may try now.
+func (l *Limiter) Check(ctx context.Context, key string) (time.Duration, error) {
+	record, err := l.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	now := l.now()
+	if now.Sub(record.LastFailureAt) > l.policy.Window && now.After(record.LockedUntil) {
+		return 0, nil
+	}
+
+	blockedUntil := record.LastFailureAt.Add(l.backoff(record.Failures))
+	if record.LockedUntil.After(blockedUntil) {
+		blockedUntil = record.LockedUntil
+	}
+	if !blockedUntil.After(now) {
+		return 0, nil
+	}
+	return blockedUntil.Sub(now), nil
+}
+
+// Fail records a failed attempt. It reports whether this failure locked
+// the key.
+func (l *Limiter) Fail(ctx context.Context, key string) (bool, error) {
+	now := l.now()
+	record, err := l.store.Fail(ctx, key, now, now.Add(-l.policy.Window))
+	if err != nil {
+		return false, err
+	}
+	if record.Failures < l.policy.MaxFailures || record.LockedUntil.After(now) {
+		return false, nil
+	}
+
+	err = l.store.Lock(ctx, key, now.Add(l.policy.LockDuration))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reset forgets every failure for key, typically after a success.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+func (l *Limiter) backoff(failures int) time.Duration {
+	if failures <= 0 || l.policy.BaseDelay <= 0 {
+		return 0
+	}
+	delay := l.policy.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= l.policy.MaxDelay {
+			return l.policy.MaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,121 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(policy Policy) (*Limiter, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryStore(), policy)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestBackoff(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{
+		MaxFailures:  10,
+		Window:       time.Hour,
+		LockDuration: time.Hour,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Second,
+	}
+	limiter, _ := newTestLimiter(policy)
+
+	want := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		5 * time.Second,
+		5 * time.Second,
+	}
+	for i, wantWait := range want {
+		if _, err := limiter.Fail(ctx, "email:a@example.com"); err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+		wait, err := limiter.Check(ctx, "email:a@example.com")
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		if wait != wantWait {
+			t.Errorf("after %d failures wait = %v, want %v", i+1, wait, wantWait)
+		}
+	}
+
+	wait, err := limiter.Check(ctx, "email:b@example.com")
+	if err != nil || wait != 0 {
+		t.Fatalf("Unrelated key must not wait, got %v, %v", wait, err)
+	}
+}
+
+func TestLockAfterMaxFailures(t *testing.T) {
+	ctx := context.Background()
+	policy := DefaultPolicy()
+	policy.MaxFailures = 3
+	policy.LockDuration = 10 * time.Minute
+	limiter, now := newTestLimiter(policy)
+
+	for i := 1; i <= 3; i++ {
+		locked, err := limiter.Fail(ctx, "ip:10.0.0.1")
+		if err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+		if locked != (i == 3) {
+			t.Fatalf("failure %d locked = %v", i, locked)
+		}
+	}
+
+	wait, _ := limiter.Check(ctx, "ip:10.0.0.1")
+	if wait != 10*time.Minute {
+		t.Fatalf("wait = %v, want the lock duration", wait)
+	}
+
+	// Further failures while locked don't report a new lock.
+	locked, _ := limiter.Fail(ctx, "ip:10.0.0.1")
+	if locked {
+		t.Fatal("Failure while already locked reported a new lock")
+	}
+
+	*now = now.Add(11 * time.Minute)
+	wait, _ = limiter.Check(ctx, "ip:10.0.0.1")
+	if wait != 0 {
+		t.Fatalf("wait = %v after lock expired, want 0", wait)
+	}
+}
+
+func TestFailuresOutsideWindowAreForgotten(t *testing.T) {
+	ctx := context.Background()
+	policy := DefaultPolicy()
+	policy.MaxFailures = 3
+	policy.Window = time.Minute
+	limiter, now := newTestLimiter(policy)
+
+	limiter.Fail(ctx, "email:a@example.com")
+	limiter.Fail(ctx, "email:a@example.com")
+
+	*now = now.Add(2 * time.Minute)
+	locked, _ := limiter.Fail(ctx, "email:a@example.com")
+	if locked {
+		t.Fatal("Failures outside the window counted towards the lock")
+	}
+	wait, _ := limiter.Check(ctx, "email:a@example.com")
+	if wait != policy.BaseDelay {
+		t.Fatalf("wait = %v, want the first backoff step %v", wait, policy.BaseDelay)
+	}
+}
+
+func TestReset(t *testing.T) {
+	ctx := context.Background()
+	limiter, _ := newTestLimiter(DefaultPolicy())
+
+	limiter.Fail(ctx, "email:a@example.com")
+	if err := limiter.Reset(ctx, "email:a@example.com"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	wait, _ := limiter.Check(ctx, "email:a@example.com")
+	if wait != 0 {
+		t.Fatalf("wait = %v after reset, want 0", wait)
+	}
+}
@@ -0,0 +1,90 @@
+// Package notification decides how events are grouped into notifications
+// and how they're described.
+package notification
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Types of notification. Users can turn each off.
+const (
+	TypeFollow  = "follow"
+	TypeLike    = "like"
+	TypeMention = "mention"
+	TypeReply   = "reply"
+)
+
+// Types lists every notification type.
+var Types = []string{TypeFollow, TypeLike, TypeMention, TypeReply}
+
+// ValidType reports whether t is a notification type.
+func ValidType(t string) bool {
+	return slices.Contains(Types, t)
+}
+
+// GroupKey identifies the notification an event is added to while that
+// notification is unread. Follows are grouped together, likes and replies
+// by chirp; every mention is its own notification.
+func GroupKey(t string, chirpID uuid.UUID) string {
+	if t == TypeFollow {
+		return t
+	}
+	return t + ":" + chirpID.String()
+}
+
+// Summary describes a notification with actorCount actors, such as "5
+// people liked your chirp".
+func Summary(t string, actorCount int) string {
+	who := "1 person"
+	if actorCount != 1 {
+		who = fmt.Sprintf("%d people", actorCount)
+	}
+	switch t {
+	case TypeFollow:
+		return who + " followed you"
+	case TypeLike:
+		return who + " liked your chirp"
+	case TypeMention:
+		return who + " mentioned you"
+	case TypeReply:
+		return who + " replied to your chirp"
+	}
+	return who + " interacted with you"
+}
+
+// Mentions returns the distinct usernames mentioned with @ in body,
+// lowercased and in order. Like a hashtag, a mention must start a word,
+// so email addresses don't count.
+func Mentions(body string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isUsernameRune(runes[i-1])) {
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && isUsernameRune(runes[j]) {
+			j++
+		}
+		if j == i+1 {
+			continue
+		}
+		username := strings.ToLower(string(runes[i+1 : j]))
+		if !seen[username] {
+			seen[username] = true
+			mentions = append(mentions, username)
+		}
+		i = j - 1
+	}
+	return mentions
+}
+
+func isUsernameRune(r rune) bool {
+	return r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
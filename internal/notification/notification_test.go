@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGroupKey(t *testing.T) {
+	chirpA, chirpB := uuid.New(), uuid.New()
+
+	if GroupKey(TypeFollow, chirpA) != GroupKey(TypeFollow, chirpB) {
+		t.Error("follows should share a group")
+	}
+	if GroupKey(TypeLike, chirpA) == GroupKey(TypeLike, chirpB) {
+		t.Error("likes of different chirps should be separate")
+	}
+	if GroupKey(TypeLike, chirpA) == GroupKey(TypeReply, chirpA) {
+		t.Error("likes and replies of a chirp should be separate")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		typ   string
+		count int
+		want  string
+	}{
+		{typ: TypeLike, count: 5, want: "5 people liked your chirp"},
+		{typ: TypeLike, count: 1, want: "1 person liked your chirp"},
+		{typ: TypeFollow, count: 2, want: "2 people followed you"},
+		{typ: TypeReply, count: 1, want: "1 person replied to your chirp"},
+		{typ: TypeMention, count: 1, want: "1 person mentioned you"},
+	}
+	for _, tt := range tests {
+		if got := Summary(tt.typ, tt.count); got != tt.want {
+			t.Errorf("Summary(%q, %d) = %q, want %q", tt.typ, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "no mentions", want: []string{}},
+		{body: "hi @Alice and @bob_1, also @alice", want: []string{"alice", "bob_1"}},
+		{body: "mail me@example.com", want: []string{}},
+		{body: "(@carol) @ @", want: []string{"carol"}},
+		{body: "@dave's chirp", want: []string{"dave"}},
+	}
+	for _, tt := range tests {
+		if got := Mentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Mentions(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestValidType(t *testing.T) {
+	if !ValidType(TypeReply) || ValidType("poke") {
+		t.Error("ValidType is wrong")
+	}
+}
@@ -0,0 +1,117 @@
+// Package oauth holds the protocol rules of Chirpy's OAuth 2.0
+// authorization server: scopes, PKCE and redirect URI checks.
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+	ScopeProfile     = "profile"
+)
+
+// Scopes lists every scope a client may request, in display order.
+var Scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfile}
+
+var scopeDescriptions = map[string]string{
+	ScopeChirpsRead:  "Read chirps",
+	ScopeChirpsWrite: "Post and delete chirps as you",
+	ScopeProfile:     "See and change your email, password and sessions",
+}
+
+// DescribeScope returns the text shown for a scope on the consent page.
+func DescribeScope(scope string) string {
+	return scopeDescriptions[scope]
+}
+
+// ParseScope splits a space-separated scope parameter, rejecting unknown
+// scopes and dropping duplicates. The result is in display order.
+func ParseScope(scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	for _, s := range requested {
+		if !slices.Contains(Scopes, s) {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+	}
+
+	parsed := []string{}
+	for _, s := range Scopes {
+		if slices.Contains(requested, s) {
+			parsed = append(parsed, s)
+		}
+	}
+	return parsed, nil
+}
+
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// Subset reports whether every scope in requested is in allowed.
+func Subset(requested, allowed []string) bool {
+	for _, s := range requested {
+		if !slices.Contains(allowed, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyPKCE checks a code_verifier against the code_challenge sent to the
+// authorization endpoint. Only the S256 method is supported.
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// ValidChallenge reports whether a code_challenge looks like the
+// base64url encoding of a SHA-256 digest.
+func ValidChallenge(challenge string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// ValidRedirectURI reports whether a redirect URI may be registered:
+// absolute, without a fragment, and https unless it points at loopback.
+func ValidRedirectURI(uri string) bool {
+	parsed, err := url.Parse(uri)
+	if err != nil || !parsed.IsAbs() || parsed.Fragment != "" || parsed.Host == "" {
+		return false
+	}
+	if parsed.Scheme == "https" {
+		return true
+	}
+	host := parsed.Hostname()
+	return parsed.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1")
+}
+
+// MatchRedirectURI reports whether uri is exactly one of the registered
+// URIs. Prefix or pattern matching would let an attacker pick a path.
+func MatchRedirectURI(uri string, registered []string) bool {
+	return slices.Contains(registered, uri)
+}
+
+// NewSecret returns a random identifier suitable for client IDs, client
+// secrets and authorization codes.
+func NewSecret(bytes int) (string, error) {
+	b := make([]byte, bytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
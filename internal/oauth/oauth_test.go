@@ -0,0 +1,92 @@
+package oauth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// The example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !ValidChallenge(challenge) {
+		t.Fatal("RFC 7636 challenge reported invalid")
+	}
+	if !VerifyPKCE(verifier, challenge) {
+		t.Fatal("RFC 7636 verifier didn't match its challenge")
+	}
+	if VerifyPKCE(verifier+"x", challenge) {
+		t.Fatal("Wrong verifier matched")
+	}
+	if VerifyPKCE("short", challenge) {
+		t.Fatal("Verifier shorter than 43 characters accepted")
+	}
+	if ValidChallenge("not-a-digest") {
+		t.Fatal("Malformed challenge reported valid")
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", scope: "", want: []string{}},
+		{name: "reordered and deduplicated", scope: "profile chirps:read profile", want: []string{ScopeChirpsRead, ScopeProfile}},
+		{name: "extra whitespace", scope: "  chirps:write  ", want: []string{ScopeChirpsWrite}},
+		{name: "unknown", scope: "chirps:read admin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScope(tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScope() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubset(t *testing.T) {
+	allowed := []string{ScopeChirpsRead, ScopeProfile}
+	if !Subset([]string{ScopeChirpsRead}, allowed) {
+		t.Fatal("Allowed scope rejected")
+	}
+	if Subset([]string{ScopeChirpsWrite}, allowed) {
+		t.Fatal("Scope outside the allowed set accepted")
+	}
+}
+
+func TestRedirectURIs(t *testing.T) {
+	tests := []struct {
+		uri   string
+		valid bool
+	}{
+		{uri: "https://app.example.com/callback", valid: true},
+		{uri: "http://localhost:3000/callback", valid: true},
+		{uri: "http://127.0.0.1/cb", valid: true},
+		{uri: "http://app.example.com/callback", valid: false},
+		{uri: "https://app.example.com/callback#frag", valid: false},
+		{uri: "/callback", valid: false},
+		{uri: "javascript:alert(1)", valid: false},
+	}
+	for _, tt := range tests {
+		if got := ValidRedirectURI(tt.uri); got != tt.valid {
+			t.Errorf("ValidRedirectURI(%q) = %v, want %v", tt.uri, got, tt.valid)
+		}
+	}
+
+	registered := []string{"https://app.example.com/callback"}
+	if !MatchRedirectURI("https://app.example.com/callback", registered) {
+		t.Fatal("Registered redirect URI rejected")
+	}
+	if MatchRedirectURI("https://app.example.com/callback/evil", registered) {
+		t.Fatal("Redirect URI with extra path accepted")
+	}
+}
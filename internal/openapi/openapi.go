@@ -0,0 +1,270 @@
+// Package openapi holds Chirpy's OpenAPI 3.1 document and validates
+// requests against it. Only the parts of OpenAPI and JSON Schema the
+// document uses are understood.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// JSON returns the document as served.
+func JSON() []byte {
+	return document
+}
+
+// Document is a parsed OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	routes []route
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// PathItem maps lowercase HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Content map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON Schema. Type is a string or, for nullable values, a
+// list such as ["string", "null"].
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// Types returns the schema's types, without "null".
+func (s *Schema) Types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, v := range t {
+			if name, ok := v.(string); ok && name != "null" {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// Nullable reports whether the schema allows null.
+func (s *Schema) Nullable() bool {
+	list, ok := s.Type.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, v := range list {
+		if v == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// Load parses the embedded document.
+func Load() (*Document, error) {
+	return Parse(document)
+}
+
+// Parse parses a document and checks that its references resolve.
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	err := json.Unmarshal(data, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			err := doc.checkRefs(op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			doc.routes = append(doc.routes, newRoute(strings.ToUpper(method), path, op))
+		}
+	}
+	for name, schema := range doc.Components.Schemas {
+		err := doc.checkSchemaRefs(schema)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	// Literal segments win over parameters, as in http.ServeMux.
+	sort.Slice(doc.routes, func(i, j int) bool {
+		return doc.routes[i].literals > doc.routes[j].literals
+	})
+	return doc, nil
+}
+
+// Resolve follows a schema's $ref, if it has one.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func (d *Document) checkRefs(op *Operation) error {
+	for _, param := range op.Parameters {
+		err := d.checkSchemaRefs(param.Schema)
+		if err != nil {
+			return err
+		}
+	}
+	if op.RequestBody != nil {
+		for _, media := range op.RequestBody.Content {
+			err := d.checkSchemaRefs(media.Schema)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, resp := range op.Responses {
+		for _, media := range resp.Content {
+			err := d.checkSchemaRefs(media.Schema)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Document) checkSchemaRefs(s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		if !ok || d.Components.Schemas[name] == nil {
+			return fmt.Errorf("unresolved reference %q", s.Ref)
+		}
+		return nil
+	}
+	for _, property := range s.Properties {
+		err := d.checkSchemaRefs(property)
+		if err != nil {
+			return err
+		}
+	}
+	if additional, ok := s.additional(); ok {
+		err := d.checkSchemaRefs(additional)
+		if err != nil {
+			return err
+		}
+	}
+	return d.checkSchemaRefs(s.Items)
+}
+
+// additional returns the additionalProperties schema, if it is one.
+func (s *Schema) additional() (*Schema, bool) {
+	object, ok := s.AdditionalProperties.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	data, _ := json.Marshal(object)
+	additional := &Schema{}
+	json.Unmarshal(data, additional)
+	return additional, true
+}
+
+// route is a path template split into segments.
+type route struct {
+	method   string
+	path     string
+	segments []string
+	literals int
+	op       *Operation
+}
+
+func newRoute(method, path string, op *Operation) route {
+	r := route{method: method, path: path, segments: strings.Split(strings.Trim(path, "/"), "/"), op: op}
+	for _, segment := range r.segments {
+		if !isParam(segment) {
+			r.literals++
+		}
+	}
+	return r
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// Find returns the operation for a request and its path parameters. ok is
+// false if the document doesn't describe it.
+func (d *Document) Find(method, path string) (op *Operation, params map[string]string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, r := range d.routes {
+		if r.method != method || len(r.segments) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		matched := true
+		for i, segment := range r.segments {
+			if isParam(segment) {
+				params[strings.Trim(segment, "{}")] = segments[i]
+				continue
+			}
+			if segment != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return r.op, params, true
+		}
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,168 @@
+package openapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadDocument(t *testing.T) *Document {
+	t.Helper()
+	doc, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return doc
+}
+
+func TestParseRejectsUnresolvedRefs(t *testing.T) {
+	_, err := Parse([]byte(`{
+		"openapi": "3.1.0",
+		"paths": {"/a": {"get": {"responses": {"200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}}}}}}}
+	}`))
+	if err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Errorf("Parse() error = %v, want an unresolved reference", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	doc := loadDocument(t)
+	tests := []struct {
+		method     string
+		path       string
+		wantID     string
+		wantParams map[string]string
+	}{
+		{method: "GET", path: "/api/chirps/abc", wantID: "getChirp", wantParams: map[string]string{"chirpID": "abc"}},
+		{method: "DELETE", path: "/api/sessions", wantID: "revokeOtherSessions", wantParams: map[string]string{}},
+		{method: "DELETE", path: "/api/sessions/abc", wantID: "revokeSession", wantParams: map[string]string{"sessionID": "abc"}},
+		{method: "GET", path: "/api/users/me/entitlements", wantID: "getEntitlements", wantParams: map[string]string{}},
+		{method: "GET", path: "/api/users/abc/lists", wantID: "listUserLists", wantParams: map[string]string{"userID": "abc"}},
+		{method: "PATCH", path: "/api/chirps/abc"},
+		{method: "GET", path: "/nowhere"},
+	}
+	for _, tt := range tests {
+		op, params, ok := doc.Find(tt.method, tt.path)
+		if tt.wantID == "" {
+			if ok {
+				t.Errorf("Find(%s %s) = %s, want no operation", tt.method, tt.path, op.OperationID)
+			}
+			continue
+		}
+		if !ok || op.OperationID != tt.wantID || !reflect.DeepEqual(params, tt.wantParams) {
+			t.Errorf("Find(%s %s) = %v, %v, %v; want %s, %v", tt.method, tt.path, op, params, ok, tt.wantID, tt.wantParams)
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc := loadDocument(t)
+	const id = "0b3c3c6e-5a2e-4bb1-9a53-0c8e2d1e7f10"
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        []FieldError
+	}{
+		{name: "valid body", method: "POST", target: "/api/chirps", body: `{"body": "hello", "reply_to_id": "` + id + `"}`},
+		{name: "missing field", method: "POST", target: "/api/chirps", body: `{}`,
+			want: []FieldError{{In: "body", Field: "body", Message: "is required"}}},
+		{name: "unknown field", method: "POST", target: "/api/chirps", body: `{"body": "hi", "bdy": "hi"}`,
+			want: []FieldError{{In: "body", Field: "bdy", Message: "isn't a known field"}}},
+		{name: "wrong type", method: "POST", target: "/api/chirps", body: `{"body": 5}`,
+			want: []FieldError{{In: "body", Field: "body", Message: "must be a string"}}},
+		{name: "bad format in array", method: "POST", target: "/api/conversations", body: `{"participant_ids": ["nope"]}`,
+			want: []FieldError{{In: "body", Field: "participant_ids[0]", Message: "must be a valid uuid"}}},
+		{name: "too few items", method: "POST", target: "/api/conversations", body: `{"participant_ids": []}`,
+			want: []FieldError{{In: "body", Field: "participant_ids", Message: "must have at least 1 items"}}},
+		{name: "not JSON", method: "POST", target: "/api/chirps", body: `{`,
+			want: []FieldError{{In: "body", Message: "isn't valid JSON"}}},
+		{name: "missing body", method: "POST", target: "/api/chirps",
+			want: []FieldError{{In: "body", Message: "is required"}}},
+		{name: "optional body", method: "POST", target: "/api/conversations/" + id + "/read"},
+		{name: "nullable", method: "POST", target: "/api/conversations/" + id + "/read", body: `{"message_id": null}`},
+		{name: "additional properties schema", method: "PUT", target: "/api/notifications/preferences", body: `{"like": "no"}`,
+			want: []FieldError{{In: "body", Field: "like", Message: "must be a boolean"}}},
+		{name: "content type", method: "POST", target: "/api/chirps", contentType: "text/plain", body: `hi`,
+			want: []FieldError{{In: "body", Message: `content type "text/plain" isn't accepted`}}},
+		{name: "form", method: "POST", target: "/oauth/token", contentType: "application/x-www-form-urlencoded", body: "grant_type=password",
+			want: []FieldError{{In: "body", Field: "grant_type", Message: `must be one of "authorization_code", "refresh_token"`}}},
+		{name: "path parameter", method: "GET", target: "/api/chirps/nope",
+			want: []FieldError{{In: "path", Field: "chirpID", Message: "must be a valid uuid"}}},
+		{name: "query enum", method: "GET", target: "/api/chirps?sort=sideways",
+			want: []FieldError{{In: "query", Field: "sort", Message: `must be one of "asc", "desc"`}}},
+		{name: "query integer", method: "GET", target: "/api/notifications?limit=many",
+			want: []FieldError{{In: "query", Field: "limit", Message: "must be an integer"}}},
+		{name: "query minimum", method: "GET", target: "/api/notifications?limit=0",
+			want: []FieldError{{In: "query", Field: "limit", Message: "must be at least 1"}}},
+		{name: "query boolean", method: "GET", target: "/api/notifications?unread=maybe",
+			want: []FieldError{{In: "query", Field: "unread", Message: "must be true or false"}}},
+		{name: "required query", method: "GET", target: "/.well-known/webfinger",
+			want: []FieldError{{In: "query", Field: "resource", Message: "is required"}}},
+		{name: "undescribed", method: "PATCH", target: "/api/chirps", body: `nonsense`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			err := doc.ValidateRequest(req)
+
+			var invalid *ValidationError
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateRequest() error = %v", err)
+				}
+			} else if !errors.As(err, &invalid) {
+				t.Fatalf("ValidateRequest() error = %v, want a ValidationError", err)
+			} else if !reflect.DeepEqual(invalid.Errors, tt.want) {
+				t.Errorf("ValidateRequest() errors = %+v, want %+v", invalid.Errors, tt.want)
+			}
+
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != tt.body {
+				t.Errorf("body after validating = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestValidateRequestReportsEveryError(t *testing.T) {
+	doc := loadDocument(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/lists", strings.NewReader(`{"name": "", "private": "yes", "extra": 1}`))
+	err := doc.ValidateRequest(req)
+
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("ValidateRequest() error = %v, want a ValidationError", err)
+	}
+	want := []FieldError{
+		{In: "body", Field: "extra", Message: "isn't a known field"},
+		{In: "body", Field: "name", Message: "can't be empty"},
+		{In: "body", Field: "private", Message: "must be a boolean"},
+	}
+	if !reflect.DeepEqual(invalid.Errors, want) {
+		t.Errorf("ValidateRequest() errors = %+v, want %+v", invalid.Errors, want)
+	}
+	if !strings.Contains(err.Error(), "body name: can't be empty") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestValidateRequestRejectsLargeBodies(t *testing.T) {
+	doc := loadDocument(t)
+	body := `{"name": "` + strings.Repeat("a", maxBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/lists", strings.NewReader(body))
+	err := doc.ValidateRequest(req)
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("ValidateRequest() error = %v, want %v", err, ErrBodyTooLarge)
+	}
+}
@@ -0,0 +1,364 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MechamJonathan/chirpy/internal/problem"
+	"github.com/google/uuid"
+)
+
+// maxBodyBytes caps the request bodies ValidateRequest reads.
+const maxBodyBytes = 1 << 20
+
+// ErrBodyTooLarge is returned by ValidateRequest for bodies over
+// maxBodyBytes. They're rejected rather than cut short, since a truncated
+// body would reach the handler looking like a different request.
+var ErrBodyTooLarge = errors.New("request body is too large")
+
+// FieldError is one part of a request that doesn't match the document.
+type FieldError = problem.FieldError
+
+// ValidationError lists everything wrong with a request.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := []string{}
+	for _, fieldErr := range e.Errors {
+		name := fieldErr.In
+		if fieldErr.Field != "" {
+			name += " " + fieldErr.Field
+		}
+		parts = append(parts, name+": "+fieldErr.Message)
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// ValidateRequest checks r's path and query parameters and JSON body
+// against the document, returning a *ValidationError if they don't
+// match. Requests the document doesn't describe are let through. The
+// body is read and replaced, so handlers can still decode it.
+func (d *Document) ValidateRequest(r *http.Request) error {
+	op, pathParams, ok := d.Find(r.Method, r.URL.Path)
+	if !ok {
+		return nil
+	}
+
+	errs := []FieldError{}
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var values []string
+		switch param.In {
+		case "path":
+			values = []string{pathParams[param.Name]}
+		case "query":
+			values = query[param.Name]
+		default:
+			continue
+		}
+		if len(values) == 0 {
+			if param.Required {
+				errs = append(errs, FieldError{In: param.In, Field: param.Name, Message: "is required"})
+			}
+			continue
+		}
+		for _, value := range values {
+			errs = append(errs, d.validateParameter(param, value)...)
+		}
+	}
+
+	if op.RequestBody != nil {
+		bodyErrs, err := d.validateBody(r, op.RequestBody)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, bodyErrs...)
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (d *Document) validateParameter(param Parameter, raw string) []FieldError {
+	schema := d.Resolve(param.Schema)
+	if schema == nil {
+		return nil
+	}
+	var value interface{} = raw
+	types := schema.Types()
+	if len(types) == 1 {
+		switch types[0] {
+		case "integer", "number":
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return []FieldError{{In: param.In, Field: param.Name, Message: "must be " + describe(types[:1])}}
+			}
+			value = n
+		case "boolean":
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return []FieldError{{In: param.In, Field: param.Name, Message: "must be true or false"}}
+			}
+			value = b
+		}
+	}
+	errs := d.validate(schema, value, param.Name)
+	for i := range errs {
+		errs[i].In = param.In
+	}
+	return errs
+}
+
+func (d *Document) validateBody(r *http.Request, body *RequestBody) ([]FieldError, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBodyBytes {
+		return nil, ErrBodyTooLarge
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return []FieldError{{In: "body", Message: "is required"}}, nil
+		}
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = "application/json"
+	}
+	media, ok := body.Content[mediaType]
+	if !ok {
+		return []FieldError{{In: "body", Message: "content type " + strconv.Quote(mediaType) + " isn't accepted"}}, nil
+	}
+
+	var value interface{}
+	if mediaType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			return []FieldError{{In: "body", Message: "isn't a valid form"}}, nil
+		}
+		object := map[string]interface{}{}
+		for key := range form {
+			object[key] = form.Get(key)
+		}
+		value = object
+	} else if strings.HasSuffix(mediaType, "json") {
+		err := json.Unmarshal(data, &value)
+		if err != nil {
+			return []FieldError{{In: "body", Message: "isn't valid JSON"}}, nil
+		}
+	} else {
+		return nil, nil
+	}
+
+	errs := d.validate(media.Schema, value, "")
+	for i := range errs {
+		errs[i].In = "body"
+	}
+	return errs, nil
+}
+
+// validate checks a decoded JSON value against a schema. path names the
+// value in errors.
+func (d *Document) validate(s *Schema, value interface{}, path string) []FieldError {
+	s = d.Resolve(s)
+	if s == nil {
+		return nil
+	}
+	fail := func(format string, args ...interface{}) []FieldError {
+		return []FieldError{{Field: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if value == nil {
+		if s.Nullable() || len(s.Types()) == 0 {
+			return nil
+		}
+		return fail("can't be null")
+	}
+
+	types := s.Types()
+	if len(types) > 0 && !matchesType(types, value) {
+		return fail("must be %s", describe(types))
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("must be one of %s", describeEnum(s.Enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return d.validateString(s, v, fail)
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		errs := []FieldError{}
+		for i, item := range v {
+			errs = append(errs, d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case map[string]interface{}:
+		return d.validateObject(s, v, path)
+	}
+	return nil
+}
+
+func (d *Document) validateString(s *Schema, v string, fail func(string, ...interface{}) []FieldError) []FieldError {
+	length := utf8.RuneCountInString(v)
+	if s.MinLength != nil && length < *s.MinLength {
+		if *s.MinLength == 1 {
+			return fail("can't be empty")
+		}
+		return fail("must be at least %d characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return fail("must be at most %d characters", *s.MaxLength)
+	}
+
+	var err error
+	switch s.Format {
+	case "uuid":
+		_, err = uuid.Parse(v)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, v)
+	case "email":
+		_, err = mail.ParseAddress(v)
+	case "uri":
+		var parsed *url.URL
+		parsed, err = url.Parse(v)
+		if err == nil && !parsed.IsAbs() {
+			err = fmt.Errorf("not absolute")
+		}
+	}
+	if err != nil {
+		return fail("must be a valid %s", s.Format)
+	}
+	return nil
+}
+
+func (d *Document) validateObject(s *Schema, v map[string]interface{}, path string) []FieldError {
+	join := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	errs := []FieldError{}
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			errs = append(errs, FieldError{Field: join(name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	additional, hasSchema := s.additional()
+	for _, name := range names {
+		if property, ok := s.Properties[name]; ok {
+			errs = append(errs, d.validate(property, v[name], join(name))...)
+			continue
+		}
+		switch {
+		case hasSchema:
+			errs = append(errs, d.validate(additional, v[name], join(name))...)
+		case s.AdditionalProperties == false:
+			errs = append(errs, FieldError{Field: join(name), Message: "isn't a known field"})
+		}
+	}
+	return errs
+}
+
+func matchesType(types []string, value interface{}) bool {
+	for _, t := range types {
+		switch v := value.(type) {
+		case string:
+			if t == "string" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describe(types []string) string {
+	names := []string{}
+	for _, t := range types {
+		switch t {
+		case "array", "integer", "object":
+			names = append(names, "an "+t)
+		default:
+			names = append(names, "a "+t)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+func describeEnum(values []interface{}) string {
+	parts := []string{}
+	for _, v := range values {
+		data, _ := json.Marshal(v)
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, ", ")
+}
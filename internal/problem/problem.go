@@ -0,0 +1,122 @@
+// Package problem writes error responses as RFC 9457 problem details.
+// Every problem carries a Code that clients can match on; its Detail is
+// for people and may change. Problems with a 5xx status never show their
+// detail, so nothing about a server failure reaches clients.
+package problem
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ContentType is the media type problems are written with.
+const ContentType = "application/problem+json"
+
+// Code identifies a kind of problem. Codes don't change once published.
+type Code string
+
+const (
+	CodeBadRequest        Code = "bad_request"
+	CodeInvalidJSON       Code = "invalid_json"
+	CodeValidationFailed  Code = "validation_failed"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeForbidden         Code = "forbidden"
+	CodeInsufficientScope Code = "insufficient_scope"
+	CodeNotFound          Code = "not_found"
+	CodeConflict          Code = "conflict"
+	CodeUnprocessable     Code = "unprocessable"
+	CodeTooLarge          Code = "too_large"
+	CodeRateLimited       Code = "rate_limited"
+	CodeInternal          Code = "internal_error"
+	CodeUnavailable       Code = "unavailable"
+)
+
+var statusCodes = map[int]Code{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodeTooLarge,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+// CodeFor returns the code for problems that only have a status.
+func CodeFor(status int) Code {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return Code(strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")))
+}
+
+// FieldError is one thing wrong with part of a request. In is "path",
+// "query" or "body"; Field is the parameter name, or the path to a value
+// in the body such as "participant_ids[0]", and is empty when the whole
+// body is wrong.
+type FieldError struct {
+	In      string `json:"in"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 9457 problem details object. Type is always
+// about:blank: Code, an extension member, says what went wrong.
+type Problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   Code         `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// New returns a problem with the code for status.
+func New(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   CodeFor(status),
+	}
+}
+
+// WithCode sets a more specific code than the status gives and returns p.
+func (p *Problem) WithCode(code Code) *Problem {
+	p.Code = code
+	return p
+}
+
+// Invalid returns a 400 validation_failed problem listing errs.
+func Invalid(detail string, errs ...FieldError) *Problem {
+	p := New(http.StatusBadRequest, detail).WithCode(CodeValidationFailed)
+	p.Errors = errs
+	return p
+}
+
+// Write writes p as the response. The detail and errors of 5xx problems
+// are dropped.
+func Write(w http.ResponseWriter, p *Problem) {
+	public := *p
+	if public.Status >= 500 {
+		public.Detail = ""
+		public.Errors = nil
+	}
+	data, err := json.Marshal(public)
+	if err != nil {
+		log.Printf("Error marshalling problem: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(public.Status)
+	w.Write(data)
+}
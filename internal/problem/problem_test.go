@@ -0,0 +1,93 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCodeFor(t *testing.T) {
+	tests := []struct {
+		status int
+		want   Code
+	}{
+		{http.StatusBadRequest, CodeBadRequest},
+		{http.StatusNotFound, CodeNotFound},
+		{http.StatusRequestEntityTooLarge, CodeTooLarge},
+		{http.StatusTooManyRequests, CodeRateLimited},
+		{http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.StatusInternalServerError, CodeInternal},
+		{http.StatusBadGateway, CodeInternal},
+	}
+	for _, tt := range tests {
+		if got := CodeFor(tt.status); got != tt.want {
+			t.Errorf("CodeFor(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func write(t *testing.T, p *Problem) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Write(rec, p)
+	body := map[string]interface{}{}
+	err := json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("body isn't JSON: %v", err)
+	}
+	return rec, body
+}
+
+func TestWrite(t *testing.T) {
+	rec, body := write(t, New(http.StatusNotFound, "Chirp not found"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ContentType)
+	}
+	want := map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Not Found",
+		"status": float64(404),
+		"detail": "Chirp not found",
+		"code":   "not_found",
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	_, body := write(t, Invalid("Chirp is too long", FieldError{In: "body", Field: "body", Message: "Chirp is too long"}))
+	if body["code"] != string(CodeValidationFailed) || body["status"] != float64(400) {
+		t.Errorf("body = %v, want a 400 validation_failed problem", body)
+	}
+	want := []interface{}{map[string]interface{}{"in": "body", "field": "body", "message": "Chirp is too long"}}
+	if !reflect.DeepEqual(body["errors"], want) {
+		t.Errorf("errors = %v, want %v", body["errors"], want)
+	}
+}
+
+func TestWriteHidesServerErrors(t *testing.T) {
+	p := New(http.StatusInternalServerError, `pq: duplicate key value violates unique constraint "users_email_key"`)
+	p.Errors = []FieldError{{In: "body", Field: "email", Message: "secret"}}
+	rec, body := write(t, p)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	want := map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Internal Server Error",
+		"status": float64(500),
+		"code":   "internal_error",
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+	if p.Detail == "" {
+		t.Error("Write() changed the problem it was given")
+	}
+}
@@ -0,0 +1,95 @@
+// Package realtime pushes events to WebSocket clients over topics they
+// subscribe to.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Broker passes messages published to a topic on to its subscribers.
+// LocalBroker does this within one process; a broker backed by a shared
+// message bus can implement the same interface so that clients connected
+// to one instance see events published on another.
+type Broker interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe calls handle with every payload published to topic until
+	// cancel is called. handle must not block.
+	Subscribe(topic string, handle func(payload []byte)) (cancel func())
+}
+
+// Message is the payload published to a topic.
+type Message struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// PublishEvent encodes data as a Message and publishes it to topic.
+func PublishEvent(ctx context.Context, broker Broker, topic, event string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(Message{Event: event, Data: raw})
+	if err != nil {
+		return err
+	}
+	return broker.Publish(ctx, topic, payload)
+}
+
+// AuthorTopic carries the chirps a user posts. A home timeline is the
+// author topics of the user and everyone they follow.
+func AuthorTopic(userID uuid.UUID) string {
+	return "author:" + userID.String()
+}
+
+// NotificationsTopic carries events addressed to a user.
+func NotificationsTopic(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+// RepliesTopic carries replies to a chirp.
+func RepliesTopic(chirpID uuid.UUID) string {
+	return "replies:" + chirpID.String()
+}
+
+// LocalBroker is an in-process Broker.
+type LocalBroker struct {
+	mu     sync.RWMutex
+	topics map[string]map[*func([]byte)]struct{}
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{topics: map[string]map[*func([]byte)]struct{}{}}
+}
+
+func (b *LocalBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for handle := range b.topics[topic] {
+		(*handle)(payload)
+	}
+	return nil
+}
+
+func (b *LocalBroker) Subscribe(topic string, handle func(payload []byte)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.topics[topic] == nil {
+		b.topics[topic] = map[*func([]byte)]struct{}{}
+	}
+	key := &handle
+	b.topics[topic][key] = struct{}{}
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.topics[topic], key)
+		if len(b.topics[topic]) == 0 {
+			delete(b.topics, topic)
+		}
+	}
+}
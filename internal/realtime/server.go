@@ -0,0 +1,306 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrUnknownTopic is returned by a Resolver for topics a client can't
+// subscribe to.
+var ErrUnknownTopic = errors.New("unknown topic")
+
+// maxMessageSize is the largest message a client may send.
+const maxMessageSize = 64 << 10
+
+// upgrader accepts handshakes from any origin. Connections are
+// authenticated with a bearer token or a ticket, never a cookie, so
+// another site can't open one as a signed-in user.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Upgrade switches the request to a WebSocket, answering it with an error
+// if it isn't a valid handshake.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	return upgrader.Upgrade(w, r, nil)
+}
+
+// Resolver maps a topic a client asks for, such as "timeline", to the
+// broker topics that make it up. It's also where access is checked.
+type Resolver func(ctx context.Context, topic string) ([]string, error)
+
+type Options struct {
+	// A ping is sent every PingInterval, and a client we haven't heard
+	// from, pong or otherwise, for PongTimeout is disconnected.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	WriteTimeout time.Duration
+	// SendBuffer messages can be queued for a client. A client that falls
+	// further behind is disconnected rather than slowing publishers down.
+	SendBuffer       int
+	MaxSubscriptions int
+}
+
+func DefaultOptions() Options {
+	return Options{
+		PingInterval:     30 * time.Second,
+		PongTimeout:      75 * time.Second,
+		WriteTimeout:     10 * time.Second,
+		SendBuffer:       64,
+		MaxSubscriptions: 20,
+	}
+}
+
+// command is a message from the client.
+type command struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+}
+
+// frame is a message to the client.
+type frame struct {
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// Server runs WebSocket sessions on top of a Broker.
+type Server struct {
+	broker Broker
+	opts   Options
+
+	mu       sync.Mutex
+	sessions map[*session]struct{}
+	closed   bool
+}
+
+func NewServer(broker Broker, opts Options) *Server {
+	return &Server{
+		broker:   broker,
+		opts:     opts,
+		sessions: map[*session]struct{}{},
+	}
+}
+
+// Serve runs a session on conn until the client leaves, misses its
+// pongs, falls behind, or its credentials expire at expiresAt (if not
+// zero). It closes conn before returning.
+func (s *Server) Serve(ctx context.Context, conn *websocket.Conn, resolve Resolver, expiresAt time.Time) {
+	sess := &session{
+		server:  s,
+		conn:    conn,
+		resolve: resolve,
+		send:    make(chan []byte, s.opts.SendBuffer),
+		done:    make(chan struct{}),
+		subs:    map[string]func(){},
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		closeConn(conn, websocket.CloseGoingAway, "server shutting down", s.opts.WriteTimeout)
+		return
+	}
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+	}()
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		sess.write(ctx, expiresAt)
+	}()
+
+	sess.read(ctx)
+	for _, cancel := range sess.subs {
+		cancel()
+	}
+	<-writerDone
+}
+
+// Close disconnects every client and refuses new ones.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for sess := range s.sessions {
+		sess.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+type session struct {
+	server  *Server
+	conn    *websocket.Conn
+	resolve Resolver
+	send    chan []byte
+
+	closeOnce   sync.Once
+	done        chan struct{}
+	closeCode   int
+	closeReason string
+
+	// subs maps client topics to the cancel func of their broker
+	// subscriptions. Only the reading goroutine touches it.
+	subs map[string]func()
+}
+
+// closeConn sends a close frame and hangs up.
+func closeConn(conn *websocket.Conn, code int, reason string, timeout time.Duration) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(timeout))
+	conn.Close()
+}
+
+// close asks the writer to send a close frame and hang up. The first
+// reason given wins.
+func (s *session) close(code int, reason string) {
+	s.closeOnce.Do(func() {
+		s.closeCode = code
+		s.closeReason = reason
+		close(s.done)
+	})
+}
+
+// enqueue queues a frame without blocking; it's called from publishers.
+func (s *session) enqueue(f frame) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		log.Printf("Couldn't encode realtime frame: %s", err)
+		return
+	}
+	select {
+	case s.send <- data:
+	default:
+		s.close(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+func (s *session) write(ctx context.Context, expiresAt time.Time) {
+	opts := s.server.opts
+	ping := time.NewTicker(opts.PingInterval)
+	defer ping.Stop()
+	var expired <-chan time.Time
+	if !expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		var err error
+		select {
+		case data := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			err = s.conn.WriteMessage(websocket.TextMessage, data)
+		case <-ping.C:
+			err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(opts.WriteTimeout))
+		case <-expired:
+			s.close(websocket.ClosePolicyViolation, "token expired")
+		case <-ctx.Done():
+			s.close(websocket.CloseGoingAway, "")
+		case <-s.done:
+			closeConn(s.conn, s.closeCode, s.closeReason, opts.WriteTimeout)
+			return
+		}
+		if err != nil {
+			s.close(websocket.CloseGoingAway, "write failed")
+		}
+	}
+}
+
+func (s *session) read(ctx context.Context) {
+	timeout := s.server.opts.PongTimeout
+	s.conn.SetReadLimit(maxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(timeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			s.close(websocket.CloseGoingAway, "")
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(timeout))
+		if messageType != websocket.TextMessage {
+			s.close(websocket.CloseUnsupportedData, "expected JSON text messages")
+			return
+		}
+
+		cmd := command{}
+		err = json.Unmarshal(data, &cmd)
+		if err != nil {
+			s.enqueue(frame{Type: "error", Message: "couldn't decode message"})
+			continue
+		}
+		switch cmd.Type {
+		case "subscribe":
+			s.subscribe(ctx, cmd.Topic)
+		case "unsubscribe":
+			if cancel, ok := s.subs[cmd.Topic]; ok {
+				cancel()
+				delete(s.subs, cmd.Topic)
+			}
+			s.enqueue(frame{Type: "unsubscribed", Topic: cmd.Topic})
+		case "ping":
+			s.enqueue(frame{Type: "pong"})
+		default:
+			s.enqueue(frame{Type: "error", Message: "unknown message type"})
+		}
+	}
+}
+
+func (s *session) subscribe(ctx context.Context, topic string) {
+	if _, ok := s.subs[topic]; ok {
+		s.enqueue(frame{Type: "subscribed", Topic: topic})
+		return
+	}
+	if len(s.subs) >= s.server.opts.MaxSubscriptions {
+		s.enqueue(frame{Type: "error", Topic: topic, Message: "too many subscriptions"})
+		return
+	}
+
+	brokerTopics, err := s.resolve(ctx, topic)
+	if errors.Is(err, ErrUnknownTopic) {
+		s.enqueue(frame{Type: "error", Topic: topic, Message: "unknown topic"})
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't resolve realtime topic %q: %s", topic, err)
+		s.enqueue(frame{Type: "error", Topic: topic, Message: "couldn't subscribe"})
+		return
+	}
+
+	deliver := func(payload []byte) {
+		message := Message{}
+		err := json.Unmarshal(payload, &message)
+		if err != nil {
+			log.Printf("Couldn't decode realtime message: %s", err)
+			return
+		}
+		s.enqueue(frame{Type: "event", Topic: topic, Event: message.Event, Data: message.Data})
+	}
+	cancels := make([]func(), 0, len(brokerTopics))
+	for _, brokerTopic := range brokerTopics {
+		cancels = append(cancels, s.server.broker.Subscribe(brokerTopic, deliver))
+	}
+	s.subs[topic] = func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+	s.enqueue(frame{Type: "subscribed", Topic: topic})
+}
@@ -0,0 +1,215 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func testResolver(ctx context.Context, topic string) ([]string, error) {
+	switch topic {
+	case "timeline":
+		return []string{"author:alice", "author:bob"}, nil
+	case "notifications":
+		return []string{"notifications:alice"}, nil
+	}
+	return nil, ErrUnknownTopic
+}
+
+func startServer(t *testing.T, opts Options, expiresAt time.Time) (*Server, *LocalBroker, string) {
+	t.Helper()
+	broker := NewLocalBroker()
+	srv := NewServer(broker, opts)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		srv.Serve(r.Context(), conn, testResolver, expiresAt)
+	}))
+	t.Cleanup(httpServer.Close)
+	return srv, broker, "ws://" + strings.TrimPrefix(httpServer.URL, "http://") + "/"
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { closeConn(conn, websocket.CloseNormalClosure, "", time.Second) })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, cmd command) {
+	t.Helper()
+	data, _ := json.Marshal(cmd)
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	err := conn.WriteMessage(websocket.TextMessage, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, conn *websocket.Conn) frame {
+	t.Helper()
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := frame{}
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func expectClose(t *testing.T, conn *websocket.Conn, code int) {
+	t.Helper()
+	for {
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			if closeErr.Code != code {
+				t.Errorf("close code = %d, want %d", closeErr.Code, code)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("ReadMessage() error = %v, want a close frame", err)
+		}
+	}
+}
+
+func TestSubscribeAndReceive(t *testing.T) {
+	_, broker, url := startServer(t, DefaultOptions(), time.Time{})
+	conn := dial(t, url)
+
+	send(t, conn, command{Type: "subscribe", Topic: "timeline"})
+	if f := receive(t, conn); f.Type != "subscribed" || f.Topic != "timeline" {
+		t.Fatalf("got %+v, want subscribed", f)
+	}
+
+	PublishEvent(context.Background(), broker, "author:carol", "chirp.created", map[string]string{"body": "not followed"})
+	PublishEvent(context.Background(), broker, "author:bob", "chirp.created", map[string]string{"body": "hi"})
+	f := receive(t, conn)
+	if f.Type != "event" || f.Topic != "timeline" || f.Event != "chirp.created" || string(f.Data) != `{"body":"hi"}` {
+		t.Errorf("got %+v, want bob's chirp on the timeline", f)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	_, broker, url := startServer(t, DefaultOptions(), time.Time{})
+	conn := dial(t, url)
+
+	send(t, conn, command{Type: "subscribe", Topic: "timeline"})
+	receive(t, conn)
+	send(t, conn, command{Type: "unsubscribe", Topic: "timeline"})
+	if f := receive(t, conn); f.Type != "unsubscribed" {
+		t.Fatalf("got %+v, want unsubscribed", f)
+	}
+
+	PublishEvent(context.Background(), broker, "author:bob", "chirp.created", nil)
+	send(t, conn, command{Type: "ping"})
+	if f := receive(t, conn); f.Type != "pong" {
+		t.Errorf("got %+v, want only the pong", f)
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxSubscriptions = 1
+	_, _, url := startServer(t, opts, time.Time{})
+	conn := dial(t, url)
+
+	send(t, conn, command{Type: "subscribe", Topic: "replies:nope"})
+	if f := receive(t, conn); f.Type != "error" || f.Message != "unknown topic" {
+		t.Errorf("got %+v, want unknown topic", f)
+	}
+	send(t, conn, command{Type: "subscribe", Topic: "timeline"})
+	receive(t, conn)
+	send(t, conn, command{Type: "subscribe", Topic: "notifications"})
+	if f := receive(t, conn); f.Type != "error" || f.Message != "too many subscriptions" {
+		t.Errorf("got %+v, want too many subscriptions", f)
+	}
+	send(t, conn, command{Type: "dance"})
+	if f := receive(t, conn); f.Type != "error" {
+		t.Errorf("got %+v, want an error", f)
+	}
+}
+
+func TestClientThatStopsAnsweringIsDisconnected(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PingInterval = 10 * time.Millisecond
+	opts.PongTimeout = 50 * time.Millisecond
+	_, _, url := startServer(t, opts, time.Time{})
+	conn := dial(t, url)
+	// The pings queued up while the client wasn't reading mustn't be
+	// answered when it finally reads the close frame behind them.
+	conn.SetPingHandler(func(string) error { return nil })
+
+	// Not reading means not answering pings.
+	time.Sleep(150 * time.Millisecond)
+	expectClose(t, conn, websocket.CloseGoingAway)
+}
+
+func TestExpiredTokenIsDisconnected(t *testing.T) {
+	_, _, url := startServer(t, DefaultOptions(), time.Now().Add(50*time.Millisecond))
+	conn := dial(t, url)
+	expectClose(t, conn, websocket.ClosePolicyViolation)
+}
+
+func TestCloseDisconnectsClients(t *testing.T) {
+	srv, _, url := startServer(t, DefaultOptions(), time.Time{})
+	conn := dial(t, url)
+	send(t, conn, command{Type: "ping"})
+	receive(t, conn)
+
+	srv.Close()
+	expectClose(t, conn, websocket.CloseGoingAway)
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	sess := &session{
+		send: make(chan []byte, 2),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < 3; i++ {
+		sess.enqueue(frame{Type: "event"})
+	}
+
+	select {
+	case <-sess.done:
+	default:
+		t.Fatal("expected the session to be closing")
+	}
+	if sess.closeCode != websocket.CloseTryAgainLater {
+		t.Errorf("close code = %d, want %d", sess.closeCode, websocket.CloseTryAgainLater)
+	}
+}
+
+func TestLocalBroker(t *testing.T) {
+	broker := NewLocalBroker()
+	got := 0
+	cancel := broker.Subscribe("a", func([]byte) { got++ })
+	broker.Publish(context.Background(), "a", nil)
+	broker.Publish(context.Background(), "b", nil)
+	cancel()
+	broker.Publish(context.Background(), "a", nil)
+
+	if got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+	if len(broker.topics) != 0 {
+		t.Errorf("topics = %v, want none left", broker.topics)
+	}
+}
@@ -0,0 +1,173 @@
+// Package stream fans chirp events out to live subscribers, such as
+// Server-Sent Events connections, and keeps recent events so a client
+// that reconnects can catch up.
+package stream
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrClosed is returned when subscribing to a hub that has shut down.
+var ErrClosed = errors.New("stream: hub is closed")
+
+// Event is one published event. IDs increase by one per event.
+type Event struct {
+	ID       uint64
+	Type     string
+	Data     []byte
+	AuthorID uuid.UUID
+	Hashtags []string
+}
+
+// Filter selects the events a subscriber gets. A nil Authors or Hashtags
+// matches everything; an empty, non-nil one matches nothing.
+type Filter struct {
+	Authors  map[uuid.UUID]bool
+	Hashtags map[string]bool
+}
+
+// Match reports whether e passes the filter: it must be by one of the
+// authors and carry at least one of the hashtags.
+func (f Filter) Match(e Event) bool {
+	if f.Authors != nil && !f.Authors[e.AuthorID] {
+		return false
+	}
+	if f.Hashtags != nil && !slices.ContainsFunc(e.Hashtags, func(tag string) bool { return f.Hashtags[tag] }) {
+		return false
+	}
+	return true
+}
+
+// Subscription receives matching events on C. C is closed when the
+// subscriber falls too far behind or the hub closes; the client should
+// reconnect and resume from the last ID it saw.
+type Subscription struct {
+	C      <-chan Event
+	events chan Event
+	filter Filter
+}
+
+// Hub delivers published events to subscribers without ever waiting on
+// them, and remembers the last bufferSize events for replay.
+type Hub struct {
+	mu               sync.Mutex
+	nextID           uint64
+	floor            uint64
+	buffer           []Event
+	bufferSize       int
+	subscriberBuffer int
+	subscribers      map[*Subscription]struct{}
+	closed           bool
+}
+
+// NewHub returns a hub that keeps bufferSize events for replay and lets
+// each subscriber fall subscriberBuffer events behind before dropping it.
+//
+// IDs start from the clock rather than at 1, so IDs from before a restart
+// are lower than any the new hub issues and resuming from one is reported
+// as a gap instead of silently replaying the wrong events.
+func NewHub(bufferSize, subscriberBuffer int) *Hub {
+	start := uint64(time.Now().UnixMicro())
+	return &Hub{
+		nextID:           start,
+		floor:            start - 1,
+		bufferSize:       bufferSize,
+		subscriberBuffer: subscriberBuffer,
+		subscribers:      map[*Subscription]struct{}{},
+	}
+}
+
+// Publish assigns the event an ID and sends it to every matching
+// subscriber. It never blocks: a subscriber whose channel is full is
+// dropped.
+func (h *Hub) Publish(e Event) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return e
+	}
+
+	e.ID = h.nextID
+	h.nextID++
+
+	if h.bufferSize > 0 {
+		if len(h.buffer) == h.bufferSize {
+			h.floor = h.buffer[0].ID
+			h.buffer = slices.Delete(h.buffer, 0, 1)
+		}
+		h.buffer = append(h.buffer, e)
+	} else {
+		h.floor = e.ID
+	}
+
+	for sub := range h.subscribers {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			h.drop(sub)
+		}
+	}
+	return e
+}
+
+// Subscribe starts a subscription. If lastEventID isn't 0, the buffered
+// matching events after it are returned for the caller to send first;
+// complete is false when events after lastEventID may already have been
+// dropped from the buffer (or were never in it), so the client should
+// refetch instead of trusting the replay.
+func (h *Hub) Subscribe(lastEventID uint64, filter Filter) (sub *Subscription, replay []Event, complete bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, nil, false, ErrClosed
+	}
+
+	events := make(chan Event, h.subscriberBuffer)
+	sub = &Subscription{C: events, events: events, filter: filter}
+	h.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, true, nil
+	}
+	for _, e := range h.buffer {
+		if e.ID > lastEventID && filter.Match(e) {
+			replay = append(replay, e)
+		}
+	}
+	complete = lastEventID >= h.floor && lastEventID < h.nextID
+	return sub, replay, complete, nil
+}
+
+// Unsubscribe ends sub. It's safe to call more than once.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+// Close ends every subscription and stops accepting new ones, so that
+// streaming handlers return and the server can shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		h.drop(sub)
+	}
+}
+
+func (h *Hub) drop(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
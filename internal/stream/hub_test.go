@@ -0,0 +1,170 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func publishN(h *Hub, n int, author uuid.UUID) []Event {
+	var events []Event
+	for i := 0; i < n; i++ {
+		events = append(events, h.Publish(Event{Type: "chirp.created", AuthorID: author}))
+	}
+	return events
+}
+
+func TestPublishDeliversMatchingEvents(t *testing.T) {
+	h := NewHub(10, 10)
+	alice, bob := uuid.New(), uuid.New()
+
+	sub, _, _, err := h.Subscribe(0, Filter{Authors: map[uuid.UUID]bool{alice: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Publish(Event{Type: "chirp.created", AuthorID: bob})
+	want := h.Publish(Event{Type: "chirp.created", AuthorID: alice})
+
+	select {
+	case got := <-sub.C:
+		if got.ID != want.ID {
+			t.Errorf("got event %d, want %d", got.ID, want.ID)
+		}
+	default:
+		t.Fatal("expected an event")
+	}
+	select {
+	case got := <-sub.C:
+		t.Errorf("unexpected event %+v", got)
+	default:
+	}
+}
+
+func TestPublishAssignsIncreasingIDs(t *testing.T) {
+	h := NewHub(10, 10)
+	events := publishN(h, 3, uuid.New())
+	for i := 1; i < len(events); i++ {
+		if events[i].ID != events[i-1].ID+1 {
+			t.Errorf("IDs %d then %d, want consecutive", events[i-1].ID, events[i].ID)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	alice := uuid.New()
+	event := Event{AuthorID: alice, Hashtags: []string{"go", "chirpy"}}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "empty filter", filter: Filter{}, want: true},
+		{name: "author", filter: Filter{Authors: map[uuid.UUID]bool{alice: true}}, want: true},
+		{name: "other author", filter: Filter{Authors: map[uuid.UUID]bool{uuid.New(): true}}, want: false},
+		{name: "no authors", filter: Filter{Authors: map[uuid.UUID]bool{}}, want: false},
+		{name: "hashtag", filter: Filter{Hashtags: map[string]bool{"go": true, "rust": true}}, want: true},
+		{name: "other hashtag", filter: Filter{Hashtags: map[string]bool{"rust": true}}, want: false},
+		{
+			name:   "author and hashtag",
+			filter: Filter{Authors: map[uuid.UUID]bool{alice: true}, Hashtags: map[string]bool{"rust": true}},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(event); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeReplaysAfterLastEventID(t *testing.T) {
+	h := NewHub(10, 10)
+	events := publishN(h, 5, uuid.New())
+
+	_, replay, complete, err := h.Subscribe(events[1].ID, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !complete {
+		t.Error("expected a complete replay")
+	}
+	if len(replay) != 3 || replay[0].ID != events[2].ID || replay[2].ID != events[4].ID {
+		t.Errorf("replay = %+v, want events 2 to 4", replay)
+	}
+}
+
+func TestSubscribeReportsGap(t *testing.T) {
+	h := NewHub(3, 10)
+	events := publishN(h, 6, uuid.New())
+
+	tests := []struct {
+		name         string
+		lastEventID  uint64
+		wantReplay   int
+		wantComplete bool
+	}{
+		{name: "still buffered", lastEventID: events[2].ID, wantReplay: 3, wantComplete: true},
+		{name: "evicted", lastEventID: events[1].ID, wantReplay: 3, wantComplete: false},
+		{name: "before this hub", lastEventID: events[0].ID - 100, wantReplay: 3, wantComplete: false},
+		{name: "never issued", lastEventID: events[5].ID + 100, wantReplay: 0, wantComplete: false},
+		{name: "up to date", lastEventID: events[5].ID, wantReplay: 0, wantComplete: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, replay, complete, err := h.Subscribe(tt.lastEventID, Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(replay) != tt.wantReplay {
+				t.Errorf("replayed %d events, want %d", len(replay), tt.wantReplay)
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	h := NewHub(10, 2)
+	slow, _, _, _ := h.Subscribe(0, Filter{})
+	fast, _, _, _ := h.Subscribe(0, Filter{})
+
+	for i := 0; i < 3; i++ {
+		h.Publish(Event{Type: "chirp.created"})
+		<-fast.C
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("slow subscriber got %d events before being dropped, want 2", received)
+	}
+
+	h.Publish(Event{Type: "chirp.created"})
+	if _, ok := <-fast.C; !ok {
+		t.Error("fast subscriber was dropped")
+	}
+}
+
+func TestClose(t *testing.T) {
+	h := NewHub(10, 10)
+	sub, _, _, _ := h.Subscribe(0, Filter{})
+
+	h.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the subscription to be closed")
+	}
+	h.Unsubscribe(sub)
+	h.Publish(Event{Type: "chirp.created"})
+
+	_, _, _, err := h.Subscribe(0, Filter{})
+	if err != ErrClosed {
+		t.Errorf("Subscribe() after Close error = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package subscription tracks the Chirpy Red subscription lifecycle
+// driven by Polka's billing events.
+package subscription
+
+import (
+	"errors"
+	"time"
+)
+
+// Status is where a subscription is in its lifecycle.
+type Status string
+
+const (
+	// StatusActive subscriptions are paid up to CurrentPeriodEnd.
+	StatusActive Status = "active"
+	// StatusPastDue subscriptions failed to renew. They stay active for the
+	// grace period after CurrentPeriodEnd while Polka retries the payment.
+	StatusPastDue Status = "past_due"
+	// StatusCanceled subscriptions won't renew but stay active until
+	// CurrentPeriodEnd.
+	StatusCanceled Status = "canceled"
+	// StatusExpired subscriptions have ended.
+	StatusExpired Status = "expired"
+)
+
+// Polka events that change a subscription.
+const (
+	EventUpgraded   = "user.upgraded"
+	EventDowngraded = "user.downgraded"
+	EventRenewed    = "subscription.renewed"
+	EventCanceled   = "subscription.canceled"
+	EventPayment    = "payment.failed"
+)
+
+const (
+	// DefaultPlan is used when an event doesn't name a plan.
+	DefaultPlan = "red"
+	// DefaultPeriod is the billing period assumed when an event doesn't
+	// say when the new period ends.
+	DefaultPeriod = 30 * 24 * time.Hour
+	// DefaultGracePeriod is how long a past-due subscription stays active.
+	DefaultGracePeriod = 3 * 24 * time.Hour
+)
+
+var (
+	ErrUnknownEvent   = errors.New("unknown subscription event")
+	ErrNoSubscription = errors.New("user has no subscription")
+)
+
+type Subscription struct {
+	Plan   string
+	Status Status
+	// CurrentPeriodEnd is zero for memberships from before billing, which
+	// don't end until Polka starts billing them.
+	CurrentPeriodEnd time.Time
+	CanceledAt       time.Time // zero unless canceled
+}
+
+// Event is a billing event for one user's subscription. Plan and
+// PeriodEnd are optional.
+type Event struct {
+	Type      string
+	Plan      string
+	PeriodEnd time.Time
+}
+
+// Handles reports whether eventType changes subscriptions.
+func Handles(eventType string) bool {
+	switch eventType {
+	case EventUpgraded, EventDowngraded, EventRenewed, EventCanceled, EventPayment:
+		return true
+	}
+	return false
+}
+
+// Apply returns the subscription after event. current is nil when the
+// user has never subscribed; only an upgrade can start a subscription.
+func Apply(current *Subscription, event Event, now time.Time) (Subscription, error) {
+	if !Handles(event.Type) {
+		return Subscription{}, ErrUnknownEvent
+	}
+	if current == nil {
+		if event.Type != EventUpgraded {
+			return Subscription{}, ErrNoSubscription
+		}
+		current = &Subscription{Plan: DefaultPlan}
+	}
+
+	next := *current
+	if event.Plan != "" {
+		next.Plan = event.Plan
+	}
+	periodEnd := event.PeriodEnd
+	if periodEnd.IsZero() {
+		periodEnd = now.Add(DefaultPeriod)
+	}
+
+	switch event.Type {
+	case EventUpgraded, EventRenewed:
+		next.Status = StatusActive
+		next.CurrentPeriodEnd = periodEnd
+		next.CanceledAt = time.Time{}
+	case EventCanceled:
+		next.Status = StatusCanceled
+		next.CanceledAt = now
+		// There's no paid period left to run out.
+		if next.CurrentPeriodEnd.IsZero() {
+			next.CurrentPeriodEnd = now
+		}
+	case EventPayment:
+		// A failed payment doesn't undo a cancellation; the subscription
+		// still ends at the end of the period.
+		if next.Status == StatusActive {
+			next.Status = StatusPastDue
+		}
+	case EventDowngraded:
+		next.Status = StatusExpired
+		next.CurrentPeriodEnd = now
+		if next.CanceledAt.IsZero() {
+			next.CanceledAt = now
+		}
+	}
+	return next, nil
+}
+
+// Active reports whether the subscription grants Chirpy Red at now.
+// Active and past-due subscriptions get the grace period after their
+// period ends, since renewals can arrive late.
+func (s Subscription) Active(now time.Time, grace time.Duration) bool {
+	if s.CurrentPeriodEnd.IsZero() {
+		return s.Status == StatusActive || s.Status == StatusPastDue
+	}
+	switch s.Status {
+	case StatusActive, StatusPastDue:
+		return now.Before(s.CurrentPeriodEnd.Add(grace))
+	case StatusCanceled:
+		return now.Before(s.CurrentPeriodEnd)
+	}
+	return false
+}
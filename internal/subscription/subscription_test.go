@@ -0,0 +1,133 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(DefaultPeriod)
+	grace := DefaultGracePeriod
+
+	sub, err := Apply(nil, Event{Type: EventUpgraded}, now)
+	if err != nil {
+		t.Fatalf("Apply(upgraded) error = %v", err)
+	}
+	if sub.Status != StatusActive || sub.Plan != DefaultPlan || !sub.CurrentPeriodEnd.Equal(periodEnd) {
+		t.Fatalf("Unexpected subscription after upgrade: %+v", sub)
+	}
+	if !sub.Active(now, grace) {
+		t.Fatal("New subscription isn't active")
+	}
+
+	sub, err = Apply(&sub, Event{Type: EventPayment}, periodEnd)
+	if err != nil {
+		t.Fatalf("Apply(payment.failed) error = %v", err)
+	}
+	if sub.Status != StatusPastDue {
+		t.Fatalf("Status = %s, want %s", sub.Status, StatusPastDue)
+	}
+	if !sub.Active(periodEnd.Add(grace-time.Second), grace) {
+		t.Fatal("Past-due subscription inactive within grace period")
+	}
+	if sub.Active(periodEnd.Add(grace), grace) {
+		t.Fatal("Past-due subscription active after grace period")
+	}
+
+	renewedEnd := periodEnd.Add(DefaultPeriod)
+	sub, err = Apply(&sub, Event{Type: EventRenewed, PeriodEnd: renewedEnd}, periodEnd.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Apply(renewed) error = %v", err)
+	}
+	if sub.Status != StatusActive || !sub.CurrentPeriodEnd.Equal(renewedEnd) {
+		t.Fatalf("Unexpected subscription after renewal: %+v", sub)
+	}
+
+	canceledAt := periodEnd.Add(2 * time.Hour)
+	sub, err = Apply(&sub, Event{Type: EventCanceled}, canceledAt)
+	if err != nil {
+		t.Fatalf("Apply(canceled) error = %v", err)
+	}
+	if sub.Status != StatusCanceled || !sub.CanceledAt.Equal(canceledAt) {
+		t.Fatalf("Unexpected subscription after cancel: %+v", sub)
+	}
+	if !sub.Active(renewedEnd.Add(-time.Second), grace) {
+		t.Fatal("Canceled subscription inactive before period end")
+	}
+	if sub.Active(renewedEnd, grace) {
+		t.Fatal("Canceled subscription gets a grace period")
+	}
+
+	sub, err = Apply(&sub, Event{Type: EventPayment}, canceledAt)
+	if err != nil {
+		t.Fatalf("Apply(payment.failed) error = %v", err)
+	}
+	if sub.Status != StatusCanceled {
+		t.Fatalf("Failed payment changed a canceled subscription to %s", sub.Status)
+	}
+}
+
+func TestDowngradeEndsImmediately(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sub, err := Apply(nil, Event{Type: EventUpgraded, Plan: "red-annual"}, now)
+	if err != nil {
+		t.Fatalf("Apply(upgraded) error = %v", err)
+	}
+	if sub.Plan != "red-annual" {
+		t.Fatalf("Plan = %q, want red-annual", sub.Plan)
+	}
+
+	later := now.Add(time.Hour)
+	sub, err = Apply(&sub, Event{Type: EventDowngraded}, later)
+	if err != nil {
+		t.Fatalf("Apply(downgraded) error = %v", err)
+	}
+	if sub.Status != StatusExpired || sub.Active(later, DefaultGracePeriod) {
+		t.Fatalf("Downgraded subscription still active: %+v", sub)
+	}
+	if !sub.CanceledAt.Equal(later) {
+		t.Fatalf("CanceledAt = %v, want %v", sub.CanceledAt, later)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	now := time.Now()
+	for _, eventType := range []string{EventDowngraded, EventRenewed, EventCanceled, EventPayment} {
+		_, err := Apply(nil, Event{Type: eventType}, now)
+		if err != ErrNoSubscription {
+			t.Errorf("Apply(nil, %s) error = %v, want ErrNoSubscription", eventType, err)
+		}
+	}
+	_, err := Apply(nil, Event{Type: "user.exploded"}, now)
+	if err != ErrUnknownEvent {
+		t.Errorf("Apply(unknown) error = %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestMembershipWithoutEndDate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	grace := DefaultGracePeriod
+
+	// What the migration to subscriptions gives existing members.
+	sub := Subscription{Plan: DefaultPlan, Status: StatusActive}
+	if !sub.Active(now.Add(10*365*24*time.Hour), grace) {
+		t.Fatal("Membership without an end date expired")
+	}
+
+	renewed, err := Apply(&sub, Event{Type: EventRenewed}, now)
+	if err != nil {
+		t.Fatalf("Apply(renewed) error = %v", err)
+	}
+	if !renewed.CurrentPeriodEnd.Equal(now.Add(DefaultPeriod)) {
+		t.Fatalf("CurrentPeriodEnd = %s, want a billing period from now", renewed.CurrentPeriodEnd)
+	}
+
+	canceled, err := Apply(&sub, Event{Type: EventCanceled}, now)
+	if err != nil {
+		t.Fatalf("Apply(canceled) error = %v", err)
+	}
+	if canceled.Active(now, grace) {
+		t.Fatal("Canceled membership without an end date is still active")
+	}
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+)
+
+// DBStore keeps the queue in the webhook_deliveries table, so deliveries
+// survive restarts and every instance can help send them.
+type DBStore struct {
+	db *database.Queries
+}
+
+func NewDBStore(db *database.Queries) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	rows, err := s.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil:    leaseUntil.UTC(),
+		Now:           now.UTC(),
+		MaxDeliveries: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []Delivery{}
+	for _, row := range rows {
+		deliveries = append(deliveries, Delivery{
+			Queued: Queued{
+				ID:             row.ID,
+				Status:         Status(row.Status),
+				Attempts:       int(row.Attempts),
+				NextAttemptAt:  row.NextAttemptAt,
+				LastAttemptAt:  row.LastAttemptAt.Time,
+				ResponseStatus: int(row.ResponseStatus),
+				LastError:      row.LastError,
+			},
+			EndpointID: row.EndpointID,
+			URL:        row.Url,
+			Secret:     row.Secret,
+			EventID:    row.EventID,
+			Event:      row.Event,
+			Payload:    []byte(row.Payload),
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *DBStore) Update(ctx context.Context, delivery Delivery) error {
+	return s.db.UpdateWebhookDelivery(ctx, database.UpdateWebhookDeliveryParams{
+		ID:             delivery.ID,
+		Status:         string(delivery.Status),
+		Attempts:       int32(delivery.Attempts),
+		NextAttemptAt:  delivery.NextAttemptAt.UTC(),
+		LastAttemptAt:  sql.NullTime{Time: delivery.LastAttemptAt.UTC(), Valid: !delivery.LastAttemptAt.IsZero()},
+		ResponseStatus: int32(delivery.ResponseStatus),
+		LastError:      delivery.LastError,
+	})
+}
@@ -0,0 +1,318 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Events developers can subscribe their endpoints to.
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserFollowed = "user.followed"
+	EventChirpLiked   = "chirp.liked"
+)
+
+// Events lists every event an endpoint may subscribe to.
+var Events = []string{EventChirpCreated, EventChirpDeleted, EventUserFollowed, EventChirpLiked}
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+)
+
+// Envelope is the JSON body of a delivery. ID identifies the event, so it
+// stays the same across retries and replays and receivers can use it to
+// ignore duplicates.
+type Envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewEnvelope wraps data in a new event of type eventType.
+func NewEnvelope(eventType string, data interface{}, now time.Time) (Envelope, []byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Envelope{}, nil, err
+	}
+	envelope := Envelope{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: now.UTC(),
+		Data:      raw,
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return Envelope{}, nil, err
+	}
+	return envelope, payload, nil
+}
+
+// Status is where a delivery is in the queue.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Queued is the bookkeeping every queued delivery carries. Delivery types
+// embed it so that a Dispatcher and a MemoryStore can handle them.
+type Queued struct {
+	ID             uuid.UUID
+	Status         Status
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  time.Time
+	ResponseStatus int
+	LastError      string
+}
+
+func (q *Queued) queued() *Queued {
+	return q
+}
+
+// Queueable is a pointer to a delivery type that embeds Queued.
+type Queueable[D any] interface {
+	*D
+	queued() *Queued
+}
+
+// Delivery is one event queued for one endpoint.
+type Delivery struct {
+	Queued
+	EndpointID uuid.UUID
+	URL        string
+	Secret     string
+	EventID    uuid.UUID
+	Event      string
+	Payload    []byte
+}
+
+// Store is a persistent delivery queue.
+type Store[D any] interface {
+	// Claim returns up to limit pending deliveries due at now, and hides
+	// them from other claims until leaseUntil so that several dispatchers
+	// can share a queue.
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]D, error)
+	// Update saves the outcome of an attempt.
+	Update(ctx context.Context, delivery D) error
+}
+
+// SendFunc makes one attempt at a delivery and returns the status the
+// receiver responded with, if any. ctx carries the attempt's timeout.
+type SendFunc[D any] func(ctx context.Context, delivery D, now time.Time) (int, error)
+
+// RetryPolicy decides when to retry a failed delivery.
+type RetryPolicy struct {
+	// MaxAttempts failed attempts mark the delivery failed for good.
+	MaxAttempts int
+	// The n-th failure waits BaseDelay * 2^(n-1), capped at MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    6 * time.Hour,
+	}
+}
+
+// Backoff returns how long to wait after the given number of failed
+// attempts.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// Dispatcher sends queued deliveries with a SendFunc, retrying failures
+// according to its RetryPolicy.
+type Dispatcher[D any, P Queueable[D]] struct {
+	name      string
+	store     Store[D]
+	send      SendFunc[D]
+	policy    RetryPolicy
+	batchSize int
+	timeout   time.Duration
+
+	// Now returns the current time.
+	Now func() time.Time
+}
+
+// NewDispatcher returns a dispatcher for webhook deliveries in store,
+// signing each with its endpoint's secret and sending with NewClient.
+func NewDispatcher(store Store[Delivery], policy RetryPolicy, allowPrivateNetworks bool) *Dispatcher[Delivery, *Delivery] {
+	return NewDispatcherFunc("webhook", store, policy, sendWebhook(NewClient(allowPrivateNetworks)))
+}
+
+// NewDispatcherFunc returns a dispatcher for store that sends with send.
+// name describes the deliveries in logs.
+func NewDispatcherFunc[D any, P Queueable[D]](name string, store Store[D], policy RetryPolicy, send SendFunc[D]) *Dispatcher[D, P] {
+	return &Dispatcher[D, P]{
+		name:      name,
+		store:     store,
+		send:      send,
+		policy:    policy,
+		batchSize: 20,
+		timeout:   10 * time.Second,
+		Now:       time.Now,
+	}
+}
+
+// NewClient returns a client for calling URLs that users control. Unless
+// allowPrivateNetworks is set, it refuses to connect to loopback, private
+// and link-local addresses, so they can't be pointed at Chirpy's own
+// network.
+func NewClient(allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivateNetworks {
+		dialer.Control = refusePrivateAddresses
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{
+		Transport: transport,
+		// A redirect could lead anywhere, including past the address
+		// check above. Treat it as a failure instead.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run sends due deliveries every interval until ctx is done.
+func (d *Dispatcher[D, P]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := d.RunOnce(ctx)
+		if err != nil {
+			log.Printf("Error sending %s deliveries: %s", d.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends one batch of due deliveries and returns how many it
+// attempted. A delivery whose result can't be stored is logged and left
+// for its lease to expire, so the rest of the batch still goes out.
+func (d *Dispatcher[D, P]) RunOnce(ctx context.Context) (int, error) {
+	now := d.Now()
+	// The lease outlasts every attempt in the batch, so a delivery is only
+	// picked up again if this dispatcher dies mid-batch.
+	lease := now.Add(time.Duration(d.batchSize+1) * d.timeout)
+	deliveries, err := d.store.Claim(ctx, now, lease, d.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		d.attempt(ctx, &delivery)
+		err := d.store.Update(ctx, delivery)
+		if err != nil {
+			log.Printf("Couldn't store %s delivery %s: %s", d.name, P(&delivery).queued().ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher[D, P]) attempt(ctx context.Context, delivery *D) {
+	now := d.Now()
+	queued := P(delivery).queued()
+	queued.Attempts++
+	queued.LastAttemptAt = now
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	status, err := d.send(ctx, *delivery, now)
+	queued.ResponseStatus = status
+	if err == nil {
+		queued.Status = StatusSucceeded
+		queued.LastError = ""
+		return
+	}
+
+	queued.LastError = err.Error()
+	if queued.Attempts >= d.policy.MaxAttempts {
+		queued.Status = StatusFailed
+		return
+	}
+	queued.Status = StatusPending
+	queued.NextAttemptAt = now.Add(d.policy.Backoff(queued.Attempts))
+}
+
+// sendWebhook returns a SendFunc that posts deliveries with client,
+// signed with their endpoint's secret.
+func sendWebhook(client *http.Client) SendFunc[Delivery] {
+	return func(ctx context.Context, delivery Delivery, now time.Time) (int, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+		req.Header.Set(SignatureHeader, Sign(delivery.Secret, now, delivery.Payload))
+		req.Header.Set(EventHeader, delivery.Event)
+		req.Header.Set(DeliveryHeader, delivery.ID.String())
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+		}
+		return resp.StatusCode, nil
+	}
+}
+
+var errPrivateAddress = errors.New("refusing to deliver to a private address")
+
+func refusePrivateAddresses(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
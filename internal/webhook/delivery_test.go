@@ -0,0 +1,282 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// receiver is an httptest endpoint that verifies signatures and answers
+// with the queued status codes, then 200.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	received []Envelope
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Errorf("Failed to read body: %v", err)
+	}
+	err = Verify(rc.secret, r.Header.Get(SignatureHeader), body, time.Now(), DefaultTolerance)
+	if err != nil {
+		rc.t.Errorf("Delivery signature didn't verify: %v", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	envelope := Envelope{}
+	err = json.Unmarshal(body, &envelope)
+	if err != nil {
+		rc.t.Errorf("Failed to decode envelope: %v", err)
+	}
+	if r.Header.Get(EventHeader) != envelope.Type {
+		rc.t.Errorf("%s header = %q, want %q", EventHeader, r.Header.Get(EventHeader), envelope.Type)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, envelope)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestDispatcher(store Store[Delivery], policy RetryPolicy, now *time.Time) *Dispatcher[Delivery, *Delivery] {
+	dispatcher := NewDispatcher(store, policy, true)
+	dispatcher.Now = func() time.Time { return *now }
+	return dispatcher
+}
+
+func queueTestDelivery(t *testing.T, store *MemoryStore[Delivery, *Delivery], url, secret string, now time.Time) Delivery {
+	t.Helper()
+	envelope, payload, err := NewEnvelope(EventChirpCreated, map[string]string{"body": "hello"}, now)
+	if err != nil {
+		t.Fatalf("Failed to create envelope: %v", err)
+	}
+	return store.Add(Delivery{
+		Queued:     Queued{NextAttemptAt: now},
+		EndpointID: uuid.New(),
+		URL:        url,
+		Secret:     secret,
+		EventID:    envelope.ID,
+		Event:      envelope.Type,
+		Payload:    payload,
+	})
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	rc := &receiver{t: t, secret: "endpoint-secret"}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	now := time.Now()
+	store := NewMemoryStore[Delivery]()
+	dispatcher := newTestDispatcher(store, DefaultRetryPolicy(), &now)
+	delivery := queueTestDelivery(t, store, server.URL, "endpoint-secret", now)
+
+	sent, err := dispatcher.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if sent != 1 || len(rc.received) != 1 {
+		t.Fatalf("Sent %d, received %d, want 1 each", sent, len(rc.received))
+	}
+	if rc.received[0].ID != delivery.EventID {
+		t.Fatalf("Received event %v, want %v", rc.received[0].ID, delivery.EventID)
+	}
+
+	got, _ := store.Get(delivery.ID)
+	if got.Status != StatusSucceeded || got.Attempts != 1 || got.ResponseStatus != http.StatusOK {
+		t.Fatalf("Unexpected delivery after success: %+v", got)
+	}
+
+	sent, err = dispatcher.RunOnce(context.Background())
+	if err != nil || sent != 0 {
+		t.Fatalf("RunOnce() = %d, %v; want nothing left to send", sent, err)
+	}
+}
+
+// failingStore fails to store the first delivery it is given.
+type failingStore struct {
+	*MemoryStore[Delivery, *Delivery]
+	failed bool
+}
+
+func (s *failingStore) Update(ctx context.Context, delivery Delivery) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("database is down")
+	}
+	return s.MemoryStore.Update(ctx, delivery)
+}
+
+func TestDispatcherContinuesAfterUpdateError(t *testing.T) {
+	rc := &receiver{t: t, secret: "endpoint-secret"}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	now := time.Now()
+	store := &failingStore{MemoryStore: NewMemoryStore[Delivery]()}
+	dispatcher := newTestDispatcher(store, DefaultRetryPolicy(), &now)
+	queueTestDelivery(t, store.MemoryStore, server.URL, "endpoint-secret", now)
+	queueTestDelivery(t, store.MemoryStore, server.URL, "endpoint-secret", now)
+
+	sent, err := dispatcher.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if sent != 2 || len(rc.received) != 2 {
+		t.Fatalf("Sent %d, received %d, want 2 each", sent, len(rc.received))
+	}
+
+	succeeded := 0
+	for _, envelope := range rc.received {
+		for _, delivery := range store.deliveries {
+			if delivery.EventID == envelope.ID && delivery.Status == StatusSucceeded {
+				succeeded++
+			}
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d deliveries stored as succeeded, want 1", succeeded)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	rc := &receiver{t: t, secret: "endpoint-secret", statuses: []int{500, 503}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	now := time.Now()
+	store := NewMemoryStore[Delivery]()
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	dispatcher := newTestDispatcher(store, policy, &now)
+	delivery := queueTestDelivery(t, store, server.URL, "endpoint-secret", now)
+
+	dispatcher.RunOnce(context.Background())
+	got, _ := store.Get(delivery.ID)
+	if got.Status != StatusPending || got.Attempts != 1 || got.ResponseStatus != 500 {
+		t.Fatalf("Unexpected delivery after first failure: %+v", got)
+	}
+	if !got.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("NextAttemptAt = %v, want %v", got.NextAttemptAt, now.Add(time.Minute))
+	}
+
+	// Not due yet.
+	if sent, _ := dispatcher.RunOnce(context.Background()); sent != 0 {
+		t.Fatalf("Retried %d deliveries before they were due", sent)
+	}
+
+	now = now.Add(time.Minute)
+	dispatcher.RunOnce(context.Background())
+	got, _ = store.Get(delivery.ID)
+	if got.Attempts != 2 || !got.NextAttemptAt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("Unexpected delivery after second failure: %+v", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	dispatcher.RunOnce(context.Background())
+	got, _ = store.Get(delivery.ID)
+	if got.Status != StatusSucceeded || got.Attempts != 3 || got.LastError != "" {
+		t.Fatalf("Unexpected delivery after retry succeeded: %+v", got)
+	}
+	if len(rc.received) != 3 {
+		t.Fatalf("Receiver got %d requests, want 3", len(rc.received))
+	}
+	for _, envelope := range rc.received {
+		if envelope.ID != delivery.EventID {
+			t.Fatalf("Retry changed the event ID to %v", envelope.ID)
+		}
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	rc := &receiver{t: t, secret: "endpoint-secret", statuses: []int{500, 500, 500}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	now := time.Now()
+	store := NewMemoryStore[Delivery]()
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second}
+	dispatcher := newTestDispatcher(store, policy, &now)
+	delivery := queueTestDelivery(t, store, server.URL, "endpoint-secret", now)
+
+	dispatcher.RunOnce(context.Background())
+	now = now.Add(time.Second)
+	dispatcher.RunOnce(context.Background())
+
+	got, _ := store.Get(delivery.ID)
+	if got.Status != StatusFailed || got.Attempts != 2 || got.LastError == "" {
+		t.Fatalf("Unexpected delivery after max attempts: %+v", got)
+	}
+	now = now.Add(time.Hour)
+	if sent, _ := dispatcher.RunOnce(context.Background()); sent != 0 {
+		t.Fatal("Failed delivery was retried")
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	rc := &receiver{t: t, secret: "endpoint-secret"}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	now := time.Now()
+	store := NewMemoryStore[Delivery]()
+	dispatcher := NewDispatcher(store, DefaultRetryPolicy(), false)
+	dispatcher.Now = func() time.Time { return now }
+	delivery := queueTestDelivery(t, store, server.URL, "endpoint-secret", now)
+
+	dispatcher.RunOnce(context.Background())
+	got, _ := store.Get(delivery.ID)
+	if got.Status != StatusPending || got.Attempts != 1 || got.LastError == "" {
+		t.Fatalf("Delivery to loopback wasn't refused: %+v", got)
+	}
+	if len(rc.received) != 0 {
+		t.Fatal("Receiver on loopback got a delivery")
+	}
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	rc := &receiver{t: t, secret: "endpoint-secret"}
+	target := httptest.NewServer(rc)
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	now := time.Now()
+	store := NewMemoryStore[Delivery]()
+	dispatcher := newTestDispatcher(store, DefaultRetryPolicy(), &now)
+	delivery := queueTestDelivery(t, store, redirect.URL, "endpoint-secret", now)
+
+	dispatcher.RunOnce(context.Background())
+	got, _ := store.Get(delivery.ID)
+	if got.Status != StatusPending || got.ResponseStatus != http.StatusTemporaryRedirect {
+		t.Fatalf("Unexpected delivery after redirect: %+v", got)
+	}
+	if len(rc.received) != 0 {
+		t.Fatal("Redirect was followed")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for attempts, delay := range want {
+		if got := policy.Backoff(attempts); got != delay {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, delay)
+		}
+	}
+}
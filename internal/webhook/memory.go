@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps the queue in process. Deliveries are lost on restart,
+// so use it only for tests.
+type MemoryStore[D any, P Queueable[D]] struct {
+	mu         sync.Mutex
+	deliveries map[uuid.UUID]D
+}
+
+func NewMemoryStore[D any, P Queueable[D]]() *MemoryStore[D, P] {
+	return &MemoryStore[D, P]{deliveries: map[uuid.UUID]D{}}
+}
+
+// Add queues a delivery, giving it an ID if it has none.
+func (s *MemoryStore[D, P]) Add(delivery D) D {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queued := P(&delivery).queued()
+	if queued.ID == uuid.Nil {
+		queued.ID = uuid.New()
+	}
+	if queued.Status == "" {
+		queued.Status = StatusPending
+	}
+	s.deliveries[queued.ID] = delivery
+	return delivery
+}
+
+// Get returns a delivery by ID.
+func (s *MemoryStore[D, P]) Get(id uuid.UUID) (D, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery, ok := s.deliveries[id]
+	return delivery, ok
+}
+
+// All returns every delivery, in no particular order.
+func (s *MemoryStore[D, P]) All() []D {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := []D{}
+	for _, delivery := range s.deliveries {
+		all = append(all, delivery)
+	}
+	return all
+}
+
+func (s *MemoryStore[D, P]) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]D, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []D{}
+	for _, delivery := range s.deliveries {
+		queued := P(&delivery).queued()
+		if queued.Status == StatusPending && !queued.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return P(&due[i]).queued().NextAttemptAt.Before(P(&due[j]).queued().NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, delivery := range due {
+		queued := P(&delivery).queued()
+		queued.NextAttemptAt = leaseUntil
+		s.deliveries[queued.ID] = delivery
+	}
+	return due, nil
+}
+
+func (s *MemoryStore[D, P]) Update(ctx context.Context, delivery D) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[P(&delivery).queued().ID] = delivery
+	return nil
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	header := Sign("secret", now, body)
+
+	err := Verify("secret", header, body, now.Add(time.Minute), DefaultTolerance)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt_1"}`)
+	valid := Sign("secret", now, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", secret: "secret", header: valid, body: body, now: now, wantErr: nil},
+		{name: "wrong secret", secret: "other", header: valid, body: body, now: now, wantErr: ErrSignatureMismatch},
+		{name: "tampered body", secret: "secret", header: valid, body: []byte(`{"id":"evt_2"}`), now: now, wantErr: ErrSignatureMismatch},
+		{name: "too old", secret: "secret", header: valid, body: body, now: now.Add(DefaultTolerance + time.Second), wantErr: ErrTimestampExpired},
+		{name: "from the future", secret: "secret", header: valid, body: body, now: now.Add(-DefaultTolerance - time.Second), wantErr: ErrTimestampExpired},
+		{name: "no timestamp", secret: "secret", header: "v1=abcd", body: body, now: now, wantErr: ErrMalformedHeader},
+		{name: "no signature", secret: "secret", header: "t=1767268800", body: body, now: now, wantErr: ErrNoSignature},
+		{name: "garbage", secret: "secret", header: "garbage", body: body, now: now, wantErr: ErrMalformedHeader},
+		{name: "non-hex signature", secret: "secret", header: "t=1767268800,v1=zz", body: body, now: now, wantErr: ErrMalformedHeader},
+		{name: "one of several signatures", secret: "secret", header: valid + ",v1=00ff", body: body, now: now, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, DefaultTolerance)
+			if err != tt.wantErr {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
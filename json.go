@@ -4,22 +4,37 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/MechamJonathan/chirpy/internal/problem"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
+// respondWithError responds with a problem whose code follows from the
+// status. Clients see msg only for 4xx statuses; err is only logged.
+func respondWithError(w http.ResponseWriter, status int, msg string, err error) {
+	respondWithProblem(w, problem.New(status, msg), err)
+}
+
+// respondWithProblem logs err and writes p.
+func respondWithProblem(w http.ResponseWriter, p *problem.Problem, err error) {
 	if err != nil {
 		log.Println(err)
 	}
-	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+	if p.Status > 499 {
+		log.Printf("Responding with 5XX error: %s", p.Detail)
 	}
+	problem.Write(w, p)
+}
 
-	type errorResponse struct {
-		Error string `json:"error"`
-	}
-	respondWithJSON(w, code, errorResponse{
-		Error: msg,
-	})
+// respondWithDecodeError responds to a body that couldn't be decoded.
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	respondWithProblem(w, problem.New(http.StatusBadRequest, "Couldn't decode parameters").WithCode(problem.CodeInvalidJSON), err)
+}
+
+// respondWithInvalidField responds with a validation problem for one field
+// of the body. err's text is shown, so it must come from checking the
+// client's input, never from the database or another service.
+func respondWithInvalidField(w http.ResponseWriter, field string, err error) {
+	respondWithProblem(w, problem.Invalid(err.Error(), problem.FieldError{In: "body", Field: field, Message: err.Error()}), nil)
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
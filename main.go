@@ -1,13 +1,30 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/MechamJonathan/chirpy/internal/activitypub"
+	"github.com/MechamJonathan/chirpy/internal/auth"
 	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/entitlements"
+	"github.com/MechamJonathan/chirpy/internal/lockout"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/MechamJonathan/chirpy/internal/openapi"
+	"github.com/MechamJonathan/chirpy/internal/realtime"
+	"github.com/MechamJonathan/chirpy/internal/stream"
+	"github.com/MechamJonathan/chirpy/internal/subscription"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
 
 	"github.com/joho/godotenv"
 
@@ -16,10 +33,30 @@ import (
 
 type apiConfig struct {
 	db             *database.Queries
+	dbConn         *sql.DB
 	fileserverHits atomic.Int32
 	platform       string
-	jwtSecret      string
+	// publicURL is where clients reach the server, for absolute links in
+	// feeds.
+	publicURL      string
+	keys           *auth.Keyring
+	tokens         *auth.TokenService
 	polkaKey       string
+	polkaTolerance time.Duration
+	adminKey       string
+	passwords      *auth.PasswordHasher
+	passwordPolicy *auth.PasswordPolicy
+
+	subscriptionGrace time.Duration
+	entitlements      *entitlements.Entitlements
+
+	chirpStream *stream.Hub
+	broker      realtime.Broker
+	realtime    *realtime.Server
+	federation  *activitypub.Server
+
+	loginEmailLimiter *lockout.Limiter
+	loginIPLimiter    *lockout.Limiter
 }
 
 func main() {
@@ -42,28 +79,143 @@ func main() {
 	}
 	dbQueries := database.New(dbConn)
 
+	// JWT_KEYS_DIR holds the asymmetric signing keys. Without it tokens are
+	// signed with JWT_SECRET.
 	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
+	jwtKeysDir := os.Getenv("JWT_KEYS_DIR")
+	keys := auth.NewKeyring()
+	if jwtKeysDir != "" {
+		err := keys.LoadDir(jwtKeysDir)
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %s", err)
+		}
+		go reloadKeysOnHangup(keys, jwtKeysDir)
+	} else if jwtSecret != "" {
+		keys.Add(auth.NewHMACKey("default", jwtSecret))
+	} else {
+		log.Fatal("JWT_KEYS_DIR or JWT_SECRET environment variable is required")
+	}
+
+	tokenConfig := auth.DefaultTokenConfig()
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		tokenConfig.Issuer = issuer
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		tokenConfig.Audience = audience
+	}
+	tokenConfig.AccessTokenTTL = durationEnv("ACCESS_TOKEN_TTL", tokenConfig.AccessTokenTTL)
+	tokenConfig.MaxAccessTokenTTL = durationEnv("ACCESS_TOKEN_MAX_TTL", tokenConfig.MaxAccessTokenTTL)
+	tokenConfig.RefreshTokenTTL = durationEnv("REFRESH_TOKEN_TTL", tokenConfig.RefreshTokenTTL)
+	tokenConfig.Leeway = durationEnv("JWT_LEEWAY", tokenConfig.Leeway)
+	if tokenConfig.MaxAccessTokenTTL < tokenConfig.AccessTokenTTL {
+		tokenConfig.MaxAccessTokenTTL = tokenConfig.AccessTokenTTL
 	}
 
 	polkaKey := os.Getenv("POLKA_KEY")
-	if jwtSecret == "" {
+	if polkaKey == "" {
 		log.Fatal("POLKA_KEY environment variable is required")
 	}
+	polkaTolerance := durationEnv("POLKA_SIGNATURE_TOLERANCE", webhook.DefaultTolerance)
+	subscriptionGrace := durationEnv("SUBSCRIPTION_GRACE_PERIOD", subscription.DefaultGracePeriod)
+
+	// ENTITLEMENTS_FILE overrides what each tier may do, see
+	// internal/entitlements.
+	tierEntitlements := entitlements.Default()
+	if path := os.Getenv("ENTITLEMENTS_FILE"); path != "" {
+		tierEntitlements, err = entitlements.LoadFile(path)
+		if err != nil {
+			log.Fatalf("Error loading entitlements: %s", err)
+		}
+	}
+
+	// Failed logins are counted per account and per client IP. An IP may
+	// fail more often than an account since many users can share one.
+	var lockoutStore lockout.Store = lockout.NewDBStore(dbQueries)
+	if os.Getenv("LOGIN_ATTEMPTS_STORE") == "memory" {
+		lockoutStore = lockout.NewMemoryStore()
+	}
+	emailPolicy := lockout.DefaultPolicy()
+	emailPolicy.MaxFailures = intEnv("LOGIN_MAX_FAILURES", emailPolicy.MaxFailures)
+	emailPolicy.LockDuration = durationEnv("LOGIN_LOCK_DURATION", emailPolicy.LockDuration)
+	ipPolicy := emailPolicy
+	ipPolicy.MaxFailures = intEnv("LOGIN_IP_MAX_FAILURES", 10*emailPolicy.MaxFailures)
+
+	argon2Params := auth.DefaultArgon2Params()
+	argon2Params.Memory = uint32(intEnv("ARGON2_MEMORY_KIB", int(argon2Params.Memory)))
+	argon2Params.Iterations = uint32(intEnv("ARGON2_ITERATIONS", int(argon2Params.Iterations)))
+	argon2Params.Parallelism = uint8(intEnv("ARGON2_PARALLELISM", int(argon2Params.Parallelism)))
+	passwordPolicy := auth.DefaultPasswordPolicy()
+	passwordPolicy.MinLength = intEnv("PASSWORD_MIN_LENGTH", passwordPolicy.MinLength)
+
+	// The broker is in process, so WebSocket clients only see events from
+	// the instance they're connected to.
+	var broker realtime.Broker = realtime.NewLocalBroker()
+	realtimeOptions := realtime.DefaultOptions()
+	realtimeOptions.PingInterval = durationEnv("WS_PING_INTERVAL", realtimeOptions.PingInterval)
+	realtimeOptions.PongTimeout = durationEnv("WS_PONG_TIMEOUT", realtimeOptions.PongTimeout)
+	realtimeOptions.SendBuffer = intEnv("WS_SEND_BUFFER", realtimeOptions.SendBuffer)
+
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
 
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:             dbQueries,
+		dbConn:         dbConn,
 		platform:       platform,
-		jwtSecret:      jwtSecret,
+		publicURL:      publicURL,
+		keys:           keys,
+		tokens:         auth.NewTokenService(keys, tokenConfig),
+		passwords:      auth.NewPasswordHasher(argon2Params),
+		passwordPolicy: passwordPolicy,
+
 		polkaKey:       polkaKey,
+		polkaTolerance: polkaTolerance,
+		adminKey:       os.Getenv("ADMIN_API_KEY"),
+
+		subscriptionGrace: subscriptionGrace,
+		entitlements:      tierEntitlements,
+
+		chirpStream: stream.NewHub(intEnv("STREAM_REPLAY_BUFFER", 1000), 64),
+		broker:      broker,
+		realtime:    realtime.NewServer(broker, realtimeOptions),
+
+		loginEmailLimiter: lockout.NewLimiter(lockoutStore, emailPolicy),
+		loginIPLimiter:    lockout.NewLimiter(lockoutStore, ipPolicy),
 	}
 
+	// SIGINT and SIGTERM stop background work and drain the server.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go apiCfg.expireSubscriptionsEvery(ctx, durationEnv("SUBSCRIPTION_EXPIRY_INTERVAL", time.Hour))
+
+	// Outgoing webhooks may only reach private addresses in development,
+	// where receivers usually run on localhost.
+	webhookRetries := webhook.DefaultRetryPolicy()
+	webhookRetries.MaxAttempts = intEnv("WEBHOOK_MAX_ATTEMPTS", webhookRetries.MaxAttempts)
+	dispatcher := webhook.NewDispatcher(webhook.NewDBStore(dbQueries), webhookRetries, platform == "dev")
+	go dispatcher.Run(ctx, durationEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second))
+
+	// Federation follows the same rule, and retries deliveries to other
+	// servers the way webhooks are retried.
+	federation, err := activitypub.NewServer(publicURL, federationBackend{cfg: &apiCfg}, activitypub.NewDBStore(dbQueries),
+		webhook.NewClient(platform == "dev"), webhookRetries)
+	if err != nil {
+		log.Fatalf("Invalid PUBLIC_URL: %s", err)
+	}
+	apiCfg.federation = federation
+	go federation.Run(ctx, durationEnv("ACTIVITYPUB_POLL_INTERVAL", 5*time.Second))
+
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
 
 	mux.HandleFunc("GET /api/healthz", readinessHandler)
+	mux.HandleFunc("GET /api/openapi.json", handlerOpenAPI)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+	federation.Register(mux)
 
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerWebHook)
 
@@ -71,26 +223,164 @@ func main() {
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
 
+	mux.HandleFunc("GET /api/sessions", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerSessionsList))
+	mux.HandleFunc("DELETE /api/sessions", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerSessionsRevokeOthers))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerSessionsRevoke))
+
+	mux.HandleFunc("POST /api/tokens", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerTokensCreate))
+	mux.HandleFunc("GET /api/tokens", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerTokensList))
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerTokensRevoke))
+
+	mux.HandleFunc("POST /api/webhooks", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerWebhookEndpointsCreate))
+	mux.HandleFunc("GET /api/webhooks", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerWebhookEndpointsList))
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerWebhookEndpointsDelete))
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerWebhookDeliveriesList))
+	mux.HandleFunc("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/replay", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerWebhookDeliveriesReplay))
+
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.handlerOAuthAuthorizeConsent)
+	mux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
+	mux.HandleFunc("POST /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerOAuthClientsCreate))
+
 	mux.HandleFunc("POST /api/users", apiCfg.handlerUsersCreate)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUsersUpdate)
+	mux.HandleFunc("PUT /api/users", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerUsersUpdate))
+	mux.HandleFunc("GET /api/users/me/entitlements", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerEntitlementsGet))
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.handler_chirps_create)
+	mux.HandleFunc("POST /api/chirps", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handler_chirps_create))
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerChirpsRetrieve)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerChirpsGet)
 	mux.HandleFunc("GET /api/chirps?author_id=<uuid>", apiCfg.handlerChirpsRetrieve)
 	mux.HandleFunc("GET /api/chirps?sort=asc", apiCfg.handlerChirpsRetrieve)
 	mux.HandleFunc("GET /api/chirps?sort=desc", apiCfg.handlerChirpsRetrieve)
 
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerChirpsDelete)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handlerChirpsDelete))
+	mux.HandleFunc("GET /api/stream/chirps", apiCfg.handlerStreamChirps)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerRealtime)
+	mux.HandleFunc("POST /api/ws/tickets", apiCfg.middlewareAuth(oauth.ScopeChirpsRead, apiCfg.handlerRealtimeTicketsCreate))
+	mux.HandleFunc("GET /users/{userID}/feed.atom", apiCfg.handlerFeedUser)
+	mux.HandleFunc("GET /users/{userID}/feed.rss", apiCfg.handlerFeedUser)
+	mux.HandleFunc("GET /hashtags/{tag}/feed.atom", apiCfg.handlerFeedHashtag)
+	mux.HandleFunc("GET /hashtags/{tag}/feed.rss", apiCfg.handlerFeedHashtag)
+
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handlerChirpLikesCreate))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handlerChirpLikesDelete))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handlerChirpPinsCreate))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handlerChirpPinsDelete))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handlerBookmarksCreate))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(oauth.ScopeChirpsWrite, apiCfg.handlerBookmarksDelete))
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.middlewareAuth(oauth.ScopeChirpsRead, apiCfg.handlerBookmarksList))
+
+	mux.HandleFunc("POST /api/lists", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerListsCreate))
+	mux.HandleFunc("GET /api/users/{userID}/lists", apiCfg.handlerListsForUser)
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.handlerListsGet)
+	mux.HandleFunc("GET /api/lists/{listID}/chirps", apiCfg.handlerListsChirps)
+	mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerListsUpdate))
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerListsDelete))
+	mux.HandleFunc("POST /api/lists/{listID}/members", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerListMembersCreate))
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerListMembersDelete))
+
+	mux.HandleFunc("GET /api/notifications", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerNotificationsList))
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerNotificationsRead))
+	mux.HandleFunc("GET /api/notifications/preferences", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerNotificationPreferencesGet))
+	mux.HandleFunc("PUT /api/notifications/preferences", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerNotificationPreferencesUpdate))
+
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerFollowsCreate))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerFollowsDelete))
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerBlocksCreate))
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerBlocksDelete))
+
+	mux.HandleFunc("POST /api/conversations", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerConversationsCreate))
+	mux.HandleFunc("GET /api/conversations", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerConversationsList))
+	mux.HandleFunc("GET /api/conversations/{conversationID}", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerConversationsGet))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerConversationsRead))
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerMessagesList))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.middlewareAuth(oauth.ScopeProfile, apiCfg.handlerMessagesCreate))
 
 	mux.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
 	mux.HandleFunc("POST /admin/reset", apiCfg.resetHandler)
+	mux.HandleFunc("GET /admin/webhooks/dead-letters", apiCfg.middlewareAdmin(apiCfg.handlerWebhookDeadLettersList))
+	mux.HandleFunc("POST /admin/webhooks/dead-letters/{deadLetterID}/replay", apiCfg.middlewareAdmin(apiCfg.handlerWebhookDeadLettersReplay))
+
+	// OPENAPI_VALIDATE_REQUESTS checks every request against the OpenAPI
+	// document before routing it.
+	var handler http.Handler = mux
+	if os.Getenv("OPENAPI_VALIDATE_REQUESTS") == "true" {
+		doc, err := openapi.Load()
+		if err != nil {
+			log.Fatalf("Error loading OpenAPI document: %s", err)
+		}
+		handler = middlewareValidate(doc, mux)
+	}
 
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: handler,
+	}
+
+	// Open streams never go idle, so Shutdown would wait on them until its
+	// timeout, and it doesn't track WebSockets at all. Close both directly.
+	server.RegisterOnShutdown(apiCfg.chirpStream.Close)
+	server.RegisterOnShutdown(apiCfg.realtime.Close)
+
+	go func() {
+		log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), durationEnv("SHUTDOWN_TIMEOUT", 15*time.Second))
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Error shutting down: %s", err)
 	}
+}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(server.ListenAndServe())
+// reloadKeysOnHangup reloads the JWT keys from dir whenever the process gets
+// SIGHUP, so keys can be rotated without a restart.
+func reloadKeysOnHangup(keys *auth.Keyring, dir string) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		err := keys.LoadDir(dir)
+		if err != nil {
+			log.Printf("Error reloading JWT keys, keeping current keys: %s", err)
+			continue
+		}
+		log.Printf("Reloaded JWT keys from %s", dir)
+	}
+}
+
+// durationEnv reads a duration such as "15m" from the environment, falling
+// back to def when the variable is unset.
+func durationEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration like 1h or 15m: %s", name, err)
+	}
+	return d
+}
+
+// intEnv reads an integer from the environment, falling back to def when
+// the variable is unset.
+func intEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %s", name, err)
+	}
+	return n
 }
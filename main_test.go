@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/activitypub"
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/entitlements"
+	"github.com/MechamJonathan/chirpy/internal/lockout"
+	"github.com/MechamJonathan/chirpy/internal/realtime"
+	"github.com/MechamJonathan/chirpy/internal/stream"
+	"github.com/MechamJonathan/chirpy/internal/subscription"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+
+	_ "github.com/lib/pq"
+)
+
+// Handler tests that need Postgres run against the database at
+// TEST_DB_URL and are skipped without one. The database is wiped and
+// migrated from sql/schema once per run, then emptied before each test.
+//
+//	TEST_DB_URL=postgres://localhost:5432/chirpy_test?sslmode=disable go test ./...
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// newTestConfig returns an apiConfig wired like main's, backed by an
+// empty test database.
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	dbConn, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("Couldn't open test database: %v", err)
+	}
+	t.Cleanup(func() { dbConn.Close() })
+
+	migrateOnce.Do(func() { migrateErr = migrateTestDB(dbConn) })
+	if migrateErr != nil {
+		t.Fatalf("Couldn't migrate test database: %v", migrateErr)
+	}
+	err = truncateTestDB(dbConn)
+	if err != nil {
+		t.Fatalf("Couldn't empty test database: %v", err)
+	}
+
+	keys := auth.NewKeyring()
+	keys.Add(auth.NewHMACKey("test", "test-secret"))
+	// Cheap parameters keep password hashing from dominating the tests.
+	argon2Params := auth.DefaultArgon2Params()
+	argon2Params.Memory = 64
+	argon2Params.Iterations = 1
+	argon2Params.Parallelism = 1
+
+	broker := realtime.NewLocalBroker()
+	limiterStore := lockout.NewMemoryStore()
+	cfg := &apiConfig{
+		db:             database.New(dbConn),
+		dbConn:         dbConn,
+		platform:       "dev",
+		publicURL:      "http://chirpy.test",
+		keys:           keys,
+		tokens:         auth.NewTokenService(keys, auth.DefaultTokenConfig()),
+		passwords:      auth.NewPasswordHasher(argon2Params),
+		passwordPolicy: auth.DefaultPasswordPolicy(),
+		polkaKey:       "test-polka-key",
+		polkaTolerance: webhook.DefaultTolerance,
+		adminKey:       "test-admin-key",
+
+		subscriptionGrace: subscription.DefaultGracePeriod,
+		entitlements:      entitlements.Default(),
+
+		chirpStream: stream.NewHub(100, 8),
+		broker:      broker,
+		realtime:    realtime.NewServer(broker, realtime.DefaultOptions()),
+
+		loginEmailLimiter: lockout.NewLimiter(limiterStore, lockout.DefaultPolicy()),
+		loginIPLimiter:    lockout.NewLimiter(limiterStore, lockout.DefaultPolicy()),
+	}
+	t.Cleanup(cfg.chirpStream.Close)
+	t.Cleanup(cfg.realtime.Close)
+
+	// Deliveries are queued in memory and never sent unless a test runs
+	// them.
+	cfg.federation, err = activitypub.NewServer(cfg.publicURL, federationBackend{cfg: cfg}, activitypub.NewMemoryStore(),
+		webhook.NewClient(true), webhook.DefaultRetryPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// migrateTestDB recreates the public schema and applies the Up section of
+// every goose migration in order.
+func migrateTestDB(db *sql.DB) error {
+	_, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+	if err != nil {
+		return err
+	}
+
+	paths, err := filepath.Glob(filepath.Join("sql", "schema", "*.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		_, err = db.Exec(up)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// truncateTestDB empties every table the migrations created.
+func truncateTestDB(db *sql.DB) error {
+	rows, err := db.Query("SELECT tablename FROM pg_tables WHERE schemaname = 'public'")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	tables := []string{}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		tables = append(tables, `"`+table+`"`)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	_, err = db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " CASCADE")
+	return err
+}
+
+// createTestUser stores a user with the given username and returns it.
+func createTestUser(t *testing.T, cfg *apiConfig, username string) database.User {
+	t.Helper()
+	hashedPassword, err := cfg.passwords.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          username + "@example.com",
+		HashedPassword: hashedPassword,
+		Username:       sql.NullString{String: username, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Couldn't create user %s: %v", username, err)
+	}
+	return user
+}
+
+// loginTestUser starts a first-party session for user and returns its
+// access token.
+func loginTestUser(t *testing.T, cfg *apiConfig, user database.User) auth.AccessToken {
+	t.Helper()
+	sess := tokenSession{UserID: user.ID, UserAgent: "test", IPAddress: "192.0.2.1"}
+	_, err := cfg.issueRefreshToken(context.Background(), cfg.db, &sess)
+	if err != nil {
+		t.Fatalf("Couldn't start session: %v", err)
+	}
+	return auth.AccessToken{UserID: user.ID, SessionID: sess.ID}
+}
+
+// serveAuthed calls h as middlewareAuth would for accessToken. pathValues
+// alternate names and values.
+func serveAuthed(h authedHandler, accessToken auth.AccessToken, method, target, body string, pathValues ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		r.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	w := httptest.NewRecorder()
+	h(w, r, accessToken)
+	return w
+}
+
+// expectStatus fails the test unless w has the given status.
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, status, w.Body)
+	}
+}
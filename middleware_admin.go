@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+)
+
+// middlewareAdmin lets requests through only with the ADMIN_API_KEY in an
+// "Authorization: ApiKey <key>" header. Without a configured key, the
+// routes it guards are disabled.
+func (cfg *apiConfig) middlewareAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.adminKey == "" {
+			respondWithError(w, http.StatusForbidden, "Admin API is disabled", nil)
+			return
+		}
+		apiKey, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't get ApiKey from header", err)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.adminKey)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't verify ApiKey", nil)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/problem"
+)
+
+// authedHandler handles a route that requires an access token.
+type authedHandler func(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken)
+
+// middlewareAuth validates the bearer access token and checks that it
+// grants scope before calling next. Tokens from Chirpy's own login grant
+// every scope; tokens issued to OAuth clients only grant what the user
+// consented to, and personal access tokens what they were created with.
+func (cfg *apiConfig) middlewareAuth(scope string, next authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy"`)
+			respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+			return
+		}
+		accessToken, err := cfg.validateBearerToken(r.Context(), token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy", error="invalid_token"`)
+			respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+			return
+		}
+		if !accessToken.HasScope(scope) {
+			respondWithInsufficientScope(w, scope)
+			return
+		}
+
+		next(w, r, accessToken)
+	}
+}
+
+// respondWithInsufficientScope tells the client its token doesn't grant
+// scope.
+func respondWithInsufficientScope(w http.ResponseWriter, scope string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", scope=%q`, scope))
+	p := problem.New(http.StatusForbidden, "Token doesn't grant the "+scope+" scope").WithCode(problem.CodeInsufficientScope)
+	respondWithProblem(w, p, nil)
+}
+
+// validateBearerToken accepts either a JWT access token or a personal
+// access token.
+func (cfg *apiConfig) validateBearerToken(ctx context.Context, token string) (auth.AccessToken, error) {
+	if !auth.IsPersonalToken(token) {
+		return cfg.tokens.ValidateAccessToken(token)
+	}
+
+	personalToken, err := cfg.db.UsePersonalToken(ctx, auth.HashToken(token))
+	if err != nil {
+		return auth.AccessToken{}, err
+	}
+	return auth.AccessToken{
+		UserID:          personalToken.UserID,
+		ExpiresAt:       personalToken.ExpiresAt.Time,
+		Scopes:          strings.Fields(personalToken.Scopes),
+		PersonalTokenID: personalToken.ID,
+	}, nil
+}
+
+// optionalAccessToken is for public routes that show the owner more than
+// everyone else. ok is false if there's no bearer token, or it doesn't
+// grant scope; an invalid token is an error.
+func (cfg *apiConfig) optionalAccessToken(r *http.Request, scope string) (accessToken auth.AccessToken, ok bool, err error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return auth.AccessToken{}, false, nil
+	}
+	accessToken, err = cfg.validateBearerToken(r.Context(), token)
+	if err != nil {
+		return auth.AccessToken{}, false, err
+	}
+	return accessToken, accessToken.HasScope(scope), nil
+}
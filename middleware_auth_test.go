@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/google/uuid"
+)
+
+func TestMiddlewareAuthScopes(t *testing.T) {
+	keys := auth.NewKeyring()
+	keys.Add(auth.NewHMACKey("test", "test-secret"))
+	cfg := &apiConfig{keys: keys, tokens: auth.NewTokenService(keys, auth.DefaultTokenConfig())}
+
+	userID, sessionID := uuid.New(), uuid.New()
+	firstParty, err := cfg.tokens.MakeAccessToken(userID, sessionID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := cfg.tokens.MakeClientAccessToken(userID, sessionID, "client", []string{oauth.ScopeChirpsRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := cfg.tokens.MakeClientAccessToken(userID, sessionID, "client", []string{oauth.ScopeChirpsWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"first-party login grants every scope", firstParty, http.StatusNoContent},
+		{"client granted the scope", reader, http.StatusNoContent},
+		{"client granted another scope", writer, http.StatusForbidden},
+		{"no token", "", http.StatusUnauthorized},
+		{"invalid token", "not-a-jwt", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := cfg.middlewareAuth(oauth.ScopeChirpsRead, func(w http.ResponseWriter, r *http.Request, accessToken auth.AccessToken) {
+				called = true
+				if accessToken.UserID != userID {
+					t.Errorf("UserID = %s, want %s", accessToken.UserID, userID)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/api/bookmarks", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+
+			expectStatus(t, w, tt.status)
+			if called != (tt.status == http.StatusNoContent) {
+				t.Errorf("handler called = %v", called)
+			}
+			if tt.status == http.StatusForbidden && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
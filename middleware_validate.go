@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/MechamJonathan/chirpy/internal/openapi"
+	"github.com/MechamJonathan/chirpy/internal/problem"
+)
+
+// middlewareValidate rejects requests whose parameters or body don't match
+// the OpenAPI document before they reach a handler. Routes the document
+// doesn't describe are let through.
+func middlewareValidate(doc *openapi.Document, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := doc.ValidateRequest(r)
+		var invalid *openapi.ValidationError
+		if errors.As(err, &invalid) {
+			respondWithProblem(w, problem.Invalid("Request doesn't match the API schema", invalid.Errors...), nil)
+			return
+		}
+		if errors.Is(err, openapi.ErrBodyTooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request body is too large", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't read request", err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/activitypub"
+	"github.com/MechamJonathan/chirpy/internal/auth"
+	"github.com/MechamJonathan/chirpy/internal/entitlements"
+	"github.com/MechamJonathan/chirpy/internal/openapi"
+	"github.com/MechamJonathan/chirpy/internal/problem"
+	"github.com/MechamJonathan/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// The OpenAPI document is written by hand. These tests fail when it
+// drifts from the routes registered in main.go, from the parameters and
+// response structs declared in handlers, or from the types handlers
+// respond with.
+
+// apiTypes are described by component schemas of the same name.
+var apiTypes = []interface{}{
+	Block{},
+	Chirp{},
+	ChirpLike{},
+	Conversation{},
+	Follow{},
+	List{},
+	Message{},
+	Notification{},
+	OAuthClient{},
+	Participant{},
+	PersonalToken{},
+	QuotedChirp{},
+	Session{},
+	User{},
+	WebhookDeadLetter{},
+	WebhookDelivery{},
+	WebhookEndpoint{},
+	auth.JWK{},
+	auth.JWKS{},
+	entitlements.Grant{},
+	problem.FieldError{},
+	problem.Problem{},
+}
+
+// jsonKind is how a Go type is encoded: its JSON Schema type and format,
+// the component schema it refers to, and the kind of its array items. A
+// zero typ means any JSON value, or a type the tests don't know.
+type jsonKind struct {
+	typ     string
+	format  string
+	ref     string
+	pointer bool
+	items   *jsonKind
+}
+
+// jsonField is a field of a struct as it's encoded.
+type jsonField struct {
+	name      string
+	omitempty bool
+	kind      jsonKind
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+func kindOf(t reflect.Type) jsonKind {
+	switch t {
+	case timeType:
+		return jsonKind{typ: "string", format: "date-time"}
+	case uuidType:
+		return jsonKind{typ: "string", format: "uuid"}
+	case rawType:
+		return jsonKind{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		kind := kindOf(t.Elem())
+		kind.pointer = true
+		return kind
+	case reflect.String:
+		return jsonKind{typ: "string"}
+	case reflect.Bool:
+		return jsonKind{typ: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonKind{typ: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return jsonKind{typ: "number"}
+	case reflect.Slice, reflect.Array:
+		items := kindOf(t.Elem())
+		return jsonKind{typ: "array", items: &items}
+	case reflect.Map:
+		return jsonKind{typ: "object"}
+	case reflect.Struct:
+		return jsonKind{typ: "object", ref: t.Name()}
+	}
+	return jsonKind{}
+}
+
+// reflectFields lists a struct's JSON fields, including those of embedded
+// structs.
+func reflectFields(t reflect.Type) []jsonField {
+	fields := []jsonField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			fields = append(fields, reflectFields(field.Type)...)
+			continue
+		}
+		name, omitempty, ok := parseTag(field.Tag.Get("json"), field.Name)
+		if !ok || !field.IsExported() {
+			continue
+		}
+		fields = append(fields, jsonField{name: name, omitempty: omitempty, kind: kindOf(field.Type)})
+	}
+	return fields
+}
+
+func parseTag(tag, fieldName string) (name string, omitempty, ok bool) {
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = fieldName
+	}
+	return name, strings.Contains(","+options+",", ",omitempty,"), true
+}
+
+// kindOfExpr is kindOf for a type in the source. Named types other than
+// the API types aren't known.
+func kindOfExpr(expr ast.Expr) jsonKind {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		kind := kindOfExpr(e.X)
+		kind.pointer = true
+		return kind
+	case *ast.ArrayType:
+		items := kindOfExpr(e.Elt)
+		return jsonKind{typ: "array", items: &items}
+	case *ast.MapType:
+		return jsonKind{typ: "object"}
+	case *ast.SelectorExpr:
+		switch pkg, _ := e.X.(*ast.Ident); pkg.Name + "." + e.Sel.Name {
+		case "time.Time":
+			return jsonKind{typ: "string", format: "date-time"}
+		case "uuid.UUID":
+			return jsonKind{typ: "string", format: "uuid"}
+		}
+	case *ast.Ident:
+		switch e.Name {
+		case "string":
+			return jsonKind{typ: "string"}
+		case "bool":
+			return jsonKind{typ: "boolean"}
+		case "int", "int32", "int64":
+			return jsonKind{typ: "integer"}
+		case "float64":
+			return jsonKind{typ: "number"}
+		}
+		for _, v := range apiTypes {
+			if reflect.TypeOf(v).Name() == e.Name {
+				return jsonKind{typ: "object", ref: e.Name}
+			}
+		}
+	}
+	return jsonKind{}
+}
+
+// checkKind returns what's wrong with s as the schema for kind, if
+// anything.
+func checkKind(doc *openapi.Document, s *openapi.Schema, kind jsonKind) string {
+	if s == nil {
+		return "has no schema"
+	}
+	if kind.ref != "" {
+		if s.Ref != "#/components/schemas/"+kind.ref {
+			return "should refer to #/components/schemas/" + kind.ref
+		}
+		return ""
+	}
+	s = doc.Resolve(s)
+	if kind.typ == "" {
+		return ""
+	}
+	types := s.Types()
+	if len(types) == 0 {
+		return "has no type, want " + kind.typ
+	}
+	found := false
+	for _, t := range types {
+		found = found || t == kind.typ
+	}
+	if !found {
+		return "is " + strings.Join(types, " or ") + ", want " + kind.typ
+	}
+	if kind.format != "" && s.Format != kind.format {
+		return "has format " + strconv.Quote(s.Format) + ", want " + strconv.Quote(kind.format)
+	}
+	if kind.items != nil {
+		if problem := checkKind(doc, s.Items, *kind.items); problem != "" {
+			return "items " + problem
+		}
+	}
+	return ""
+}
+
+// compareFields checks that an object schema has exactly fields. For
+// responses, a field must be required unless it's omitempty, and nullable
+// exactly when it's a pointer that isn't.
+func compareFields(t *testing.T, doc *openapi.Document, where string, s *openapi.Schema, fields []jsonField, response bool) {
+	t.Helper()
+	s = doc.Resolve(s)
+	if s == nil || len(s.Types()) != 1 || s.Types()[0] != "object" {
+		t.Errorf("%s: schema isn't an object", where)
+		return
+	}
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	names := map[string]bool{}
+	for _, field := range fields {
+		names[field.name] = true
+		property, ok := s.Properties[field.name]
+		if !ok {
+			t.Errorf("%s: %s is missing from the OpenAPI document", where, field.name)
+			continue
+		}
+		if problem := checkKind(doc, property, field.kind); problem != "" {
+			t.Errorf("%s: %s %s", where, field.name, problem)
+		}
+		if !response {
+			continue
+		}
+		if required[field.name] == field.omitempty {
+			t.Errorf("%s: %s should be required exactly when it isn't omitempty", where, field.name)
+		}
+		if property.Ref == "" && property.Nullable() != (field.kind.pointer && !field.omitempty) {
+			t.Errorf("%s: %s should be nullable exactly when it's a pointer without omitempty", where, field.name)
+		}
+	}
+	for name := range s.Properties {
+		if !names[name] {
+			t.Errorf("%s: %s is in the OpenAPI document but not the struct", where, name)
+		}
+	}
+}
+
+func loadOpenAPI(t *testing.T) *openapi.Document {
+	t.Helper()
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("openapi.Load() error = %v", err)
+	}
+	return doc
+}
+
+// parseSources parses the package's non-test files.
+func parseSources(t *testing.T) []*ast.File {
+	t.Helper()
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []*ast.File{}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// registeredRoute is a mux.Handle or mux.HandleFunc call in main.
+type registeredRoute struct {
+	method  string
+	path    string
+	handler string
+}
+
+func parseRoutes(t *testing.T, files []*ast.File) []registeredRoute {
+	t.Helper()
+	routes := []registeredRoute{}
+	seen := map[string]bool{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Name.Name != "main" || fn.Recv != nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) != 2 {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+					return true
+				}
+				if mux, ok := sel.X.(*ast.Ident); !ok || mux.Name != "mux" {
+					return true
+				}
+				lit, ok := call.Args[0].(*ast.BasicLit)
+				if !ok {
+					t.Fatalf("route pattern isn't a string literal")
+				}
+				pattern, _ := strconv.Unquote(lit.Value)
+				method, path, found := strings.Cut(pattern, " ")
+				if !found {
+					// The file server answers every method; the document
+					// describes GET.
+					method, path = http.MethodGet, pattern+"{path}"
+				}
+				path, _, _ = strings.Cut(path, "?")
+				if seen[method+" "+path] {
+					return true
+				}
+				seen[method+" "+path] = true
+				routes = append(routes, registeredRoute{method: method, path: path, handler: handlerName(call.Args[1])})
+				return true
+			})
+		}
+	}
+	if len(routes) == 0 {
+		t.Fatal("found no routes in main")
+	}
+	return routes
+}
+
+// handlerName unwraps middleware to find the function that handles a
+// route.
+func handlerName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		return handlerName(e.Args[len(e.Args)-1])
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	described := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			described[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, route := range parseRoutes(t, parseSources(t)) {
+		key := route.method + " " + route.path
+		if !described[key] {
+			t.Errorf("%s is registered in main.go but isn't in the OpenAPI document", key)
+		}
+		delete(described, key)
+	}
+
+	// What's left must be registered by the federation server.
+	mux := http.NewServeMux()
+	federation, err := activitypub.NewServer("https://chirpy.example", nil, nil, nil, webhook.RetryPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	federation.Register(mux)
+	for key := range described {
+		method, path, _ := strings.Cut(key, " ")
+		_, pattern := mux.Handler(httptest.NewRequest(method, path, nil))
+		if pattern != key {
+			t.Errorf("%s is in the OpenAPI document but isn't registered", key)
+		}
+	}
+}
+
+func TestOpenAPIHandlerStructs(t *testing.T) {
+	doc := loadOpenAPI(t)
+	files := parseSources(t)
+	funcs := map[string]*ast.FuncDecl{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				funcs[fn.Name.Name] = fn
+			}
+		}
+	}
+
+	for _, route := range parseRoutes(t, files) {
+		fn := funcs[route.handler]
+		if fn == nil {
+			continue
+		}
+		op := doc.Paths[route.path][strings.ToLower(route.method)]
+		if op == nil {
+			continue
+		}
+		where := route.method + " " + route.path
+		structs := localStructs(fn)
+
+		if params, ok := structs["parameters"]; ok {
+			var schema *openapi.Schema
+			if op.RequestBody != nil && op.RequestBody.Content["application/json"] != nil {
+				schema = op.RequestBody.Content["application/json"].Schema
+			}
+			if schema == nil {
+				t.Errorf("%s decodes a JSON body but the OpenAPI document has none", where)
+			} else {
+				compareFields(t, doc, where+" request", schema, astFields(t, doc, params), false)
+			}
+		}
+
+		if resp, ok := structs["response"]; ok {
+			schema := successSchema(op)
+			if schema == nil {
+				t.Errorf("%s responds with JSON but the OpenAPI document has no 2xx JSON response", where)
+			} else {
+				compareFields(t, doc, where+" response", schema, astFields(t, doc, resp), true)
+			}
+		}
+	}
+}
+
+// localStructs returns the struct types declared in a function by name.
+func localStructs(fn *ast.FuncDecl) map[string]*ast.StructType {
+	structs := map[string]*ast.StructType{}
+	for _, stmt := range fn.Body.List {
+		decl, ok := stmt.(*ast.DeclStmt)
+		if !ok {
+			continue
+		}
+		gen, ok := decl.Decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			if st, ok := typeSpec.Type.(*ast.StructType); ok {
+				structs[typeSpec.Name.Name] = st
+			}
+		}
+	}
+	return structs
+}
+
+// astFields is reflectFields for a struct in the source. Embedded API
+// types contribute their fields.
+func astFields(t *testing.T, doc *openapi.Document, st *ast.StructType) []jsonField {
+	t.Helper()
+	fields := []jsonField{}
+	for _, field := range st.Fields.List {
+		tag := ""
+		if field.Tag != nil {
+			value, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(value).Get("json")
+		}
+		if len(field.Names) == 0 {
+			embedded, ok := field.Type.(*ast.Ident)
+			if !ok {
+				t.Fatalf("can't follow embedded field %T", field.Type)
+			}
+			found := false
+			for _, v := range apiTypes {
+				if reflect.TypeOf(v).Name() == embedded.Name {
+					fields = append(fields, reflectFields(reflect.TypeOf(v))...)
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("embedded %s isn't one of apiTypes", embedded.Name)
+			}
+			continue
+		}
+		for _, name := range field.Names {
+			jsonName, omitempty, ok := parseTag(tag, name.Name)
+			if !ok || !name.IsExported() {
+				continue
+			}
+			fields = append(fields, jsonField{name: jsonName, omitempty: omitempty, kind: kindOfExpr(field.Type)})
+		}
+	}
+	return fields
+}
+
+// successSchema returns the JSON schema of an operation's first 2xx
+// response.
+func successSchema(op *openapi.Operation) *openapi.Schema {
+	codes := []string{}
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		if media := op.Responses[code].Content["application/json"]; media != nil {
+			return media.Schema
+		}
+	}
+	return nil
+}
+
+func TestOpenAPIComponentSchemas(t *testing.T) {
+	doc := loadOpenAPI(t)
+	for _, v := range apiTypes {
+		typ := reflect.TypeOf(v)
+		schema, ok := doc.Components.Schemas[typ.Name()]
+		if !ok {
+			t.Errorf("%s has no component schema", typ.Name())
+			continue
+		}
+		compareFields(t, doc, typ.Name(), schema, reflectFields(typ), true)
+	}
+}
+
+// TestOpenAPITypesListed catches new response types that apiTypes, and so
+// the document, don't cover.
+func TestOpenAPITypesListed(t *testing.T) {
+	listed := map[string]bool{}
+	for _, v := range apiTypes {
+		listed[reflect.TypeOf(v).Name()] = true
+	}
+	for _, file := range parseSources(t) {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				st, ok := typeSpec.Type.(*ast.StructType)
+				if !ok || !typeSpec.Name.IsExported() || listed[typeSpec.Name.Name] {
+					continue
+				}
+				for _, field := range st.Fields.List {
+					if field.Tag != nil && strings.Contains(field.Tag.Value, "json:") {
+						t.Errorf("%s is encoded as JSON but isn't in apiTypes", typeSpec.Name.Name)
+						break
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/oauth"
+	"github.com/google/uuid"
+)
+
+// QuotedChirp is a chirp shown inside a quote, one level deep: its own
+// quote is only referenced by ID. If it's been deleted, one of the two
+// authors has blocked the other, or the viewer and the quoted author have
+// blocked each other, it's a placeholder instead. A deleted chirp's
+// placeholder has no ID.
+type QuotedChirp struct {
+	ID          *uuid.UUID `json:"id,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	Body        string     `json:"body"`
+	QuoteOf     *uuid.UUID `json:"quote_of,omitempty"`
+	Unavailable bool       `json:"unavailable"`
+}
+
+const unavailableChirpBody = "This chirp is unavailable"
+
+// unavailableQuote is the placeholder for a quoted chirp that can't be
+// shown. id is nil if the chirp was deleted.
+func unavailableQuote(id *uuid.UUID) *QuotedChirp {
+	return &QuotedChirp{
+		ID:          id,
+		Body:        unavailableChirpBody,
+		Unavailable: true,
+	}
+}
+
+// addQuotes fills in Quote for every chirp that quotes another, as
+// viewerID sees them; viewerID is uuid.Nil for anonymous readers.
+func (cfg *apiConfig) addQuotes(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) error {
+	quotedIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.QuoteOf != nil {
+			quotedIDs = append(quotedIDs, *chirp.QuoteOf)
+		}
+	}
+	if len(quotedIDs) == 0 {
+		return nil
+	}
+
+	dbQuoted, err := cfg.db.GetChirpsByIDs(ctx, quotedIDs)
+	if err != nil {
+		return err
+	}
+	userIDs := []uuid.UUID{viewerID}
+	for _, chirp := range chirps {
+		userIDs = append(userIDs, chirp.UserID)
+	}
+	quoted := map[uuid.UUID]Chirp{}
+	for _, dbChirp := range dbQuoted {
+		quoted[dbChirp.ID] = chirpFromDB(dbChirp)
+		userIDs = append(userIDs, dbChirp.UserID)
+	}
+
+	dbBlocks, err := cfg.db.ListBlocksAmong(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	type pair struct{ a, b uuid.UUID }
+	blocked := map[pair]bool{}
+	for _, block := range dbBlocks {
+		blocked[pair{block.BlockerID, block.BlockedID}] = true
+		blocked[pair{block.BlockedID, block.BlockerID}] = true
+	}
+
+	for i, chirp := range chirps {
+		if chirp.QuoteOf == nil {
+			continue
+		}
+		original, ok := quoted[*chirp.QuoteOf]
+		if !ok || blocked[pair{chirp.UserID, original.UserID}] || blocked[pair{viewerID, original.UserID}] {
+			chirps[i].Quote = unavailableQuote(chirp.QuoteOf)
+			continue
+		}
+		chirps[i].Quote = quotedChirpFrom(original)
+	}
+	return nil
+}
+
+func quotedChirpFrom(original Chirp) *QuotedChirp {
+	return &QuotedChirp{
+		ID:        &original.ID,
+		CreatedAt: &original.CreatedAt,
+		UserID:    &original.UserID,
+		Body:      original.Body,
+		QuoteOf:   original.QuoteOf,
+	}
+}
+
+// chirpViewer returns who is reading chirps on a public route, or
+// uuid.Nil if they're anonymous, responding with a 401 if the bearer
+// token is invalid.
+func (cfg *apiConfig) chirpViewer(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	accessToken, ok, err := cfg.optionalAccessToken(r, oauth.ScopeChirpsRead)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return uuid.Nil, false
+	}
+	if !ok {
+		return uuid.Nil, true
+	}
+	return accessToken.UserID, true
+}
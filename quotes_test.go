@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// createTestQuote has user quote quoted.
+func createTestQuote(t *testing.T, cfg *apiConfig, user database.User, quoted database.Chirp) database.Chirp {
+	t.Helper()
+	chirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
+		Body:      "look at this",
+		UserID:    user.ID,
+		QuoteOfID: uuid.NullUUID{UUID: quoted.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Couldn't create quote: %v", err)
+	}
+	return chirp
+}
+
+// quoteAs returns the quote in chirp as viewerID sees it.
+func quoteAs(t *testing.T, cfg *apiConfig, chirp database.Chirp, viewerID uuid.UUID) *QuotedChirp {
+	t.Helper()
+	dbChirp, err := cfg.db.GetChirp(context.Background(), chirp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps := []Chirp{chirpFromDB(dbChirp)}
+	err = cfg.addQuotes(context.Background(), chirps, viewerID)
+	if err != nil {
+		t.Fatalf("addQuotes() error = %v", err)
+	}
+	return chirps[0].Quote
+}
+
+func TestAddQuotes(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	original := createTestChirp(t, cfg, alice, "hello")
+	quote := createTestQuote(t, cfg, bob, original)
+
+	got := quoteAs(t, cfg, quote, uuid.Nil)
+	if got == nil || got.Unavailable || got.ID == nil || *got.ID != original.ID || got.Body != "hello" {
+		t.Errorf("quote = %+v, want alice's chirp", got)
+	}
+	if plain := quoteAs(t, cfg, original, uuid.Nil); plain != nil {
+		t.Errorf("a chirp that quotes nothing has quote %+v", plain)
+	}
+}
+
+func TestAddQuotesBlocks(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob, carol := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob"), createTestUser(t, cfg, "carol")
+	original := createTestChirp(t, cfg, alice, "hello")
+	quote := createTestQuote(t, cfg, bob, original)
+
+	// A viewer who blocked the quoted author doesn't see their chirp.
+	blockTestUser(t, cfg, carol, alice)
+	got := quoteAs(t, cfg, quote, carol.ID)
+	if got == nil || !got.Unavailable || got.Body != unavailableChirpBody || got.ID == nil || *got.ID != original.ID {
+		t.Errorf("carol sees %+v, want a placeholder for %s", got, original.ID)
+	}
+	if got := quoteAs(t, cfg, quote, bob.ID); got == nil || got.Unavailable {
+		t.Errorf("bob sees %+v, want alice's chirp", got)
+	}
+
+	// Neither does anyone once the authors block each other.
+	blockTestUser(t, cfg, alice, bob)
+	if got := quoteAs(t, cfg, quote, uuid.Nil); got == nil || !got.Unavailable {
+		t.Errorf("after alice blocked bob: quote = %+v, want a placeholder", got)
+	}
+}
+
+func TestAddQuotesDeleted(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob")
+	original := createTestChirp(t, cfg, alice, "hello")
+	quote := createTestQuote(t, cfg, bob, original)
+
+	_, err := cfg.db.DeleteChirpByID(context.Background(), database.DeleteChirpByIDParams{ID: original.ID, UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbChirp, err := cfg.db.GetChirp(context.Background(), quote.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbChirp.QuoteOfID.Valid || !dbChirp.IsQuote {
+		t.Errorf("quote_of_id = %v, is_quote = %v; want the ID cleared and the chirp still a quote", dbChirp.QuoteOfID, dbChirp.IsQuote)
+	}
+
+	got := quoteAs(t, cfg, quote, uuid.Nil)
+	if got == nil || !got.Unavailable || got.ID != nil {
+		t.Errorf("quote = %+v, want a placeholder without an ID", got)
+	}
+}
+
+func TestHandlerChirpsGetQuoteForViewer(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice, bob, carol := createTestUser(t, cfg, "alice"), createTestUser(t, cfg, "bob"), createTestUser(t, cfg, "carol")
+	quote := createTestQuote(t, cfg, bob, createTestChirp(t, cfg, alice, "hello"))
+	blockTestUser(t, cfg, carol, alice)
+	carolToken := loginTestUser(t, cfg, carol)
+	jwt, err := cfg.tokens.MakeAccessToken(carol.ID, carolToken.SessionID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(token string) Chirp {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/api/chirps/"+quote.ID.String(), nil)
+		r.SetPathValue("chirpID", quote.ID.String())
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		cfg.handlerChirpsGet(w, r)
+		expectStatus(t, w, http.StatusOK)
+		var chirp Chirp
+		err := json.NewDecoder(w.Body).Decode(&chirp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chirp
+	}
+
+	if chirp := get(""); chirp.Quote == nil || chirp.Quote.Unavailable {
+		t.Errorf("anonymous reader sees quote %+v, want alice's chirp", chirp.Quote)
+	}
+	if chirp := get(jwt); chirp.Quote == nil || !chirp.Quote.Unavailable {
+		t.Errorf("carol sees quote %+v, want a placeholder", chirp.Quote)
+	}
+}
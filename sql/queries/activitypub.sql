@@ -0,0 +1,132 @@
+-- name: ClaimActivityPubDeliveries :many
+UPDATE activitypub_deliveries SET next_attempt_at = sqlc.arg(lease_until),
+updated_at = NOW()
+WHERE id IN (
+    SELECT due.id FROM activitypub_deliveries due
+    WHERE due.status = 'pending'
+        AND due.next_attempt_at <= sqlc.arg(now)
+    ORDER BY due.next_attempt_at
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CreateActivityPubDelivery :exec
+INSERT INTO activitypub_deliveries (id, created_at, updated_at, sender_id, inbox, payload, status, attempts, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    'pending',
+    0,
+    $4
+);
+
+-- name: CreatePendingFollow :exec
+INSERT INTO activitypub_pending_follows (follow_uri, created_at, follower_id, followee_id)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3
+);
+
+-- name: CreateRemoteNote :execrows
+INSERT INTO remote_notes (chirp_id, object_uri)
+VALUES (
+    $1,
+    $2
+)
+ON CONFLICT (object_uri) DO NOTHING;
+
+-- name: CreateUserKey :one
+-- Two requests may make a key at once; the first one saved wins.
+INSERT INTO user_keys (user_id, created_at, public_key_pem, private_key_pem)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3
+)
+ON CONFLICT (user_id) DO UPDATE SET user_id = user_keys.user_id
+RETURNING *;
+
+-- name: DeletePendingFollow :one
+DELETE FROM activitypub_pending_follows
+WHERE follow_uri = $1
+    AND followee_id = $2
+RETURNING follower_id;
+
+-- name: DeletePendingFollowsBetween :exec
+DELETE FROM activitypub_pending_follows
+WHERE follower_id = $1
+    AND followee_id = $2;
+
+-- name: DeleteRemoteChirp :one
+-- Only the actor who wrote a note can delete the chirp made from it.
+DELETE FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+    AND id = (
+        SELECT chirp_id FROM remote_notes
+        WHERE object_uri = sqlc.arg(object_uri)
+    )
+RETURNING *;
+
+-- name: GetRemoteActor :one
+SELECT * FROM remote_actors
+WHERE actor_uri = $1;
+
+-- name: GetRemoteActorByUserID :one
+SELECT * FROM remote_actors
+WHERE user_id = $1;
+
+-- name: GetRemoteNote :one
+SELECT * FROM remote_notes
+WHERE chirp_id = $1;
+
+-- name: GetUserKey :one
+SELECT * FROM user_keys
+WHERE user_id = $1;
+
+-- name: IsRemoteActorFollowed :one
+SELECT EXISTS (
+    SELECT 1 FROM follows
+    WHERE followee_id = $1
+);
+
+-- name: ListRemoteFollowers :many
+SELECT remote_actors.* FROM remote_actors
+JOIN follows ON follows.follower_id = remote_actors.user_id
+WHERE follows.followee_id = $1
+ORDER BY follows.created_at ASC;
+
+-- name: UpdateActivityPubDelivery :exec
+UPDATE activitypub_deliveries SET status = $2,
+attempts = $3,
+next_attempt_at = $4,
+last_attempt_at = $5,
+response_status = $6,
+last_error = $7,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpsertRemoteActor :one
+INSERT INTO remote_actors (user_id, actor_uri, inbox, shared_inbox, public_key_id, public_key_pem, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW()
+)
+ON CONFLICT (actor_uri) DO UPDATE SET inbox = EXCLUDED.inbox,
+shared_inbox = EXCLUDED.shared_inbox,
+public_key_id = EXCLUDED.public_key_id,
+public_key_pem = EXCLUDED.public_key_pem,
+updated_at = NOW()
+RETURNING *;
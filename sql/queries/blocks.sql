@@ -0,0 +1,27 @@
+-- name: CreateBlock :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1
+    AND blocked_id = $2;
+
+-- name: HasBlockBetween :one
+-- Reports whether user_id has blocked, or been blocked by, any of others.
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(others)::UUID[]))
+        OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(others)::UUID[]))
+);
+
+-- name: ListBlocksAmong :many
+-- Blocks where both users are in user_ids.
+SELECT * FROM blocks
+WHERE blocker_id = ANY(sqlc.arg(user_ids)::UUID[])
+    AND blocked_id = ANY(sqlc.arg(user_ids)::UUID[]);
@@ -0,0 +1,20 @@
+-- name: CreateBookmark :execrows
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1
+    AND chirp_id = $2;
+
+-- name: ListBookmarkedChirps :many
+-- Most recently bookmarked first.
+SELECT chirps.* FROM chirps
+JOIN bookmarks ON bookmarks.chirp_id = chirps.id
+WHERE bookmarks.user_id = $1
+ORDER BY bookmarks.created_at DESC;
@@ -0,0 +1,13 @@
+-- name: CreateChirpLike :execrows
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: DeleteChirpLike :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1
+    AND chirp_id = $2;
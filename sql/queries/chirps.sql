@@ -1,11 +1,15 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id, is_quote, hashtags)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2)
+    $2,
+    $3,
+    $4,
+    $4::UUID IS NOT NULL,
+    COALESCE(sqlc.narg(hashtags)::TEXT[], '{}'))
 RETURNING *;
 
 -- name: GetAllChirps :many
@@ -19,4 +23,40 @@ WHERE id  = $1;
 -- name: DeleteChirpByID :one
 DELETE FROM chirps 
 WHERE id = $1 AND user_id = $2
-RETURNING id;
\ No newline at end of file
+RETURNING id;
+-- name: GetChirpsByAuthors :many
+SELECT * FROM chirps
+WHERE user_id = ANY(sqlc.arg(author_ids)::UUID[])
+ORDER BY created_at ASC;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::UUID[]);
+
+-- name: ListRecentChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: GetChirpsState :one
+-- How many chirps match, and when the matching chirps last changed,
+-- counting deletes, for feed validators. author_id and tag are optional
+-- filters.
+SELECT COUNT(*) AS chirp_count,
+    GREATEST(
+        COALESCE(MAX(updated_at), TIMESTAMP 'epoch'),
+        COALESCE((
+            SELECT MAX(deleted_at) FROM feed_deletions
+            WHERE feed_key IN ('author:' || sqlc.narg(author_id)::TEXT, 'tag:' || sqlc.narg(tag)::TEXT)
+        ), TIMESTAMP 'epoch')
+    )::TIMESTAMP AS last_changed
+FROM chirps
+WHERE (sqlc.narg(author_id)::UUID IS NULL OR user_id = sqlc.narg(author_id))
+    AND (sqlc.narg(tag)::TEXT IS NULL OR hashtags @> ARRAY[sqlc.narg(tag)::TEXT]);
+
+-- name: ListRecentChirpsByHashtag :many
+SELECT * FROM chirps
+WHERE hashtags @> ARRAY[sqlc.arg(tag)::TEXT]
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,98 @@
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at, last_read_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW()
+);
+
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: FindDirectConversation :one
+-- Finds the one-to-one conversation between user_id and other_id.
+SELECT conversations.* FROM conversations
+WHERE conversations.id IN (
+        SELECT conversation_id FROM conversation_participants WHERE user_id = sqlc.arg(user_id)
+    )
+    AND conversations.id IN (
+        SELECT conversation_id FROM conversation_participants WHERE user_id = sqlc.arg(other_id)
+    )
+    AND (SELECT COUNT(*) FROM conversation_participants
+        WHERE conversation_participants.conversation_id = conversations.id) = 2
+ORDER BY conversations.created_at ASC
+LIMIT 1;
+
+-- name: GetConversationForUser :one
+SELECT conversations.*,
+    (SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+            AND messages.sender_id <> conversation_participants.user_id
+            AND messages.created_at > conversation_participants.last_read_at) AS unread_count
+FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversations.id = sqlc.arg(id)
+    AND conversation_participants.user_id = sqlc.arg(user_id);
+
+-- name: GetMessage :one
+SELECT * FROM messages
+WHERE id = $1
+    AND conversation_id = $2;
+
+-- name: ListConversationParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::UUID[])
+ORDER BY joined_at ASC, user_id ASC;
+
+-- name: ListConversations :many
+SELECT conversations.*,
+    (SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+            AND messages.sender_id <> conversation_participants.user_id
+            AND messages.created_at > conversation_participants.last_read_at) AS unread_count
+FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversation_participants.user_id = $1
+ORDER BY conversations.updated_at DESC;
+
+-- name: ListMessages :many
+-- Newest first. Pass the oldest message of a page as before to get the
+-- next page; a before from another conversation matches nothing.
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+    AND (sqlc.narg(before)::UUID IS NULL
+        OR (created_at, id) < (SELECT before.created_at, before.id FROM messages AS before
+            WHERE before.id = sqlc.narg(before)
+                AND before.conversation_id = sqlc.arg(conversation_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: MarkConversationRead :one
+-- Read receipts only move forward.
+UPDATE conversation_participants SET last_read_at = GREATEST(last_read_at, sqlc.arg(read_at))
+WHERE conversation_id = sqlc.arg(conversation_id)
+    AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW()
+WHERE id = $1;
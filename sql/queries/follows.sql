@@ -0,0 +1,24 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+    AND followee_id = $2;
+
+-- name: DeleteFollowsBetween :exec
+-- Removes the follows between two users in both directions.
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_id) AND followee_id = sqlc.arg(other_id))
+    OR (follower_id = sqlc.arg(other_id) AND followee_id = sqlc.arg(user_id));
+
+-- name: ListFollowing :many
+SELECT followee_id FROM follows
+WHERE follower_id = $1
+ORDER BY created_at ASC;
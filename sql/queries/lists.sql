@@ -0,0 +1,59 @@
+-- name: AddListMember :execrows
+INSERT INTO list_members (list_id, user_id, added_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members
+WHERE list_id = $1;
+
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, user_id, name, private)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1
+    AND user_id = $2;
+
+-- name: GetList :one
+SELECT * FROM lists
+WHERE id = $1;
+
+-- name: ListListMembers :many
+SELECT * FROM list_members
+WHERE list_id = ANY(sqlc.arg(list_ids)::UUID[])
+ORDER BY added_at ASC, user_id ASC;
+
+-- name: ListLists :many
+-- A user's lists. include_private is false when someone else is looking.
+SELECT * FROM lists
+WHERE user_id = sqlc.arg(user_id)
+    AND (sqlc.arg(include_private)::BOOLEAN OR NOT private)
+ORDER BY created_at ASC;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1
+    AND user_id = $2;
+
+-- name: UpdateList :one
+UPDATE lists SET
+    updated_at = NOW(),
+    name = $3,
+    private = $4
+WHERE id = $1
+    AND user_id = $2
+RETURNING *;
@@ -0,0 +1,26 @@
+-- name: GetLoginAttempts :one
+SELECT * FROM login_attempts
+WHERE attempt_key = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_attempts (attempt_key, failures, last_failure_at)
+VALUES (
+    $1,
+    1,
+    $2
+)
+ON CONFLICT (attempt_key) DO UPDATE SET
+    failures = CASE
+        WHEN login_attempts.last_failure_at < sqlc.arg(window_start)::timestamp THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING *;
+
+-- name: LockLoginAttempts :exec
+UPDATE login_attempts SET locked_until = $2
+WHERE attempt_key = $1;
+
+-- name: ResetLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE attempt_key = $1;
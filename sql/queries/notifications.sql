@@ -0,0 +1,63 @@
+-- name: AddNotificationActor :execrows
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (notification_id, actor_id) DO NOTHING;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1
+    AND read_at IS NULL;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: ListNotifications :many
+SELECT notifications.*,
+    (SELECT COUNT(*) FROM notification_actors
+        WHERE notification_actors.notification_id = notifications.id) AS actor_count,
+    ARRAY(SELECT notification_actors.actor_id FROM notification_actors
+        WHERE notification_actors.notification_id = notifications.id
+        ORDER BY notification_actors.created_at DESC
+        LIMIT 3)::UUID[] AS recent_actor_ids
+FROM notifications
+WHERE notifications.user_id = sqlc.arg(user_id)
+    AND (NOT sqlc.arg(unread_only)::BOOLEAN OR notifications.read_at IS NULL)
+ORDER BY notifications.updated_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+    AND read_at IS NULL
+    AND (sqlc.arg(all_notifications)::BOOLEAN OR id = ANY(sqlc.arg(ids)::UUID[]));
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled,
+updated_at = NOW();
+
+-- name: UpsertNotification :one
+-- Adds to the unread notification of the same group if there is one.
+-- Returns no row if the user has turned this type off.
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, group_key, chirp_id, read_at)
+SELECT gen_random_uuid(), NOW(), NOW(), sqlc.arg(user_id), sqlc.arg(type), sqlc.arg(group_key), sqlc.narg(chirp_id), NULL
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE notification_preferences.user_id = sqlc.arg(user_id)
+        AND notification_preferences.type = sqlc.arg(type)
+        AND NOT notification_preferences.enabled
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW()
+RETURNING *;
@@ -0,0 +1,35 @@
+-- name: CreatePersonalToken :one
+INSERT INTO personal_tokens (id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NULL,
+    NULL
+)
+RETURNING *;
+
+-- name: ListPersonalTokens :many
+SELECT * FROM personal_tokens
+WHERE user_id = $1
+    AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: RevokePersonalToken :execrows
+UPDATE personal_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+    AND user_id = $2
+    AND revoked_at IS NULL;
+
+-- name: UsePersonalToken :one
+UPDATE personal_tokens SET last_used_at = NOW()
+WHERE token_hash = $1
+    AND revoked_at IS NULL
+    AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING *;
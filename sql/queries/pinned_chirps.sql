@@ -0,0 +1,35 @@
+-- name: CountPinnedChirps :one
+SELECT COUNT(*) FROM pinned_chirps
+WHERE user_id = $1;
+
+-- name: DeletePinnedChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1
+    AND chirp_id = $2;
+
+-- name: IsChirpPinned :one
+SELECT EXISTS (
+    SELECT 1 FROM pinned_chirps
+    WHERE user_id = $1
+        AND chirp_id = $2
+);
+
+-- name: ListPinnedChirpIDs :many
+-- Most recently pinned first.
+SELECT chirp_id FROM pinned_chirps
+WHERE user_id = $1
+ORDER BY pinned_at DESC;
+
+-- name: LockUserPins :exec
+-- Serializes pinning for a user until the transaction ends, so the limit
+-- can be checked with CountPinnedChirps before PinChirp.
+SELECT pg_advisory_xact_lock(hashtextextended('pinned_chirps:' || sqlc.arg(user_id)::TEXT, 0));
+
+-- name: PinChirp :execrows
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
@@ -1,23 +1,71 @@
 -- name: CreateRefreshToken :exec
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip_address, session_created_at, last_used_at, client_id, scope)
 VALUES (
     $1, 
     $2, 
     $2, 
     $3, 
     $4, 
-    NULL 
+    NULL,
+    $5,
+    $6,
+    $7,
+    $8,
+    $2,
+    $9,
+    $10
 );
 
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
+
 -- name: GetUserFromRefreshToken :one
 SELECT users.* FROM users 
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1
+WHERE refresh_tokens.token_hash = $1
     AND refresh_tokens.expires_at > NOW()
     AND refresh_tokens.revoked_at IS NULL;
 
 -- name: RevokeRefreshToken :one
 UPDATE refresh_tokens SET revoked_at = NOW(),
 updated_at = NOW()
-WHERE token = $1
-RETURNING *;
\ No newline at end of file
+WHERE token_hash = $1
+RETURNING *;
+
+-- name: RotateRefreshToken :one
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW(),
+replaced_by = $2
+WHERE token_hash = $1
+    AND revoked_at IS NULL
+    AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE family_id = $1
+    AND revoked_at IS NULL;
+
+
+-- name: ListUserSessions :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+    AND revoked_at IS NULL
+    AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1
+    AND family_id = $2
+    AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserSessions :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1
+    AND family_id <> $2
+    AND revoked_at IS NULL;
\ No newline at end of file
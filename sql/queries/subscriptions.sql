@@ -0,0 +1,28 @@
+-- name: GetSubscriptionByUser :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, canceled_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id) DO UPDATE SET plan = EXCLUDED.plan,
+status = EXCLUDED.status,
+current_period_end = EXCLUDED.current_period_end,
+canceled_at = EXCLUDED.canceled_at,
+updated_at = NOW()
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :execrows
+UPDATE subscriptions SET status = 'expired',
+updated_at = NOW()
+WHERE (status IN ('active', 'past_due') AND current_period_end < $1)
+    OR (status = 'canceled' AND current_period_end < NOW());
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -13,12 +14,29 @@ RETURNING *;
 SELECT * FROM users
 WHERE email = $1;
 
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE username = $1;
+
+-- name: GetUsersByIDs :many
+SELECT * FROM users
+WHERE id = ANY(sqlc.arg(ids)::UUID[]);
+
+-- name: GetUsersByUsernames :many
+SELECT * FROM users
+WHERE username = ANY(sqlc.arg(usernames)::TEXT[]);
+
 -- name: UpdateUser :one
-UPDATE users Set email = $2, hashed_password = $3, updated_at = NOW()
+UPDATE users Set email = $2, hashed_password = $3,
+username = COALESCE(sqlc.narg(username), username),
+updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
--- name: UpgradeToChirpyRedById :one
-UPDATE users Set is_chirpy_red = TRUE, updated_at = NOW()
-WHERE id = $1
-RETURNING *;
\ No newline at end of file
+-- name: UpdateUserPassword :exec
+UPDATE users Set hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,36 @@
+-- name: CreateWebhookDeadLetter :one
+INSERT INTO webhook_dead_letters (id, created_at, updated_at, source, event_id, event, payload, status_code, error, replayed_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NULL
+)
+RETURNING *;
+
+-- name: ListWebhookDeadLetters :many
+SELECT * FROM webhook_dead_letters
+WHERE (sqlc.arg(include_replayed)::BOOLEAN OR replayed_at IS NULL)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetWebhookDeadLetter :one
+SELECT * FROM webhook_dead_letters
+WHERE id = $1;
+
+-- name: MarkWebhookDeadLetterReplayed :exec
+UPDATE webhook_dead_letters SET replayed_at = NOW(),
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateWebhookDeadLetterError :exec
+UPDATE webhook_dead_letters SET status_code = $2,
+error = $3,
+updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,77 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1
+    AND user_id = $2;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1
+    AND user_id = $2;
+
+-- name: EnqueueWebhookEvent :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event, payload, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), webhook_endpoints.id, sqlc.arg(event_id), sqlc.arg(event), sqlc.arg(payload), 'pending', 0, NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.user_id = sqlc.arg(user_id)
+    AND sqlc.arg(event)::TEXT = ANY(string_to_array(webhook_endpoints.events, ' '));
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1
+    AND endpoint_id = $2;
+
+-- name: ReplayWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event, payload, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), endpoint_id, event_id, event, payload, 'pending', 0, NOW()
+FROM webhook_deliveries
+WHERE webhook_deliveries.id = $1
+RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg(lease_until),
+updated_at = NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id
+    AND webhook_deliveries.id IN (
+        SELECT due.id FROM webhook_deliveries due
+        WHERE due.status = 'pending'
+            AND due.next_attempt_at <= sqlc.arg(now)
+        ORDER BY due.next_attempt_at
+        LIMIT sqlc.arg(max_deliveries)
+        FOR UPDATE SKIP LOCKED
+    )
+RETURNING webhook_deliveries.*, webhook_endpoints.url, webhook_endpoints.secret;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries SET status = $2,
+attempts = $3,
+next_attempt_at = $4,
+last_attempt_at = $5,
+response_status = $6,
+last_error = $7,
+updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,9 @@
+-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (source, id, event, processed_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (source, id) DO NOTHING;
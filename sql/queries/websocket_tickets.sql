@@ -0,0 +1,26 @@
+-- name: CreateWebsocketTicket :exec
+INSERT INTO websocket_tickets (ticket_hash, created_at, user_id, session_id, client_id, personal_token_id, scope, token_expires_at, expires_at, used_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    NULL
+);
+
+-- name: ConsumeWebsocketTicket :one
+UPDATE websocket_tickets SET used_at = NOW()
+WHERE ticket_hash = $1
+    AND used_at IS NULL
+    AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredWebsocketTickets :execrows
+DELETE FROM websocket_tickets
+WHERE user_id = $1
+    AND expires_at <= NOW();
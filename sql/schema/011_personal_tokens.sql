@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE personal_tokens (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    expires_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX personal_tokens_user_id_idx ON personal_tokens (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS personal_tokens;
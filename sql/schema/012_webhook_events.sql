@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    source TEXT NOT NULL,
+    id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source, id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_events;
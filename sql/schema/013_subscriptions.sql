@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_end TIMESTAMP NULL,
+    canceled_at TIMESTAMP NULL
+);
+
+CREATE INDEX subscriptions_status_period_idx ON subscriptions (status, current_period_end);
+
+-- Upgrades used to be permanent, and existing members keep that: their
+-- subscriptions have no end date until Polka bills them.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, canceled_at)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', NULL, NULL
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET is_chirpy_red = TRUE
+WHERE id IN (
+    SELECT user_id FROM subscriptions
+    WHERE status <> 'expired'
+);
+
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE webhook_dead_letters (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    source TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status_code INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    replayed_at TIMESTAMP NULL
+);
+
+CREATE INDEX webhook_dead_letters_created_at_idx ON webhook_dead_letters (created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_dead_letters;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- The quoted chirp. 029_quote_of_fk.sql adds its foreign key, which
+-- clears it when that chirp is deleted.
+ALTER TABLE chirps
+ADD COLUMN quote_of_id UUID;
+
+CREATE INDEX chirps_quote_of_id_idx ON chirps (quote_of_id);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN quote_of_id;
@@ -0,0 +1,50 @@
+-- +goose Up
+-- Signing keys for federated users, made the first time they're needed.
+CREATE TABLE user_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL
+);
+
+-- Each remote actor has a local user, whose email is the actor's URI and
+-- whose password can't be used, so follows, likes and replies from other
+-- servers fit the existing tables.
+CREATE TABLE remote_actors (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    actor_uri TEXT NOT NULL UNIQUE,
+    inbox TEXT NOT NULL,
+    shared_inbox TEXT NOT NULL DEFAULT '',
+    public_key_id TEXT NOT NULL,
+    public_key_pem TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Chirps that came from other servers, by the note they were made from.
+CREATE TABLE remote_notes (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    object_uri TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE activitypub_deliveries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    inbox TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP NULL,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX activitypub_deliveries_due_idx ON activitypub_deliveries (status, next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS activitypub_deliveries;
+DROP TABLE IF EXISTS remote_notes;
+DROP TABLE IF EXISTS remote_actors;
+DROP TABLE IF EXISTS user_keys;
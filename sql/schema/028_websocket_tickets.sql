@@ -0,0 +1,22 @@
+-- +goose Up
+-- A ticket stands in for an access token on a WebSocket handshake, since
+-- browsers can't set headers there and URLs end up in logs. It carries
+-- what the token said about its bearer; token_expires_at is NULL for
+-- personal access tokens that never expire.
+CREATE TABLE websocket_tickets (
+    ticket_hash VARCHAR(64) PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id UUID NULL,
+    client_id TEXT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    personal_token_id UUID NULL REFERENCES personal_tokens(id) ON DELETE CASCADE,
+    scope TEXT NOT NULL,
+    token_expires_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL
+);
+
+CREATE INDEX websocket_tickets_user_id_idx ON websocket_tickets (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS websocket_tickets;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Deleting a quoted chirp clears quote_of_id; is_quote remembers that the
+-- chirp was a quote, so it's shown with an unavailable placeholder rather
+-- than as not a quote at all.
+ALTER TABLE chirps
+ADD COLUMN is_quote BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE chirps SET is_quote = TRUE
+WHERE quote_of_id IS NOT NULL;
+
+UPDATE chirps SET quote_of_id = NULL
+WHERE quote_of_id IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM chirps AS quoted WHERE quoted.id = chirps.quote_of_id);
+
+ALTER TABLE chirps
+ADD CONSTRAINT chirps_quote_of_id_fkey FOREIGN KEY (quote_of_id) REFERENCES chirps(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_quote_of_id_fkey;
+
+ALTER TABLE chirps
+DROP COLUMN is_quote;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- The hashtags in each chirp, as hashtag.Extract finds them, so feeds can
+-- filter by hashtag in SQL. The backfill's character classes follow the
+-- database's locale, which may differ from Go's idea of a letter for
+-- some scripts; chirps created from now on use hashtag.Extract.
+ALTER TABLE chirps
+ADD COLUMN hashtags TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE chirps SET hashtags = ARRAY(
+    SELECT DISTINCT lower(match[1])
+    FROM regexp_matches(body, '(?:^|[^[:alnum:]_])#([[:alnum:]_]+)', 'g') AS match
+);
+
+CREATE INDEX chirps_hashtags_idx ON chirps USING GIN (hashtags);
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_user_id_created_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN hashtags;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Follows sent to remote actors that they haven't accepted yet. An Accept
+-- only records a follow if it names one of these.
+CREATE TABLE activitypub_pending_follows (
+    follow_uri TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS activitypub_pending_follows;
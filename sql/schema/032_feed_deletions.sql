@@ -0,0 +1,33 @@
+-- +goose Up
+-- When each feed last lost a chirp, keyed by "author:<user id>" and
+-- "tag:<hashtag>". Creates and edits show in the chirps' updated_at, but a
+-- delete leaves nothing behind, so without this a feed's Last-Modified
+-- would stay put or move backwards. A trigger records every delete,
+-- including those cascaded from deleting a user.
+CREATE TABLE feed_deletions (
+    feed_key TEXT PRIMARY KEY,
+    deleted_at TIMESTAMP NOT NULL
+);
+
+-- +goose StatementBegin
+CREATE FUNCTION record_feed_deletion() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO feed_deletions (feed_key, deleted_at)
+    SELECT DISTINCT feed_key, NOW()
+    FROM unnest(ARRAY['author:' || OLD.user_id::TEXT] || ARRAY(
+        SELECT 'tag:' || tag FROM unnest(OLD.hashtags) AS tag
+    )) AS feed_key
+    ON CONFLICT (feed_key) DO UPDATE SET deleted_at = GREATEST(feed_deletions.deleted_at, EXCLUDED.deleted_at);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_record_feed_deletion
+AFTER DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION record_feed_deletion();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS chirps_record_feed_deletion ON chirps;
+DROP FUNCTION IF EXISTS record_feed_deletion();
+DROP TABLE IF EXISTS feed_deletions;
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/MechamJonathan/chirpy/internal/database"
+	"github.com/MechamJonathan/chirpy/internal/subscription"
+	"github.com/google/uuid"
+)
+
+// isChirpyRed reports whether the user's subscription currently grants
+// Chirpy Red.
+func (cfg *apiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	sub, err := cfg.db.GetSubscriptionByUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return subscriptionFromDB(sub).Active(time.Now().UTC(), cfg.subscriptionGrace), nil
+}
+
+// applySubscriptionEvent moves the user's subscription through a Polka
+// billing event.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, db *database.Queries, userID uuid.UUID, event subscription.Event) error {
+	_, err := db.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var current *subscription.Subscription
+	dbSub, err := db.GetSubscriptionByUser(ctx, userID)
+	if err == nil {
+		sub := subscriptionFromDB(dbSub)
+		current = &sub
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	next, err := subscription.Apply(current, event, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	_, err = db.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:           userID,
+		Plan:             next.Plan,
+		Status:           string(next.Status),
+		CurrentPeriodEnd: sql.NullTime{Time: next.CurrentPeriodEnd, Valid: !next.CurrentPeriodEnd.IsZero()},
+		CanceledAt:       sql.NullTime{Time: next.CanceledAt, Valid: !next.CanceledAt.IsZero()},
+	})
+	return err
+}
+
+// expireSubscriptionsEvery marks lapsed subscriptions as expired on every
+// tick until ctx is done. isChirpyRed doesn't depend on it, but it keeps
+// the stored status honest for everything else that reads it.
+func (cfg *apiConfig) expireSubscriptionsEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cutoff := time.Now().UTC().Add(-cfg.subscriptionGrace)
+		expired, err := cfg.db.ExpireLapsedSubscriptions(ctx, cutoff)
+		if err != nil {
+			log.Printf("Couldn't expire subscriptions: %s", err)
+			continue
+		}
+		if expired > 0 {
+			log.Printf("Expired %d lapsed subscriptions", expired)
+		}
+	}
+}
+
+func subscriptionFromDB(sub database.Subscription) subscription.Subscription {
+	return subscription.Subscription{
+		Plan:             sub.Plan,
+		Status:           subscription.Status(sub.Status),
+		CurrentPeriodEnd: sub.CurrentPeriodEnd.Time,
+		CanceledAt:       sub.CanceledAt.Time,
+	}
+}
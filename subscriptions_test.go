@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExpireSubscriptionsEveryStopsWithContext(t *testing.T) {
+	cfg := &apiConfig{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		// The interval never elapses, so the database is never touched.
+		cfg.expireSubscriptionsEvery(ctx, time.Hour)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expireSubscriptionsEvery kept running after its context was canceled")
+	}
+}